@@ -0,0 +1,315 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	semconv "go.opentelemetry.io/collector/semconv/v1.27.0"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// aggregationFields are the flow fields that can be used to group flows
+// Each one copies the field from a flow into the aggregated flow
+// The addresses are cloned, goflow2 points them into the packet buffer that is reused once the packet is decoded
+var aggregationFields = map[string]func(dst, src *protoproducer.ProtoProducerMessage){
+	semconv.AttributeSourceAddress:      func(dst, src *protoproducer.ProtoProducerMessage) { dst.SrcAddr = slices.Clone(src.SrcAddr) },
+	semconv.AttributeDestinationAddress: func(dst, src *protoproducer.ProtoProducerMessage) { dst.DstAddr = slices.Clone(src.DstAddr) },
+	semconv.AttributeSourcePort:         func(dst, src *protoproducer.ProtoProducerMessage) { dst.SrcPort = src.SrcPort },
+	semconv.AttributeDestinationPort:    func(dst, src *protoproducer.ProtoProducerMessage) { dst.DstPort = src.DstPort },
+	semconv.AttributeNetworkTransport:   func(dst, src *protoproducer.ProtoProducerMessage) { dst.Proto = src.Proto },
+	semconv.AttributeNetworkType:        func(dst, src *protoproducer.ProtoProducerMessage) { dst.Etype = src.Etype },
+	"flow.type":                         func(dst, src *protoproducer.ProtoProducerMessage) { dst.Type = src.Type },
+	"flow.sampler_address": func(dst, src *protoproducer.ProtoProducerMessage) {
+		dst.SamplerAddress = slices.Clone(src.SamplerAddress)
+	},
+	"flow.interface.in.index":  func(dst, src *protoproducer.ProtoProducerMessage) { dst.InIf = src.InIf },
+	"flow.interface.out.index": func(dst, src *protoproducer.ProtoProducerMessage) { dst.OutIf = src.OutIf },
+}
+
+// aggregatedAttributes are the attributes that every aggregated flow has, besides the key
+var aggregatedAttributes = map[string]struct{}{
+	"flow.io.bytes":      {},
+	"flow.io.packets":    {},
+	"flow.start":         {},
+	"flow.end":           {},
+	"flow.time_received": {},
+}
+
+// aggregationKey identifies a group of flows, only the fields that are part of the key are set
+type aggregationKey struct {
+	srcAddr, dstAddr, samplerAddr string
+	srcPort, dstPort              uint32
+	proto, etype                  uint32
+	inIf, outIf                   uint32
+	flowType                      int32
+}
+
+func newAggregationKey(pm *protoproducer.ProtoProducerMessage) aggregationKey {
+	return aggregationKey{
+		srcAddr:     string(pm.SrcAddr),
+		dstAddr:     string(pm.DstAddr),
+		samplerAddr: string(pm.SamplerAddress),
+		srcPort:     pm.SrcPort,
+		dstPort:     pm.DstPort,
+		proto:       pm.Proto,
+		etype:       pm.Etype,
+		inIf:        pm.InIf,
+		outIf:       pm.OutIf,
+		flowType:    int32(pm.Type),
+	}
+}
+
+// aggregatedFlowMessage represents all the flows with the same key seen during a window
+type aggregatedFlowMessage struct {
+	// flow holds the key fields and the sum of bytes and packets
+	flow *protoproducer.ProtoProducerMessage
+	// flows is the number of flows that were added together
+	flows uint64
+	// overflow is true for the bucket that holds the flows that did not fit in the max keys
+	overflow bool
+	key      []string
+
+	// estimated holds the totals scaled by the sampling rate of each flow, it is nil unless requested
+	estimated *protoproducer.ProtoProducerMessage
+}
+
+// addAttributes adds the key attributes and the totals of the aggregated flow to the map
+// The enrichers run before the attributes are filtered, so optional attributes can be part of the key
+func (a *aggregatedFlowMessage) addAttributes(attrs pcommon.Map, enrichers ...flowEnricher) {
+	addFlowAttributes(a.flow, attrs)
+	for _, enricher := range enrichers {
+		enricher.enrich(a.flow, attrs)
+	}
+
+	keep := make(map[string]struct{}, len(a.key))
+	if !a.overflow {
+		for _, field := range a.key {
+			keep[field] = struct{}{}
+		}
+	}
+	attrs.RemoveIf(func(k string, _ pcommon.Value) bool {
+		_, isKey := keep[k]
+		_, isAggregated := aggregatedAttributes[k]
+		return !isKey && !isAggregated
+	})
+
+	// The aggregated flow has no sampling rate, as flows with different rates can be added together
+	if a.estimated != nil {
+		attrs.PutInt("flow.io.estimated_bytes", int64(a.estimated.Bytes))
+		attrs.PutInt("flow.io.estimated_packets", int64(a.estimated.Packets))
+	}
+
+	attrs.PutInt("flow.count", int64(a.flows))
+	if a.overflow {
+		attrs.PutBool("flow.aggregation.overflow", true)
+	}
+}
+
+// aggregationFlush is the message sent through the output producers when a window closes
+// The stitcher sends its flows the same way
+type aggregationFlush struct {
+	flows []producer.ProducerMessage
+}
+
+// aggregationOutput is the innermost producer of the chain that receives the aggregated or stitched flows
+// It simply returns the flows of an aggregationFlush so the logs and metrics producers can convert them
+type aggregationOutput struct{}
+
+func (aggregationOutput) Produce(msg any, _ *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	flush, ok := msg.(*aggregationFlush)
+	if !ok {
+		return nil, fmt.Errorf("expected an aggregation flush, got %T", msg)
+	}
+	return flush.flows, nil
+}
+
+func (aggregationOutput) Close() {}
+
+// Commit does nothing, the aggregated flows do not belong to the proto producer pool
+func (aggregationOutput) Commit([]producer.ProducerMessage) {}
+
+// flowAggregator is a producer that groups flows by key and sends the totals to the output when the window closes
+type flowAggregator struct {
+	wrapped producer.ProducerInterface
+	output  producer.ProducerInterface
+	cfg     AggregationConfig
+	logger  *zap.Logger
+	// estimate keeps the totals scaled by the sampling rate, when the estimates are added as attributes
+	estimate bool
+
+	mu          sync.Mutex
+	flows       map[aggregationKey]*aggregatedFlowMessage
+	overflow    *aggregatedFlowMessage
+	windowStart time.Time
+	// events are the firewall and NAT events of the window, they are sent as they are
+	events []producer.ProducerMessage
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newFlowAggregator(wrapped producer.ProducerInterface, cfg AggregationConfig, logger *zap.Logger) *flowAggregator {
+	return &flowAggregator{
+		wrapped:     wrapped,
+		cfg:         cfg,
+		logger:      logger,
+		flows:       make(map[aggregationKey]*aggregatedFlowMessage),
+		windowStart: time.Now(),
+	}
+}
+
+// Produce adds the flows of the message to the current window
+// The flows are still returned so the pipe commits them back to the wrapped producer, the firewall and NAT events are copied
+func (f *flowAggregator) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	flowMessageSet, err := f.wrapped.Produce(msg, args)
+	if err != nil {
+		return flowMessageSet, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, m := range flowMessageSet {
+		pm, ok := m.(*protoproducer.ProtoProducerMessage)
+		if !ok {
+			continue
+		}
+		if _, ok := getSecurityEvent(pm); ok {
+			event := &protoproducer.ProtoProducerMessage{}
+			proto.Merge(&event.FlowMessage, &pm.FlowMessage)
+			f.events = append(f.events, event)
+			continue
+		}
+		f.add(pm)
+	}
+
+	return flowMessageSet, nil
+}
+
+// add must be called with the lock held
+func (f *flowAggregator) add(pm *protoproducer.ProtoProducerMessage) {
+	keyFlow := &protoproducer.ProtoProducerMessage{}
+	for _, field := range f.cfg.Key {
+		aggregationFields[field](keyFlow, pm)
+	}
+	key := newAggregationKey(keyFlow)
+
+	agg, ok := f.flows[key]
+	if !ok {
+		if len(f.flows) < f.cfg.MaxKeys {
+			agg = f.newAggregatedFlow(keyFlow, false)
+			f.flows[key] = agg
+		} else {
+			// When there are too many keys the flow is counted in the overflow bucket
+			if f.overflow == nil {
+				f.overflow = f.newAggregatedFlow(&protoproducer.ProtoProducerMessage{}, true)
+			}
+			agg = f.overflow
+		}
+	}
+
+	agg.flow.Bytes += pm.Bytes
+	agg.flow.Packets += pm.Packets
+	agg.flows++
+
+	// Flows without a known sampling rate are counted as they are
+	if agg.estimated != nil {
+		rate := max(pm.SamplingRate, 1)
+		agg.estimated.Bytes += pm.Bytes * rate
+		agg.estimated.Packets += pm.Packets * rate
+	}
+}
+
+func (f *flowAggregator) newAggregatedFlow(keyFlow *protoproducer.ProtoProducerMessage, overflow bool) *aggregatedFlowMessage {
+	agg := &aggregatedFlowMessage{flow: keyFlow, key: f.cfg.Key, overflow: overflow}
+	if f.estimate {
+		agg.estimated = &protoproducer.ProtoProducerMessage{}
+	}
+	return agg
+}
+
+// start flushes the aggregated flows every window until stop is called
+func (f *flowAggregator) start() {
+	f.done = make(chan struct{})
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		ticker := time.NewTicker(f.cfg.Window)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-f.done:
+				return
+			case <-ticker.C:
+				f.flush()
+			}
+		}
+	}()
+}
+
+// stop stops flushing and sends the flows of the window that is still open
+func (f *flowAggregator) stop() {
+	if f.done == nil {
+		return
+	}
+	close(f.done)
+	f.wg.Wait()
+	f.done = nil
+	f.flush()
+}
+
+// flush closes the current window and sends its aggregated flows to the output
+func (f *flowAggregator) flush() {
+	f.mu.Lock()
+	flows, overflow, events, windowStart := f.flows, f.overflow, f.events, f.windowStart
+	windowEnd := time.Now()
+	f.flows = make(map[aggregationKey]*aggregatedFlowMessage, len(flows))
+	f.overflow, f.events = nil, nil
+	f.windowStart = windowEnd
+	f.mu.Unlock()
+
+	if overflow != nil {
+		f.logger.Warn("the number of aggregation keys reached the limit, the remaining flows were added to the overflow bucket",
+			zap.Int("max_keys", f.cfg.MaxKeys), zap.Uint64("flows", overflow.flows))
+	}
+
+	flowMessageSet := make([]producer.ProducerMessage, 0, len(flows)+1+len(events))
+	for _, agg := range flows {
+		flowMessageSet = append(flowMessageSet, agg)
+	}
+	if overflow != nil {
+		flowMessageSet = append(flowMessageSet, overflow)
+	}
+	for _, m := range flowMessageSet {
+		agg := m.(*aggregatedFlowMessage)
+		agg.flow.TimeFlowStartNs = uint64(windowStart.UnixNano())
+		agg.flow.TimeFlowEndNs = uint64(windowEnd.UnixNano())
+		agg.flow.TimeReceivedNs = uint64(windowEnd.UnixNano())
+	}
+	flowMessageSet = append(flowMessageSet, events...)
+	if len(flowMessageSet) == 0 || f.output == nil {
+		return
+	}
+
+	produced, err := f.output.Produce(&aggregationFlush{flows: flowMessageSet}, &producer.ProduceArgs{TimeReceived: windowEnd})
+	if err != nil {
+		f.logger.Error("failed to send the aggregated flows", zap.Error(err))
+	}
+	f.output.Commit(produced)
+}
+
+func (f *flowAggregator) Close() {
+	f.wrapped.Close()
+}
+
+func (f *flowAggregator) Commit(flowMessageSet []producer.ProducerMessage) {
+	f.wrapped.Commit(flowMessageSet)
+}
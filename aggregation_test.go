@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/producer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	semconv "go.opentelemetry.io/collector/semconv/v1.27.0"
+	"go.uber.org/zap"
+)
+
+func newTestAggregator(t *testing.T, cfg AggregationConfig, flows []producer.ProducerMessage, enrichers ...flowEnricher) (*flowAggregator, *consumertest.LogsSink) {
+	t.Helper()
+	sink := &consumertest.LogsSink{}
+	aggregator := newFlowAggregator(&staticProducer{messages: flows}, cfg, zap.NewNop())
+	aggregator.output = newOtelLogsProducer(aggregationOutput{}, sink, zap.NewNop(), enrichers...)
+	return aggregator, sink
+}
+
+func TestAggregation(t *testing.T) {
+	flows := []producer.ProducerMessage{
+		newTestFlow("192.168.1.1", 6, 100, 1, 1000, 5000),
+		newTestFlow("192.168.1.2", 6, 200, 2, 500, 6000),
+		newTestFlow("192.168.1.1", 17, 50, 1, 2000, 4000),
+	}
+	aggregator, sink := newTestAggregator(t, AggregationConfig{
+		Key:     []string{semconv.AttributeNetworkTransport},
+		MaxKeys: 10,
+	}, flows)
+
+	// Every packet returns its flows so they are committed back to the proto producer
+	for i := 0; i < 2; i++ {
+		produced, err := aggregator.Produce(nil, &producer.ProduceArgs{})
+		require.NoError(t, err)
+		assert.Len(t, produced, 3)
+	}
+	assert.Empty(t, sink.AllLogs())
+
+	aggregator.flush()
+	require.Len(t, sink.AllLogs(), 1)
+	records := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+	require.Equal(t, 2, records.Len())
+
+	byTransport := map[string]plog.LogRecord{}
+	for i := 0; i < records.Len(); i++ {
+		transport, ok := records.At(i).Attributes().Get(semconv.AttributeNetworkTransport)
+		require.True(t, ok)
+		byTransport[transport.Str()] = records.At(i)
+	}
+
+	tcp := byTransport["tcp"].Attributes()
+	_, hasSource := tcp.Get(semconv.AttributeSourceAddress)
+	assert.False(t, hasSource, "attributes that are not part of the key must be removed")
+	assertIntAttribute(t, tcp, "flow.io.bytes", 600)
+	assertIntAttribute(t, tcp, "flow.io.packets", 6)
+	assertIntAttribute(t, tcp, "flow.count", 4)
+
+	udp := byTransport["udp"].Attributes()
+	assertIntAttribute(t, udp, "flow.io.bytes", 100)
+	assertIntAttribute(t, udp, "flow.count", 2)
+
+	// A window without flows sends nothing
+	aggregator.flush()
+	assert.Len(t, sink.AllLogs(), 1)
+}
+
+func TestAggregationEvents(t *testing.T) {
+	flows := []producer.ProducerMessage{
+		newTestFlow("192.168.1.1", 6, 100, 1, 1000, 5000),
+		newTestNATEvent("192.168.1.2", 1),
+	}
+	aggregator, sink := newTestAggregator(t, AggregationConfig{
+		Key:     []string{semconv.AttributeNetworkTransport},
+		MaxKeys: 10,
+	}, flows)
+	_, err := aggregator.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+
+	// The event is sent as it is with the records of the window, it is not counted as a flow
+	aggregator.flush()
+	records := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+	require.Equal(t, 2, records.Len())
+	assertIntAttribute(t, records.At(0).Attributes(), "flow.count", 1)
+	assert.Equal(t, "nat.nat44_session_create", records.At(1).EventName())
+	assertStrAttribute(t, records.At(1).Attributes(), semconv.AttributeSourceAddress, "192.168.1.2")
+}
+
+func TestAggregationOverflow(t *testing.T) {
+	flows := []producer.ProducerMessage{
+		newTestFlow("192.168.1.1", 6, 100, 1, 1000, 5000),
+		newTestFlow("192.168.1.2", 6, 200, 2, 500, 6000),
+		newTestFlow("192.168.1.3", 6, 50, 1, 2000, 4000),
+	}
+	aggregator, sink := newTestAggregator(t, AggregationConfig{
+		Key:     []string{semconv.AttributeSourceAddress, "flow.interface.in.index"},
+		MaxKeys: 1,
+	}, flows, fieldSets[fieldSetInterfaces])
+
+	_, err := aggregator.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+	aggregator.flush()
+
+	require.Len(t, sink.AllLogs(), 1)
+	records := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+	require.Equal(t, 2, records.Len())
+
+	keyed := records.At(0).Attributes()
+	assertStrAttribute(t, keyed, semconv.AttributeSourceAddress, "192.168.1.1")
+	assertIntAttribute(t, keyed, "flow.interface.in.index", 0)
+	assertIntAttribute(t, keyed, "flow.count", 1)
+
+	overflow := records.At(1).Attributes()
+	_, hasSource := overflow.Get(semconv.AttributeSourceAddress)
+	assert.False(t, hasSource)
+	overflowFlag, ok := overflow.Get("flow.aggregation.overflow")
+	require.True(t, ok)
+	assert.True(t, overflowFlag.Bool())
+	assertIntAttribute(t, overflow, "flow.io.bytes", 250)
+	assertIntAttribute(t, overflow, "flow.count", 2)
+}
+
+func TestAggregationStopFlushes(t *testing.T) {
+	aggregator, sink := newTestAggregator(t, AggregationConfig{
+		Window:  time.Hour,
+		Key:     []string{semconv.AttributeSourceAddress},
+		MaxKeys: 10,
+	}, []producer.ProducerMessage{newTestFlow("192.168.1.1", 6, 100, 1, 1000, 5000)})
+
+	aggregator.start()
+	_, err := aggregator.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+	aggregator.stop()
+
+	require.Len(t, sink.AllLogs(), 1)
+	assert.Equal(t, 1, sink.AllLogs()[0].LogRecordCount())
+}
+
+func TestAggregationCopiesAddresses(t *testing.T) {
+	// goflow2 points the addresses into the packet buffer, that is reused for the next packet
+	buffer := []byte{192, 168, 1, 1, 10, 0, 0, 1, 192, 168, 1, 100}
+	flow := newTestFlow("192.168.1.1", 6, 100, 1, 1000, 5000)
+	flow.SrcAddr, flow.DstAddr, flow.SamplerAddress = buffer[0:4], buffer[4:8], buffer[8:12]
+	aggregator, sink := newTestAggregator(t, AggregationConfig{
+		Key:     []string{semconv.AttributeSourceAddress, semconv.AttributeDestinationAddress, "flow.sampler_address"},
+		MaxKeys: 10,
+	}, []producer.ProducerMessage{flow})
+
+	_, err := aggregator.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+	for i := range buffer {
+		buffer[i] = 0xff
+	}
+	aggregator.flush()
+
+	require.Len(t, sink.AllLogs(), 1)
+	attrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes()
+	assertStrAttribute(t, attrs, semconv.AttributeSourceAddress, "192.168.1.1")
+	assertStrAttribute(t, attrs, semconv.AttributeDestinationAddress, "10.0.0.1")
+	assertStrAttribute(t, attrs, "flow.sampler_address", "192.168.1.100")
+}
@@ -0,0 +1,203 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"strings"
+	"sync"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// applicationIDIndex is the protobuf index where goflow2 stores the application id of a flow
+	applicationIDIndex = 996
+	// applicationIDField is the name of the application id custom field, it cannot be used by the mappings
+	applicationIDField = "applicationId"
+
+	// The elements of the NBAR options data records that describe an application
+	// The Cisco names are enterprise elements in IPFIX and plain fields in NetFlow v9
+	applicationIDType          = 95
+	applicationNameType        = 96
+	applicationDescriptionType = 94
+	applicationCategoryType    = 12232
+)
+
+// applicationAttributes are the attributes added to the flows with an application id
+var applicationAttributes = []string{
+	"flow.application.id",
+	"flow.application.name",
+	"flow.application.description",
+	"flow.application.category",
+}
+
+// applicationKey identifies an application of an exporter
+// The application ids of NBAR are assigned by each device, so the same id can be another application on another exporter
+type applicationKey struct {
+	exporter netip.Addr
+	domain   uint32
+	id       string
+}
+
+// applicationInfo is an application described by an options data record
+type applicationInfo struct {
+	name        string
+	description string
+	category    string
+}
+
+// applicationTable keeps the applications the exporters described in their options data records
+// It is shared by all the listeners, like the exporter tracker
+type applicationTable struct {
+	mu           sync.RWMutex
+	applications map[applicationKey]applicationInfo
+}
+
+func newApplicationTable() *applicationTable {
+	return &applicationTable{applications: make(map[applicationKey]applicationInfo)}
+}
+
+// learn reads the applications of the options data records of a NetFlow v9 or IPFIX packet
+// A record that describes an application again replaces it, the exporters send the whole table every few minutes
+func (t *applicationTable) learn(msg any, exporter netip.Addr) {
+	var domain uint32
+	var flowSets []any
+	switch packet := msg.(type) {
+	case *netflow.NFv9Packet:
+		domain, flowSets = packet.SourceId, packet.FlowSets
+	case *netflow.IPFIXPacket:
+		domain, flowSets = packet.ObservationDomainId, packet.FlowSets
+	default:
+		return
+	}
+
+	for _, flowSet := range flowSets {
+		fs, ok := flowSet.(netflow.OptionsDataFlowSet)
+		if !ok {
+			continue
+		}
+		for _, record := range fs.Records {
+			id, info, ok := readApplicationRecord(record)
+			if !ok {
+				continue
+			}
+			t.mu.Lock()
+			t.applications[applicationKey{exporter: exporter.Unmap(), domain: domain, id: string(id)}] = info
+			t.mu.Unlock()
+		}
+	}
+}
+
+// readApplicationRecord reads an options data record, it is only an application when it has an id and a name
+// The id is a scope in IPFIX and an option in NetFlow v9, where the scope is the whole system
+func readApplicationRecord(record netflow.OptionsDataRecord) ([]byte, applicationInfo, bool) {
+	var id []byte
+	var info applicationInfo
+	for _, fields := range [][]netflow.DataField{record.ScopesValues, record.OptionsValues} {
+		for _, field := range fields {
+			value, ok := field.Value.([]byte)
+			if !ok || (field.PenProvided && field.Pen != penCisco) {
+				continue
+			}
+			// Unlike the data templates, goflow2 keeps the enterprise bit in the types of the options templates
+			fieldType := field.Type
+			if field.PenProvided {
+				fieldType &^= 0x8000
+			}
+			switch {
+			case fieldType == applicationIDType && !field.PenProvided:
+				id = value
+			case fieldType == applicationNameType && !field.PenProvided:
+				info.name = applicationString(value)
+			case fieldType == applicationDescriptionType && !field.PenProvided:
+				info.description = applicationString(value)
+			case fieldType == applicationCategoryType:
+				info.category = applicationString(value)
+			}
+		}
+	}
+	return id, info, len(id) > 0 && info.name != ""
+}
+
+// applicationString removes the zeros that pad the fixed length strings
+func applicationString(value []byte) string {
+	return strings.TrimRight(string(value), "\x00")
+}
+
+// get returns the application of an exporter
+func (t *applicationTable) get(key applicationKey) (applicationInfo, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	info, ok := t.applications[key]
+	return info, ok
+}
+
+// applicationProducer learns the applications of the options data records before the flows are produced
+type applicationProducer struct {
+	wrapped producer.ProducerInterface
+	table   *applicationTable
+}
+
+func (p *applicationProducer) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	p.table.learn(msg, args.SamplerAddress)
+	return p.wrapped.Produce(msg, args)
+}
+
+func (p *applicationProducer) Close() {
+	p.wrapped.Close()
+}
+
+func (p *applicationProducer) Commit(flowMessageSet []producer.ProducerMessage) {
+	p.wrapped.Commit(flowMessageSet)
+}
+
+// applicationEnricher adds the application of the flows with an application id
+// The id is always added, the name, description and category once the exporter described the application
+type applicationEnricher struct {
+	table *applicationTable
+}
+
+func (e applicationEnricher) enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	rangeUnknownFields(pm, func(num protowire.Number, _ uint64, raw []byte) {
+		if num != applicationIDIndex || len(raw) == 0 {
+			return
+		}
+		attrs.PutStr("flow.application.id", formatApplicationID(raw))
+
+		sampler, ok := netip.AddrFromSlice(pm.SamplerAddress)
+		if !ok {
+			return
+		}
+		info, ok := e.table.get(applicationKey{exporter: sampler.Unmap(), domain: pm.ObservationDomainId, id: string(raw)})
+		if !ok {
+			return
+		}
+		attrs.PutStr("flow.application.name", info.name)
+		if info.description != "" {
+			attrs.PutStr("flow.application.description", info.description)
+		}
+		if info.category != "" {
+			attrs.PutStr("flow.application.category", info.category)
+		}
+	})
+}
+
+// formatApplicationID formats an application id as its classification engine and selector, like 3:443 for the HTTPS port
+func formatApplicationID(id []byte) string {
+	engine, selector := id[0], id[1:]
+	if len(selector) > 8 {
+		return fmt.Sprintf("%d:%s", engine, hex.EncodeToString(selector))
+	}
+	padded := make([]byte, 8)
+	copy(padded[8-len(selector):], selector)
+	return fmt.Sprintf("%d:%d", engine, binary.BigEndian.Uint64(padded))
+}
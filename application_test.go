@@ -0,0 +1,127 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// nbarPacket builds an IPFIX packet with a flow of the application 13:80
+// With options, the packet also has the NBAR options data record that names the application
+func nbarPacket(options bool) []byte {
+	set := func(id uint16, content []byte) []byte {
+		s := binary.BigEndian.AppendUint16(nil, id)
+		s = binary.BigEndian.AppendUint16(s, uint16(4+len(content)))
+		return append(s, content...)
+	}
+	padded := func(s string) []byte {
+		b := make([]byte, 16)
+		copy(b, s)
+		return b
+	}
+	id := []byte{13, 0, 0, 80}
+
+	payload := set(2, []byte{
+		1, 0, 0, 2, // template 256 with 2 fields
+		0, 1, 0, 8, // octetDeltaCount
+		0, 95, 0, 4, // applicationId
+	})
+	if options {
+		payload = append(payload, set(3, []byte{
+			1, 1, 0, 4, 0, 1, // options template 257 with 4 fields, 1 scope
+			0, 95, 0, 4, // applicationId
+			0, 96, 0, 16, // applicationName
+			0, 94, 0, 16, // applicationDescription
+			0xaf, 0xc8, 0, 16, // application category name
+			0, 0, 0, 9, // pen 9
+		})...)
+		record := append(append([]byte{}, id...), padded("http")...)
+		record = append(append(record, padded("World Wide Web")...), padded("browsing")...)
+		payload = append(payload, set(257, record)...)
+	}
+	payload = append(payload, set(256, append(binary.BigEndian.AppendUint64(nil, 1500), id...))...)
+
+	header := make([]byte, 16)
+	binary.BigEndian.PutUint16(header[0:], 10)
+	binary.BigEndian.PutUint16(header[2:], uint16(16+len(payload)))
+	binary.BigEndian.PutUint32(header[12:], 1) // observation domain
+	return append(header, payload...)
+}
+
+func TestApplicationNames(t *testing.T) {
+	decode, sink := newTestDefaultDecoder(t)
+
+	require.NoError(t, decode(testMessage(nbarPacket(true))))
+	attrs := firstLogRecord(t, sink.AllLogs()).Attributes()
+	assertIntAttribute(t, attrs, "flow.io.bytes", 1500)
+	assertStrAttribute(t, attrs, "flow.application.id", "13:80")
+	assertStrAttribute(t, attrs, "flow.application.name", "http")
+	assertStrAttribute(t, attrs, "flow.application.description", "World Wide Web")
+	assertStrAttribute(t, attrs, "flow.application.category", "browsing")
+
+	// The ids are device specific, another exporter did not describe the application
+	sink.Reset()
+	msg := testMessage(nbarPacket(false))
+	msg.Src = netip.MustParseAddrPort("127.0.0.2:5000")
+	require.NoError(t, decode(msg))
+	attrs = firstLogRecord(t, sink.AllLogs()).Attributes()
+	assertStrAttribute(t, attrs, "flow.application.id", "13:80")
+	_, ok := attrs.Get("flow.application.name")
+	assert.False(t, ok)
+}
+
+func TestApplicationTableNetFlowV9(t *testing.T) {
+	table := newApplicationTable()
+	exporter := netip.MustParseAddr("192.0.2.1")
+	table.learn(&netflow.NFv9Packet{
+		SourceId: 7,
+		FlowSets: []any{netflow.OptionsDataFlowSet{Records: []netflow.OptionsDataRecord{
+			{
+				// NetFlow v9 exporters send the id as an option of the system scope
+				ScopesValues: []netflow.DataField{{Type: 1, Value: []byte{0, 0, 0, 1}}},
+				OptionsValues: []netflow.DataField{
+					{Type: applicationIDType, Value: []byte{3, 0, 0, 53}},
+					{Type: applicationNameType, Value: []byte("dns\x00\x00")},
+					{Type: applicationCategoryType, Value: []byte("net-admin")},
+				},
+			},
+			{
+				// A record without a name is not an application
+				OptionsValues: []netflow.DataField{{Type: applicationIDType, Value: []byte{3, 0, 0, 22}}},
+			},
+		}}},
+	}, netip.AddrFrom16(exporter.As16()))
+
+	info, ok := table.get(applicationKey{exporter: exporter, domain: 7, id: string([]byte{3, 0, 0, 53})})
+	require.True(t, ok)
+	assert.Equal(t, applicationInfo{name: "dns", category: "net-admin"}, info)
+	_, ok = table.get(applicationKey{exporter: exporter, domain: 7, id: string([]byte{3, 0, 0, 22})})
+	assert.False(t, ok)
+	_, ok = table.get(applicationKey{exporter: exporter, domain: 8, id: string([]byte{3, 0, 0, 53})})
+	assert.False(t, ok)
+}
+
+func TestFormatApplicationID(t *testing.T) {
+	assert.Equal(t, "3:443", formatApplicationID([]byte{3, 0x01, 0xbb}))
+	assert.Equal(t, "13:0", formatApplicationID([]byte{13}))
+	assert.Equal(t, "20:0102030405060708090a", formatApplicationID([]byte{20, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}))
+}
+
+func TestMappingApplicationIDReserved(t *testing.T) {
+	mapping := MappingConfig{
+		IPFIX: []NetFlowFieldMapping{
+			{Field: 95, FieldDestination: FieldDestination{Destination: "applicationId", Type: customFieldTypeBytes}},
+		},
+	}
+	assert.EqualError(t, mapping.Validate(), `mapping destination "applicationId" is reserved`)
+
+	mapping.IPFIX[0].Destination = "flow.application.name"
+	assert.EqualError(t, mapping.Validate(), `mapping destination "flow.application.name" is a flow attribute`)
+}
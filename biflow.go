@@ -0,0 +1,112 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// reversePen is the private enterprise number of the reverse information elements of RFC 5103
+	// A reverse element has the type of the forward element it mirrors
+	reversePen = 29305
+
+	// reverseFirstIndex is the protobuf index where goflow2 stores the first reverse information element
+	// The indexes up to sequenceLostIndex are reserved for them
+	reverseFirstIndex = 980
+)
+
+// reverseField is a reverse information element of an IPFIX biflow and the attribute it is added as
+type reverseField struct {
+	// name is the name of the element in the IANA registry, it is the name of the custom field in goflow2
+	name string
+	// ieType is the type of the forward element
+	ieType uint16
+	attr   string
+	// scale converts the value to the unit of the forward attribute, the timestamps are in nanoseconds
+	scale uint64
+}
+
+// reverseFields are the reverse elements added to the attributes of IPFIX biflows
+// The attributes mirror the attributes of the forward direction under flow.reverse
+var reverseFields = []reverseField{
+	{name: "reverseOctetDeltaCount", ieType: 1, attr: "flow.reverse.io.bytes", scale: 1},
+	{name: "reversePacketDeltaCount", ieType: 2, attr: "flow.reverse.io.packets", scale: 1},
+	{name: "reverseOctetTotalCount", ieType: 85, attr: "flow.reverse.io.bytes", scale: 1},
+	{name: "reversePacketTotalCount", ieType: 86, attr: "flow.reverse.io.packets", scale: 1},
+	{name: "reverseIpClassOfService", ieType: 5, attr: "flow.reverse.ip.tos", scale: 1},
+	{name: "reverseTcpControlBits", ieType: 6, attr: "flow.reverse.tcp.flags", scale: 1},
+	{name: "reverseFlowStartSeconds", ieType: 150, attr: "flow.reverse.start", scale: 1e9},
+	{name: "reverseFlowEndSeconds", ieType: 151, attr: "flow.reverse.end", scale: 1e9},
+	{name: "reverseFlowStartMilliseconds", ieType: 152, attr: "flow.reverse.start", scale: 1e6},
+	{name: "reverseFlowEndMilliseconds", ieType: 153, attr: "flow.reverse.end", scale: 1e6},
+}
+
+// reverseFieldsByIndex are the reverse elements by the protobuf index goflow2 stores them under
+var reverseFieldsByIndex = func() map[protowire.Number]reverseField {
+	fields := make(map[protowire.Number]reverseField, len(reverseFields))
+	for i, field := range reverseFields {
+		fields[protowire.Number(reverseFirstIndex+i)] = field
+	}
+	return fields
+}()
+
+// reverseFieldAttributes returns the attributes the reverse elements can add
+func reverseFieldAttributes() []string {
+	attrs := make([]string, 0, len(reverseFields))
+	for _, field := range reverseFields {
+		attrs = append(attrs, field.attr)
+	}
+	return attrs
+}
+
+// isReverseFieldName returns true if the name is the custom field of a reverse element, it cannot be used by the mappings
+func isReverseFieldName(name string) bool {
+	for _, field := range reverseFields {
+		if field.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reverseFormatter declares the custom fields of the reverse elements so goflow2 stores them
+func reverseFormatter() []protoproducer.ProtobufFormatterConfig {
+	formatter := make([]protoproducer.ProtobufFormatterConfig, 0, len(reverseFields))
+	for i, field := range reverseFields {
+		formatter = append(formatter, protoproducer.ProtobufFormatterConfig{
+			Name:  field.name,
+			Index: int32(reverseFirstIndex + i),
+			Type:  customFieldTypeVarint,
+		})
+	}
+	return formatter
+}
+
+// reverseMapping maps the reverse elements to their custom fields
+func reverseMapping() []protoproducer.NetFlowMapField {
+	mapping := make([]protoproducer.NetFlowMapField, 0, len(reverseFields))
+	for _, field := range reverseFields {
+		mapping = append(mapping, protoproducer.NetFlowMapField{
+			PenProvided: true,
+			Type:        field.ieType,
+			Pen:         reversePen,
+			Destination: field.name,
+		})
+	}
+	return mapping
+}
+
+// biflowEnricher adds the reverse direction of IPFIX biflows, only the elements the exporter sent are added
+type biflowEnricher struct{}
+
+func (biflowEnricher) enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	rangeUnknownFields(pm, func(num protowire.Number, value uint64, _ []byte) {
+		if field, ok := reverseFieldsByIndex[num]; ok {
+			attrs.PutInt(field.attr, int64(value*field.scale))
+		}
+	})
+}
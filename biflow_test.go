@@ -0,0 +1,105 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/netsampler/goflow2/v2/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+)
+
+// ipfixBiflowPacket builds an IPFIX packet with an RFC 5103 biflow record
+// The record has the octet and packet counts of both directions, the reverse TCP flags and the reverse start
+func ipfixBiflowPacket() []byte {
+	template := []byte{
+		0, 2, 0, 0, // template set, length
+		1, 0, 0, 6, // template 256 with 6 fields
+		0, 1, 0, 8, // octetDeltaCount
+		0, 2, 0, 8, // packetDeltaCount
+		0x80, 1, 0, 8, 0, 0, 0x72, 0x79, // reverseOctetDeltaCount
+		0x80, 2, 0, 4, 0, 0, 0x72, 0x79, // reversePacketDeltaCount, with reduced size encoding
+		0x80, 6, 0, 1, 0, 0, 0x72, 0x79, // reverseTcpControlBits
+		0x80, 152, 0, 8, 0, 0, 0x72, 0x79, // reverseFlowStartMilliseconds
+	}
+	binary.BigEndian.PutUint16(template[2:], uint16(len(template)))
+
+	data := []byte{1, 0, 0, 0}
+	data = binary.BigEndian.AppendUint64(data, 1500)
+	data = binary.BigEndian.AppendUint64(data, 3)
+	data = binary.BigEndian.AppendUint64(data, 64000)
+	data = binary.BigEndian.AppendUint32(data, 45)
+	data = append(data, 0x12)
+	data = binary.BigEndian.AppendUint64(data, 1700000000123)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)))
+
+	header := make([]byte, 16)
+	binary.BigEndian.PutUint16(header[0:], 10)
+	binary.BigEndian.PutUint16(header[2:], uint16(16+len(template)+len(data)))
+	binary.BigEndian.PutUint32(header[12:], 1) // observation domain
+
+	return append(append(header, template...), data...)
+}
+
+// newTestDefaultDecoder creates the decode function of a listener with the default configuration
+func newTestDefaultDecoder(t *testing.T) (utils.DecoderFunc, *consumertest.LogsSink) {
+	t.Helper()
+	nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *createDefaultConfig().(*Config))
+	require.NoError(t, err)
+	sink := &consumertest.LogsSink{}
+	nr.logConsumer = sink
+	// The enrichers are built when the receiver starts
+	nr.enrichers = nr.buildEnrichers()
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+	return decode, sink
+}
+
+func TestBiflowReverseAttributes(t *testing.T) {
+	decode, sink := newTestDefaultDecoder(t)
+
+	require.NoError(t, decode(testMessage(ipfixBiflowPacket())))
+	require.Equal(t, 1, sink.LogRecordCount())
+	attrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes()
+	assertIntAttribute(t, attrs, "flow.io.bytes", 1500)
+	assertIntAttribute(t, attrs, "flow.io.packets", 3)
+	assertIntAttribute(t, attrs, "flow.reverse.io.bytes", 64000)
+	assertIntAttribute(t, attrs, "flow.reverse.io.packets", 45)
+	assertIntAttribute(t, attrs, "flow.reverse.tcp.flags", 0x12)
+	assertIntAttribute(t, attrs, "flow.reverse.start", 1700000000123000000)
+	_, ok := attrs.Get("flow.reverse.end")
+	assert.False(t, ok, "the elements the exporter did not send are not added")
+}
+
+func TestBiflowReverseWithoutBiflow(t *testing.T) {
+	decode, sink := newTestDefaultDecoder(t)
+
+	require.NoError(t, decode(testMessage(ipfixPacket())))
+	require.Equal(t, 1, sink.LogRecordCount())
+	sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().Range(func(k string, _ pcommon.Value) bool {
+		assert.NotContains(t, k, "flow.reverse.")
+		return true
+	})
+}
+
+func TestMappingReverseFieldReserved(t *testing.T) {
+	mapping := MappingConfig{
+		IPFIX: []NetFlowFieldMapping{
+			{Field: 1, Pen: reversePen, FieldDestination: FieldDestination{Destination: "reverseOctetDeltaCount"}},
+		},
+	}
+	assert.EqualError(t, mapping.Validate(), `mapping destination "reverseOctetDeltaCount" is reserved`)
+
+	mapping.IPFIX[0].Destination = "flow.reverse.io.bytes"
+	assert.EqualError(t, mapping.Validate(), `mapping destination "flow.reverse.io.bytes" is a flow attribute`)
+
+	// A mapping of a reverse element replaces the built-in one
+	mapping.IPFIX[0].Destination = "reverse.bytes"
+	assert.NoError(t, mapping.Validate())
+}
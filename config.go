@@ -3,10 +3,234 @@
 
 package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/collector/config/configopaque"
+	"go.opentelemetry.io/collector/confmap"
+)
 
 // Config represents the receiver config settings within the collector's config.yaml
 type Config struct {
+	// The listeners that this receiver will start, all of them feed the same pipelines
+	// This allows a single receiver to listen for netflow, ipfix and sflow on different ports
+	Listeners []ListenerConfig `mapstructure:"listeners"`
+
+	// Deprecated: the settings of the single listener of the first versions of the receiver, use listeners instead
+	// When listeners is not set, they configure the only listener and the omitted ones keep the listener defaults
+	Scheme    string `mapstructure:"scheme"`
+	Hostname  string `mapstructure:"hostname"`
+	Port      int    `mapstructure:"port"`
+	Sockets   int    `mapstructure:"sockets"`
+	Workers   int    `mapstructure:"workers"`
+	QueueSize int    `mapstructure:"queue_size"`
+
+	// Metrics configures how flows are converted when the receiver is part of a metrics pipeline
+	Metrics MetricsConfig `mapstructure:"metrics"`
+
+	// Aggregation groups flows over a window before they are converted into logs and metrics
+	Aggregation AggregationConfig `mapstructure:"aggregation"`
+
+	// Stitching pairs the flows of both directions of a conversation into a single flow
+	Stitching StitchingConfig `mapstructure:"stitching"`
+
+	// Sampling configures how sampled bytes and packets are turned into estimated totals
+	Sampling SamplingConfig `mapstructure:"sampling"`
+
+	// Mapping decodes additional NetFlow v9, IPFIX and sFlow fields with goflow2
+	Mapping MappingConfig `mapstructure:"mapping"`
+
+	// Attributes selects the optional flow attributes that are extracted
+	Attributes AttributesConfig `mapstructure:"attributes"`
+
+	// GeoIP adds the location and the autonomous system of the addresses from MaxMind databases
+	GeoIP GeoIPConfig `mapstructure:"geoip"`
+
+	// Networks names the networks of the addresses from a list of prefixes
+	Networks NetworksConfig `mapstructure:"networks"`
+
+	// SNMP resolves the names of the interfaces by polling the exporters
+	SNMP SNMPConfig `mapstructure:"snmp"`
+
+	// Interfaces names the interfaces of the exporters from a file
+	Interfaces InterfacesConfig `mapstructure:"interfaces"`
+
+	// Exporters configures how the devices sending flows are tracked
+	Exporters ExportersConfig `mapstructure:"exporters"`
+
+	// Templates persists the NetFlow v9 and IPFIX templates so the flows can be decoded right after a restart,
+	// and buffers the flows that arrive before their template
+	Templates TemplatesConfig `mapstructure:"templates"`
+
+	// Debug serves the state of the receiver over HTTP, to troubleshoot the exporters
+	Debug DebugConfig `mapstructure:"debug"`
+}
+
+// DebugConfig represents the settings of the debug endpoint
+type DebugConfig struct {
+	// The address the debug endpoint listens on, it is disabled when it is empty
+	// It has no authentication, so it must listen on a loopback address unless AllowRemote is set
+	Endpoint string `mapstructure:"endpoint"`
+
+	// Whether the endpoint can listen on an address other hosts can reach
+	AllowRemote bool `mapstructure:"allow_remote"`
+}
+
+// TemplatesConfig represents the file the NetFlow v9 and IPFIX templates are persisted to
+type TemplatesConfig struct {
+	// The path to the file the templates are saved to and loaded from, they are not persisted when it is empty
+	File string `mapstructure:"file"`
+
+	// How often the templates are saved, they are only saved when the receiver stops when it is zero
+	SaveInterval time.Duration `mapstructure:"save_interval"`
+
+	// How long a template is kept after the exporter last sent it, it is kept forever when it is zero
+	MaxAge time.Duration `mapstructure:"max_age"`
+
+	// Buffer holds the packets whose template is not known yet, until the exporter sends it
+	Buffer TemplateBufferConfig `mapstructure:"buffer"`
+}
+
+// TemplateBufferConfig represents the limits of the packets buffered until their template arrives
+type TemplateBufferConfig struct {
+	// The most packets each listener buffers, packets are not buffered when it is zero
+	MaxPackets int `mapstructure:"max_packets"`
+
+	// How long a packet is buffered before it is dropped
+	MaxAge time.Duration `mapstructure:"max_age"`
+}
+
+// ExportersConfig represents the settings used to track the devices sending flows
+type ExportersConfig struct {
+	// How long an exporter can go without sending flows before it is logged as silent, it is never logged when it is zero
+	InactivityTimeout time.Duration `mapstructure:"inactivity_timeout"`
+
+	// Whether the first flow of the packet that counts a gap in the sequence numbers of an exporter has the number of packets or flows lost
+	AnnotateSequenceGaps bool `mapstructure:"annotate_sequence_gaps"`
+}
+
+// InterfacesConfig represents the file used to name the interfaces of the exporters
+type InterfacesConfig struct {
+	// The path to a CSV or YAML file with the name, description and speed of the interfaces of each exporter
+	File string `mapstructure:"file"`
+
+	// How often the file is checked for changes, it is never reloaded when it is zero
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+
+	// The name of the interfaces that are not in the file, they are not named when it is empty
+	DefaultName string `mapstructure:"default_name"`
+}
+
+// SNMPConfig represents the settings used to read the interfaces of the exporters with SNMP
+type SNMPConfig struct {
+	// The SNMP version, v2c or v3, the interfaces are not resolved when it is empty
+	Version string `mapstructure:"version"`
+
+	// The port the exporters answer SNMP requests on, it can point to a local simulator
+	Port uint16 `mapstructure:"port"`
+
+	// The community used with v2c
+	Community configopaque.String `mapstructure:"community"`
+
+	// The user and security level used with v3
+	User         string              `mapstructure:"user"`
+	AuthProtocol string              `mapstructure:"auth_protocol"`
+	AuthPassword configopaque.String `mapstructure:"auth_password"`
+	PrivProtocol string              `mapstructure:"priv_protocol"`
+	PrivPassword configopaque.String `mapstructure:"priv_password"`
+
+	// How long to wait for an answer of the exporter
+	Timeout time.Duration `mapstructure:"timeout"`
+
+	// How long the interfaces of an exporter are kept before they are polled again
+	CacheTTL time.Duration `mapstructure:"cache_ttl"`
+}
+
+// NetworksConfig represents the prefixes used to name the networks of the addresses
+type NetworksConfig struct {
+	// Prefixes maps a CIDR prefix to the name of the network, the longest prefix that contains an address is used
+	Prefixes map[string]string `mapstructure:"prefixes"`
+
+	// The path to a CSV or YAML file with more prefixes, they replace the same prefixes of the config
+	File string `mapstructure:"file"`
+
+	// How often the file is checked for changes, it is never reloaded when it is zero
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+// GeoIPConfig represents the settings used to look up the addresses in MaxMind databases
+type GeoIPConfig struct {
+	// The path to a GeoLite2-City or compatible database, the location attributes are added when it is set
+	CityDatabase string `mapstructure:"city_database"`
+
+	// The path to a GeoLite2-ASN or compatible database, the autonomous system attributes are added when it is set
+	ASNDatabase string `mapstructure:"asn_database"`
+
+	// How often the databases are checked for changes, they are never reloaded when it is zero
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+// AttributesConfig represents the settings used to select the flow attributes
+type AttributesConfig struct {
+	// The optional groups of attributes that are added on top of the basic ones
+	// One of interfaces, ip, transport, routing, layer2, mpls or headers
+	FieldSets []string `mapstructure:"field_sets"`
+}
+
+// MetricsConfig represents the settings used to convert flows into metrics
+type MetricsConfig struct {
+	// The flow attributes that are added as dimensions to every data point
+	// Flows with the same values for these attributes are added together
+	Dimensions []string `mapstructure:"dimensions"`
+
+	// Whether the counter samples of sFlow agents are converted into interface, CPU and memory metrics
+	SFlowCounters bool `mapstructure:"sflow_counters"`
+}
+
+// AggregationConfig represents the settings used to aggregate flows
+type AggregationConfig struct {
+	// The duration of the aggregation window, aggregation is disabled when it is zero
+	// When the window closes, one record is sent for each key with the sum of bytes, packets and flows
+	Window time.Duration `mapstructure:"window"`
+
+	// The flow attributes used to group flows
+	Key []string `mapstructure:"key"`
+
+	// The maximum number of keys kept in a window, this bounds the memory used by the aggregation
+	// Flows with new keys after the limit is reached are added to a single overflow bucket
+	MaxKeys int `mapstructure:"max_keys"`
+}
+
+// StitchingConfig represents the settings used to pair the flows of both directions of a conversation
+type StitchingConfig struct {
+	// How long a flow waits for the flow of the other direction, stitching is disabled when it is zero
+	// The flows without a pair are sent as they are once the window expires
+	Window time.Duration `mapstructure:"window"`
+
+	// The maximum number of flows waiting for their pair, this bounds the memory used by the stitching
+	// Flows that arrive after the limit is reached are sent without waiting
+	MaxFlows int `mapstructure:"max_flows"`
+}
+
+// SamplingConfig represents the settings used to account for the sampling rate of the devices
+type SamplingConfig struct {
+	// How bytes and packets are scaled by the sampling rate, one of none, add or replace
+	// add keeps the sampled values and adds the estimated totals as separate attributes
+	// replace sets bytes and packets to the estimated totals
+	Scaling string `mapstructure:"scaling"`
+
+	// The sampling rate used for flows when the device did not send one
+	// This is useful for devices that never send the sampling rate, zero means the rate stays unknown
+	DefaultRate uint64 `mapstructure:"default_rate"`
+}
+
+// ListenerConfig represents the settings of a single UDP listener
+type ListenerConfig struct {
 	// The scheme defines the type of flow data that the listener will receive
 	// The scheme must be one of sflow, netflow, or flow
 	Scheme string `mapstructure:"scheme"`
@@ -31,35 +255,377 @@ type Config struct {
 }
 
 // Validate checks if the receiver configuration is valid
+// The individual listeners are validated by ListenerConfig.Validate
 func (cfg *Config) Validate() error {
-	validSchemes := [2]string{"sflow", "netflow"}
+	if len(cfg.Listeners) == 0 {
+		return fmt.Errorf("at least one listener must be configured")
+	}
+
+	// Two listeners on the same address would silently share the port because
+	// goflow2 binds the sockets with SO_REUSEPORT, so we reject it here
+	endpoints := make(map[string]struct{}, len(cfg.Listeners))
+	for _, listener := range cfg.Listeners {
+		address := listener.address()
+		if _, ok := endpoints[address]; ok {
+			return fmt.Errorf("more than one listener is configured for %s", address)
+		}
+		endpoints[address] = struct{}{}
+	}
+
+	// The metrics dimensions and the aggregation key can use optional attributes only if their field set is enabled
+	for _, dimension := range cfg.Metrics.Dimensions {
+		if err := cfg.Attributes.requireAttribute(dimension); err != nil {
+			return fmt.Errorf("metrics dimension %q %w", dimension, err)
+		}
+	}
+	// Both hold the flows for a window, an aggregated flow has no direction to stitch
+	if cfg.Aggregation.enabled() && cfg.Stitching.enabled() {
+		return fmt.Errorf("aggregation and stitching cannot be enabled together")
+	}
+
+	if cfg.Aggregation.enabled() {
+		for _, field := range cfg.Aggregation.Key {
+			if err := cfg.Attributes.requireAttribute(field); err != nil {
+				return fmt.Errorf("aggregation key field %q %w", field, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Validate checks if the listener configuration is valid
+func (lc *ListenerConfig) Validate() error {
+	validSchemes := [3]string{"sflow", "netflow", "flow"}
 
 	validScheme := false
 	for _, scheme := range validSchemes {
-		if cfg.Scheme == scheme {
+		if lc.Scheme == scheme {
 			validScheme = true
 			break
 		}
 	}
 	if !validScheme {
-		return fmt.Errorf("scheme must be netflow or sflow")
+		return fmt.Errorf("scheme must be netflow, sflow or flow")
 	}
 
-	if cfg.Sockets <= 0 {
+	if lc.Sockets <= 0 {
 		return fmt.Errorf("sockets must be greater than 0")
 	}
 
-	if cfg.Workers <= 0 {
+	if lc.Workers <= 0 {
 		return fmt.Errorf("workers must be greater than 0")
 	}
 
-	if cfg.QueueSize <= 0 {
-		cfg.QueueSize = defaultQueueSize
+	if lc.QueueSize <= 0 {
+		lc.QueueSize = defaultQueueSize
 	}
 
-	if cfg.Port <= 0 {
+	if lc.Port <= 0 {
 		return fmt.Errorf("port must be greater than 0")
 	}
 
 	return nil
 }
+
+// Validate checks if the metrics configuration is valid
+func (mc *MetricsConfig) Validate() error {
+	// The dimensions must be attributes that we extract from a flow
+	attrs := allFlowAttributes()
+
+	for _, dimension := range mc.Dimensions {
+		if _, ok := attrs.Get(dimension); !ok {
+			return fmt.Errorf("metrics dimension %q is not a flow attribute", dimension)
+		}
+	}
+
+	return nil
+}
+
+// Validate checks if the aggregation configuration is valid
+func (ac *AggregationConfig) Validate() error {
+	if ac.Window < 0 {
+		return fmt.Errorf("aggregation window must not be negative")
+	}
+
+	if !ac.enabled() {
+		return nil
+	}
+
+	if len(ac.Key) == 0 {
+		return fmt.Errorf("aggregation key must have at least one field")
+	}
+
+	for _, field := range ac.Key {
+		if _, ok := aggregationFields[field]; !ok {
+			return fmt.Errorf("aggregation key field %q is not supported", field)
+		}
+	}
+
+	if ac.MaxKeys <= 0 {
+		return fmt.Errorf("aggregation max_keys must be greater than 0")
+	}
+
+	return nil
+}
+
+// enabled returns true if flows should be aggregated
+func (ac *AggregationConfig) enabled() bool {
+	return ac.Window > 0
+}
+
+// Validate checks if the stitching configuration is valid
+func (sc *StitchingConfig) Validate() error {
+	if sc.Window < 0 {
+		return fmt.Errorf("stitching window must not be negative")
+	}
+	if sc.enabled() && sc.MaxFlows <= 0 {
+		return fmt.Errorf("stitching max_flows must be greater than 0")
+	}
+	return nil
+}
+
+// enabled returns true if the flows of both directions should be paired
+func (sc *StitchingConfig) enabled() bool {
+	return sc.Window > 0
+}
+
+// Validate checks if the sampling configuration is valid
+func (sc *SamplingConfig) Validate() error {
+	switch sc.Scaling {
+	case samplingScalingNone, samplingScalingAdd, samplingScalingReplace:
+		return nil
+	default:
+		return fmt.Errorf("sampling scaling must be none, add or replace")
+	}
+}
+
+// Validate checks if the attributes configuration is valid
+func (ac *AttributesConfig) Validate() error {
+	for _, name := range ac.FieldSets {
+		if _, ok := fieldSets[name]; !ok {
+			return fmt.Errorf("attributes field set %q is not supported", name)
+		}
+	}
+	return nil
+}
+
+// requireAttribute returns an error if the attribute belongs to a field set that is not enabled
+func (ac *AttributesConfig) requireAttribute(attribute string) error {
+	name, ok := fieldSetOf(attribute)
+	if !ok || slices.Contains(ac.FieldSets, name) {
+		return nil
+	}
+	return fmt.Errorf("requires the %s field set", name)
+}
+
+// Validate checks if the geoip configuration is valid
+func (gc *GeoIPConfig) Validate() error {
+	if gc.ReloadInterval < 0 {
+		return fmt.Errorf("geoip reload_interval must not be negative")
+	}
+	return nil
+}
+
+// enabled returns true if at least one database is configured
+func (gc *GeoIPConfig) enabled() bool {
+	return gc.CityDatabase != "" || gc.ASNDatabase != ""
+}
+
+// Validate checks if the networks configuration is valid
+func (nc *NetworksConfig) Validate() error {
+	for p, name := range nc.Prefixes {
+		if _, err := netip.ParsePrefix(p); err != nil {
+			return fmt.Errorf("networks prefix %q is not valid: %w", p, err)
+		}
+		if name == "" {
+			return fmt.Errorf("networks prefix %q must have a name", p)
+		}
+	}
+	if nc.ReloadInterval < 0 {
+		return fmt.Errorf("networks reload_interval must not be negative")
+	}
+	return nil
+}
+
+// enabled returns true if there are prefixes to look up
+func (nc *NetworksConfig) enabled() bool {
+	return len(nc.Prefixes) > 0 || nc.File != ""
+}
+
+// Validate checks if the snmp configuration is valid
+func (sc *SNMPConfig) Validate() error {
+	switch sc.Version {
+	case "":
+		return nil
+	case snmpVersion2c:
+		if sc.Community == "" {
+			return fmt.Errorf("snmp community must be set with version %s", snmpVersion2c)
+		}
+	case snmpVersion3:
+		if sc.User == "" {
+			return fmt.Errorf("snmp user must be set with version %s", snmpVersion3)
+		}
+		if _, ok := snmpAuthProtocols[sc.AuthProtocol]; !ok {
+			return fmt.Errorf("snmp auth_protocol %q is not supported", sc.AuthProtocol)
+		}
+		if _, ok := snmpPrivProtocols[sc.PrivProtocol]; !ok {
+			return fmt.Errorf("snmp priv_protocol %q is not supported", sc.PrivProtocol)
+		}
+		if sc.PrivProtocol != "" && sc.AuthProtocol == "" {
+			return fmt.Errorf("snmp priv_protocol requires an auth_protocol")
+		}
+	default:
+		return fmt.Errorf("snmp version %q is not supported, must be %s or %s", sc.Version, snmpVersion2c, snmpVersion3)
+	}
+	if sc.Port == 0 {
+		return fmt.Errorf("snmp port must be greater than 0")
+	}
+	if sc.Timeout <= 0 {
+		return fmt.Errorf("snmp timeout must be greater than 0")
+	}
+	if sc.CacheTTL <= 0 {
+		return fmt.Errorf("snmp cache_ttl must be greater than 0")
+	}
+	return nil
+}
+
+// enabled returns true if the interfaces are resolved with SNMP
+func (sc *SNMPConfig) enabled() bool {
+	return sc.Version != ""
+}
+
+// Validate checks if the interfaces configuration is valid
+func (ic *InterfacesConfig) Validate() error {
+	if ic.ReloadInterval < 0 {
+		return fmt.Errorf("interfaces reload_interval must not be negative")
+	}
+	if ic.DefaultName != "" && ic.File == "" {
+		return fmt.Errorf("interfaces default_name requires a file")
+	}
+	return nil
+}
+
+// enabled returns true if the interfaces are named from a file
+func (ic *InterfacesConfig) enabled() bool {
+	return ic.File != ""
+}
+
+// Validate checks if the exporters configuration is valid
+func (ec *ExportersConfig) Validate() error {
+	if ec.InactivityTimeout < 0 {
+		return fmt.Errorf("exporters inactivity_timeout must not be negative")
+	}
+	return nil
+}
+
+// Validate checks if the debug configuration is valid
+func (dc *DebugConfig) Validate() error {
+	if !dc.enabled() {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(dc.Endpoint)
+	if err != nil {
+		return fmt.Errorf("debug endpoint %q is not valid: %w", dc.Endpoint, err)
+	}
+	if !dc.AllowRemote && !isLoopback(host) {
+		return fmt.Errorf("debug endpoint %q is not a loopback address, set allow_remote to expose it", dc.Endpoint)
+	}
+	return nil
+}
+
+// isLoopback returns whether a host only accepts connections from the local machine, an empty host listens on every interface
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	addr, err := netip.ParseAddr(host)
+	return err == nil && addr.IsLoopback()
+}
+
+// enabled returns whether the debug endpoint is started
+func (dc *DebugConfig) enabled() bool {
+	return dc.Endpoint != ""
+}
+
+// Validate checks if the templates configuration is valid
+func (tc *TemplatesConfig) Validate() error {
+	if tc.SaveInterval < 0 {
+		return fmt.Errorf("templates save_interval must not be negative")
+	}
+	if tc.MaxAge < 0 {
+		return fmt.Errorf("templates max_age must not be negative")
+	}
+	return nil
+}
+
+// Validate checks if the template buffer configuration is valid
+func (bc *TemplateBufferConfig) Validate() error {
+	if bc.MaxPackets < 0 {
+		return fmt.Errorf("templates buffer max_packets must not be negative")
+	}
+	if bc.enabled() && bc.MaxAge <= 0 {
+		return fmt.Errorf("templates buffer max_age must be positive")
+	}
+	return nil
+}
+
+// enabled returns whether the packets are buffered
+func (bc *TemplateBufferConfig) enabled() bool {
+	return bc.MaxPackets > 0
+}
+
+// enabled returns whether the templates are persisted
+func (tc *TemplatesConfig) enabled() bool {
+	return tc.File != ""
+}
+
+// Unmarshal maps the deprecated listener settings at the top level into the only listener
+func (cfg *Config) Unmarshal(conf *confmap.Conf) error {
+	if err := conf.Unmarshal(cfg); err != nil {
+		return err
+	}
+	if !cfg.hasDeprecatedListener() {
+		return nil
+	}
+	if conf.IsSet("listeners") {
+		return fmt.Errorf("the deprecated listener settings at the top level cannot be used with listeners")
+	}
+
+	listener := defaultListenerConfig()
+	if cfg.Scheme != "" {
+		listener.Scheme = cfg.Scheme
+	}
+	listener.Hostname = cfg.Hostname
+	if cfg.Port != 0 {
+		listener.Port = cfg.Port
+	}
+	if cfg.Sockets != 0 {
+		listener.Sockets = cfg.Sockets
+	}
+	if cfg.Workers != 0 {
+		listener.Workers = cfg.Workers
+	}
+	if cfg.QueueSize != 0 {
+		listener.QueueSize = cfg.QueueSize
+	}
+	cfg.Listeners = []ListenerConfig{listener}
+	return nil
+}
+
+// hasDeprecatedListener returns whether any of the deprecated listener settings is set
+func (cfg *Config) hasDeprecatedListener() bool {
+	return cfg.Scheme != "" || cfg.Hostname != "" || cfg.Port != 0 || cfg.Sockets != 0 || cfg.Workers != 0 || cfg.QueueSize != 0
+}
+
+// Unmarshal starts every listener from the default listener settings
+// Without this, fields omitted in a list entry would be left at their zero value
+func (lc *ListenerConfig) Unmarshal(conf *confmap.Conf) error {
+	*lc = defaultListenerConfig()
+	return conf.Unmarshal(lc)
+}
+
+// address returns the address the listener binds to, it is also used to identify the listener
+func (lc *ListenerConfig) address() string {
+	return net.JoinHostPort(lc.Hostname, strconv.Itoa(lc.Port))
+}
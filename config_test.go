@@ -6,6 +6,7 @@ package netflowreceiver
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -31,33 +32,265 @@ func TestLoadConfig(t *testing.T) {
 		},
 		{
 			id: component.NewIDWithName(metadata.Type, "one_listener"),
-			expected: &Config{
-				Scheme:    "netflow",
-				Port:      2055,
-				Sockets:   1,
-				Workers:   1,
-				QueueSize: 1000,
-			},
+			expected: withListeners(
+				ListenerConfig{
+					Scheme:    "netflow",
+					Port:      2055,
+					Sockets:   1,
+					Workers:   1,
+					QueueSize: 1000,
+				},
+			),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "multiple_listeners"),
+			expected: withListeners(
+				ListenerConfig{
+					Scheme:    "netflow",
+					Port:      2055,
+					Sockets:   1,
+					Workers:   2,
+					QueueSize: 1000,
+				},
+				ListenerConfig{
+					Scheme:    "netflow",
+					Port:      4739,
+					Sockets:   2,
+					Workers:   4,
+					QueueSize: 1000,
+				},
+				ListenerConfig{
+					Scheme:    "sflow",
+					Hostname:  "127.0.0.1",
+					Port:      6343,
+					Sockets:   1,
+					Workers:   2,
+					QueueSize: 500,
+				},
+			),
 		},
 		{
 			id: component.NewIDWithName(metadata.Type, "zero_queue"),
-			expected: &Config{
-				Scheme:    "netflow",
-				Port:      2055,
-				Sockets:   1,
-				Workers:   1,
-				QueueSize: 1000,
-			},
+			expected: withListeners(
+				ListenerConfig{
+					Scheme:    "netflow",
+					Port:      2055,
+					Sockets:   1,
+					Workers:   1,
+					QueueSize: 1000,
+				},
+			),
 		},
 		{
 			id: component.NewIDWithName(metadata.Type, "sflow"),
-			expected: &Config{
-				Scheme:    "sflow",
-				Port:      6343,
-				Sockets:   1,
-				Workers:   1,
-				QueueSize: 1000,
-			},
+			expected: withListeners(
+				ListenerConfig{
+					Scheme:    "sflow",
+					Port:      6343,
+					Sockets:   1,
+					Workers:   1,
+					QueueSize: 1000,
+				},
+			),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "flow"),
+			expected: withListeners(
+				ListenerConfig{
+					Scheme:    "flow",
+					Port:      2055,
+					Sockets:   1,
+					Workers:   1,
+					QueueSize: 1000,
+				},
+			),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "metrics_dimensions"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Metrics.Dimensions = []string{"source.address", "destination.address"}
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "sflow_counters"),
+			expected: func() component.Config {
+				cfg := withListeners(ListenerConfig{
+					Scheme:    "sflow",
+					Port:      6343,
+					Sockets:   1,
+					Workers:   2,
+					QueueSize: 1000,
+				})
+				cfg.Metrics.SFlowCounters = true
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "aggregation"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Aggregation = AggregationConfig{
+					Window:  time.Minute,
+					Key:     []string{"source.address", "destination.port", "flow.sampler_address", "flow.interface.in.index"},
+					MaxKeys: 500,
+				}
+				cfg.Attributes.FieldSets = []string{fieldSetInterfaces}
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "stitching"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Stitching = StitchingConfig{
+					Window:   30 * time.Second,
+					MaxFlows: 2000,
+				}
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "sampling"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Sampling = SamplingConfig{
+					Scaling:     samplingScalingAdd,
+					DefaultRate: 1000,
+				}
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "attributes"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Attributes.FieldSets = []string{fieldSetIP, fieldSetRouting}
+				cfg.Metrics.Dimensions = []string{"flow.as.source", "flow.ip.dscp"}
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "geoip"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.GeoIP = GeoIPConfig{
+					CityDatabase:   "/var/lib/geoip/GeoLite2-City.mmdb",
+					ASNDatabase:    "/var/lib/geoip/GeoLite2-ASN.mmdb",
+					ReloadInterval: time.Hour,
+				}
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "networks"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Networks.Prefixes = map[string]string{
+					"10.1.0.0/16":    "datacenter-fra",
+					"192.168.0.0/16": "office",
+				}
+				cfg.Networks.File = "/etc/otelcol/networks.csv"
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "snmp"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.SNMP.Version = "v3"
+				cfg.SNMP.User = "netflow"
+				cfg.SNMP.AuthProtocol = "sha256"
+				cfg.SNMP.AuthPassword = "authpassword"
+				cfg.SNMP.PrivProtocol = "aes"
+				cfg.SNMP.PrivPassword = "privpassword"
+				cfg.SNMP.CacheTTL = 30 * time.Minute
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "exporters"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Exporters.InactivityTimeout = 15 * time.Minute
+				cfg.Exporters.AnnotateSequenceGaps = true
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "templates"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Templates.File = "/var/lib/otelcol/netflow-templates.json"
+				cfg.Templates.SaveInterval = 5 * time.Minute
+				cfg.Templates.MaxAge = 12 * time.Hour
+				cfg.Templates.Buffer.MaxPackets = 500
+				cfg.Templates.Buffer.MaxAge = 30 * time.Second
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "debug"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Debug.Endpoint = "localhost:8790"
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "debug_remote"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Debug.Endpoint = "0.0.0.0:8790"
+				cfg.Debug.AllowRemote = true
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "interfaces"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Interfaces.File = "/etc/otelcol/interfaces.yaml"
+				cfg.Interfaces.ReloadInterval = 5 * time.Minute
+				cfg.Interfaces.DefaultName = "unknown"
+				return cfg
+			}(),
+		},
+		{
+			id: component.NewIDWithName(metadata.Type, "mapping"),
+			expected: func() component.Config {
+				cfg := createDefaultConfig().(*Config)
+				cfg.Mapping = MappingConfig{
+					IPFIX: []NetFlowFieldMapping{
+						{Field: 239, FieldDestination: FieldDestination{Destination: "flow.biflow_direction"}},
+						{Field: 12235, Pen: 2, FieldDestination: FieldDestination{Destination: "application.name", Type: "string"}},
+					},
+					NetFlowV9: []NetFlowFieldMapping{
+						{Field: 34, FieldDestination: FieldDestination{Destination: "SamplingRate", Endianness: "little"}},
+					},
+					SFlow: []SFlowFieldMapping{
+						{Layer: "udp", Offset: 48, Length: 16, FieldDestination: FieldDestination{Destination: "udp.checksum"}},
+					},
+					Dictionaries: []string{"testdata/dictionary.yaml"},
+				}
+				return cfg
+			}(),
+		},
+		{
+			// The settings at the top level of the first versions are the only listener
+			id: component.NewIDWithName(metadata.Type, "deprecated_listener"),
+			expected: func() *Config {
+				cfg := withListeners(ListenerConfig{
+					Scheme:    "sflow",
+					Port:      6343,
+					Sockets:   1,
+					Workers:   4,
+					QueueSize: 1000,
+				})
+				cfg.Scheme, cfg.Port, cfg.Workers = "sflow", 6343, 4
+				return cfg
+			}(),
 		},
 	}
 
@@ -76,6 +309,21 @@ func TestLoadConfig(t *testing.T) {
 	}
 }
 
+// withListeners returns the default config with the given listeners
+func withListeners(listeners ...ListenerConfig) *Config {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Listeners = listeners
+	return cfg
+}
+
+func TestDeprecatedListenerWithListeners(t *testing.T) {
+	cm, err := confmaptest.LoadConf(filepath.Join("testdata", "config.yaml"))
+	require.NoError(t, err)
+	sub, err := cm.Sub(component.NewIDWithName(metadata.Type, "deprecated_listener_with_listeners").String())
+	require.NoError(t, err)
+	assert.ErrorContains(t, sub.Unmarshal(createDefaultConfig()), "cannot be used with listeners")
+}
+
 func TestInvalidConfig(t *testing.T) {
 	t.Parallel()
 
@@ -86,9 +334,17 @@ func TestInvalidConfig(t *testing.T) {
 		id  component.ID
 		err string
 	}{
+		{
+			id:  component.NewIDWithName(metadata.Type, "no_listeners"),
+			err: "at least one listener must be configured",
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "duplicate_listeners"),
+			err: "more than one listener is configured for :2055",
+		},
 		{
 			id:  component.NewIDWithName(metadata.Type, "invalid_schema"),
-			err: "scheme must be netflow or sflow",
+			err: "scheme must be netflow, sflow or flow",
 		},
 		{
 			id:  component.NewIDWithName(metadata.Type, "invalid_port"),
@@ -102,6 +358,90 @@ func TestInvalidConfig(t *testing.T) {
 			id:  component.NewIDWithName(metadata.Type, "zero_workers"),
 			err: "workers must be greater than 0",
 		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_metrics_dimension"),
+			err: `metrics dimension "not.an.attribute" is not a flow attribute`,
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_aggregation_key"),
+			err: `aggregation key field "flow.io.bytes" is not supported`,
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_aggregation_max_keys"),
+			err: "aggregation max_keys must be greater than 0",
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_stitching_max_flows"),
+			err: "stitching max_flows must be greater than 0",
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_stitching_with_aggregation"),
+			err: "aggregation and stitching cannot be enabled together",
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_sampling_scaling"),
+			err: "sampling scaling must be none, add or replace",
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_field_set"),
+			err: `attributes field set "everything" is not supported`,
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "dimension_without_field_set"),
+			err: `metrics dimension "flow.vlan.id" requires the layer2 field set`,
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_geoip_reload_interval"),
+			err: "geoip reload_interval must not be negative",
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_networks_prefix"),
+			err: `networks prefix "10.1.0.0/33" is not valid`,
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_exporters_inactivity_timeout"),
+			err: "exporters inactivity_timeout must not be negative",
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_templates_max_age"),
+			err: "templates max_age must not be negative",
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_templates_buffer_max_age"),
+			err: "templates buffer max_age must be positive",
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_debug_endpoint"),
+			err: `debug endpoint "localhost" is not valid: address localhost: missing port in address`,
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_debug_endpoint_remote"),
+			err: `debug endpoint ":8790" is not a loopback address, set allow_remote to expose it`,
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_interfaces_default_name"),
+			err: "interfaces default_name requires a file",
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_snmp_community"),
+			err: "snmp community must be set with version v2c",
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_snmp_auth_protocol"),
+			err: `snmp auth_protocol "sha1" is not supported`,
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_mapping_type"),
+			err: `mapping type of "flow.biflow_direction" must be varint, string, bytes or address`,
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_mapping_destination"),
+			err: `mapping destination "flow.io.bytes" is a flow attribute`,
+		},
+		{
+			id:  component.NewIDWithName(metadata.Type, "invalid_mapping_dictionary"),
+			err: `failed to read the dictionary "testdata/dictionary.json"`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,341 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.uber.org/zap"
+)
+
+// templateSystemKey identifies the template system goflow2 creates for an exporter in a listener
+type templateSystemKey struct {
+	listener string
+	src      netip.AddrPort
+}
+
+// samplingRateKey identifies the sampling rate an exporter sends for an observation domain
+type samplingRateKey struct {
+	exporter netip.Addr
+	version  uint16
+	domain   uint32
+}
+
+// basicTemplateSystem keeps the templates of an exporter like the template system of goflow2
+// Unlike it, its templates can be listed while the exporter adds new ones
+type basicTemplateSystem struct {
+	mu        sync.RWMutex
+	templates map[templateKey]any
+}
+
+func newBasicTemplateSystem() *basicTemplateSystem {
+	return &basicTemplateSystem{templates: make(map[templateKey]any)}
+}
+
+func (s *basicTemplateSystem) AddTemplate(version uint16, obsDomainID uint32, templateID uint16, template any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates[templateKey{version: version, domain: obsDomainID, id: templateID}] = template
+	return nil
+}
+
+func (s *basicTemplateSystem) GetTemplate(version uint16, obsDomainID uint32, templateID uint16) (any, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if template, ok := s.templates[templateKey{version: version, domain: obsDomainID, id: templateID}]; ok {
+		return template, nil
+	}
+	return nil, netflow.ErrorTemplateNotFound
+}
+
+func (s *basicTemplateSystem) RemoveTemplate(version uint16, obsDomainID uint32, templateID uint16) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := templateKey{version: version, domain: obsDomainID, id: templateID}
+	template, ok := s.templates[key]
+	if !ok {
+		return nil, netflow.ErrorTemplateNotFound
+	}
+	delete(s.templates, key)
+	return template, nil
+}
+
+// list returns a copy of the templates, the exporter of the keys is not set
+func (s *basicTemplateSystem) list() map[templateKey]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	templates := make(map[templateKey]any, len(s.templates))
+	for key, template := range s.templates {
+		templates[key] = template
+	}
+	return templates
+}
+
+// debugServer serves the templates of the exporters and their sampling rates as JSON
+// It reads the template systems that the NetFlow pipes of the listeners use to decode the flows
+type debugServer struct {
+	cfg    DebugConfig
+	logger *zap.Logger
+
+	mu            sync.Mutex
+	systems       map[templateSystemKey]*basicTemplateSystem
+	samplingRates map[samplingRateKey]uint64
+
+	server *http.Server
+	wg     sync.WaitGroup
+}
+
+func newDebugServer(cfg DebugConfig, logger *zap.Logger) *debugServer {
+	return &debugServer{
+		cfg:           cfg,
+		logger:        logger.With(zap.String("endpoint", cfg.Endpoint)),
+		systems:       make(map[templateSystemKey]*basicTemplateSystem),
+		samplingRates: make(map[samplingRateKey]uint64),
+	}
+}
+
+// register adds the template system of an exporter in a listener
+func (d *debugServer) register(listener string, src netip.AddrPort, templates *basicTemplateSystem) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.systems[templateSystemKey{listener: listener, src: src}] = templates
+}
+
+// observeSamplingRates records the sampling rates that goflow2 applied to the flows of the exporters
+// They also cover the rates of the NetFlow v5 headers and of the sFlow samples, that are not sent as options
+func (d *debugServer) observeSamplingRates(flowMessageSet []producer.ProducerMessage) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, m := range flowMessageSet {
+		pm, ok := m.(*protoproducer.ProtoProducerMessage)
+		if !ok || pm.SamplingRate == 0 {
+			continue
+		}
+		exporter, ok := netip.AddrFromSlice(pm.SamplerAddress)
+		if !ok {
+			continue
+		}
+		key := samplingRateKey{exporter: exporter.Unmap(), version: flowTypeVersion(int32(pm.Type)), domain: pm.ObservationDomainId}
+		d.samplingRates[key] = pm.SamplingRate
+	}
+}
+
+// observeSamplingOptions records the sampling rates of the options data records of a NetFlow v9 or IPFIX packet
+// It reads them like goflow2 does for its sampling systems, so an exporter that sent its sampling options but no flows yet has its rate
+func (d *debugServer) observeSamplingOptions(msg any, exporter netip.Addr) {
+	key := samplingRateKey{exporter: exporter.Unmap()}
+	var options []netflow.OptionsDataFlowSet
+	switch packet := msg.(type) {
+	case *netflow.NFv9Packet:
+		key.version, key.domain = 9, packet.SourceId
+		_, _, _, options = protoproducer.SplitNetFlowSets(*packet)
+	case *netflow.IPFIXPacket:
+		key.version, key.domain = 10, packet.ObservationDomainId
+		_, _, _, options = protoproducer.SplitIPFIXSets(*packet)
+	default:
+		return
+	}
+	samplingRate, found, err := protoproducer.SearchNetFlowOptionDataSets(options)
+	if err != nil || !found || samplingRate == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.samplingRates[key] = uint64(samplingRate)
+}
+
+// flowTypeVersion returns the NetFlow version of a flow type, it is zero for sFlow
+func flowTypeVersion(flowType int32) uint16 {
+	switch getFlowTypeName(flowType) {
+	case "netflow_v1":
+		return 1
+	case "netflow_v5":
+		return 5
+	case "netflow_v7":
+		return 7
+	case "netflow_v9":
+		return 9
+	case "ipfix":
+		return 10
+	default:
+		return 0
+	}
+}
+
+func (d *debugServer) start() error {
+	ln, err := net.Listen("tcp", d.cfg.Endpoint)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/templates", d.handleTemplates)
+	d.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	d.logger.Info("Starting debug endpoint")
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		if err := d.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			d.logger.Error("Debug endpoint stopped", zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+func (d *debugServer) stop() {
+	if d.server == nil {
+		return
+	}
+	if err := d.server.Shutdown(context.Background()); err != nil {
+		d.logger.Warn("Error stopping debug endpoint", zap.Error(err))
+	}
+	d.wg.Wait()
+	d.server = nil
+
+	// The listeners create new template systems when they start again
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.systems = make(map[templateSystemKey]*basicTemplateSystem)
+}
+
+type debugExporter struct {
+	Listener           string                   `json:"listener"`
+	Exporter           string                   `json:"exporter"`
+	ObservationDomains []debugObservationDomain `json:"observation_domains"`
+}
+
+type debugObservationDomain struct {
+	Version           uint16          `json:"version"`
+	ObservationDomain uint32          `json:"observation_domain"`
+	SamplingRate      uint64          `json:"sampling_rate,omitempty"`
+	Templates         []debugTemplate `json:"templates"`
+}
+
+type debugTemplate struct {
+	TemplateID uint16 `json:"template_id"`
+	Kind       string `json:"kind"`
+	// Fields are the fields of a data template, options templates have scopes and options instead
+	Fields  []debugField `json:"fields,omitempty"`
+	Scopes  []debugField `json:"scopes,omitempty"`
+	Options []debugField `json:"options,omitempty"`
+}
+
+type debugField struct {
+	Type       uint16 `json:"type"`
+	Name       string `json:"name,omitempty"`
+	Length     uint16 `json:"length"`
+	Enterprise uint32 `json:"enterprise,omitempty"`
+}
+
+// handleTemplates lists the templates of every exporter, by listener and observation domain
+func (d *debugServer) handleTemplates(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(d.exporters()); err != nil {
+		d.logger.Debug("Failed to write the templates", zap.Error(err))
+	}
+}
+
+func (d *debugServer) exporters() []debugExporter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	exporters := make([]debugExporter, 0, len(d.systems))
+	for key, system := range d.systems {
+		domains := make(map[samplingRateKey]*debugObservationDomain)
+		for tk, template := range system.list() {
+			dk := samplingRateKey{exporter: key.src.Addr().Unmap(), version: tk.version, domain: tk.domain}
+			domain, ok := domains[dk]
+			if !ok {
+				domain = &debugObservationDomain{Version: tk.version, ObservationDomain: tk.domain, SamplingRate: d.samplingRates[dk]}
+				domains[dk] = domain
+			}
+			domain.Templates = append(domain.Templates, newDebugTemplate(tk, template))
+		}
+
+		exporter := debugExporter{Listener: key.listener, Exporter: key.src.String(), ObservationDomains: []debugObservationDomain{}}
+		for _, domain := range domains {
+			slices.SortFunc(domain.Templates, func(a, b debugTemplate) int { return cmp.Compare(a.TemplateID, b.TemplateID) })
+			exporter.ObservationDomains = append(exporter.ObservationDomains, *domain)
+		}
+		slices.SortFunc(exporter.ObservationDomains, func(a, b debugObservationDomain) int {
+			return cmp.Or(cmp.Compare(a.Version, b.Version), cmp.Compare(a.ObservationDomain, b.ObservationDomain))
+		})
+		exporters = append(exporters, exporter)
+	}
+	slices.SortFunc(exporters, func(a, b debugExporter) int {
+		return cmp.Or(cmp.Compare(a.Listener, b.Listener), cmp.Compare(a.Exporter, b.Exporter))
+	})
+	return exporters
+}
+
+func newDebugTemplate(key templateKey, template any) debugTemplate {
+	t := debugTemplate{TemplateID: key.id}
+	switch record := template.(type) {
+	case netflow.TemplateRecord:
+		t.Kind = templateKindData
+		t.Fields = newDebugFields(key.version, record.Fields)
+	case netflow.NFv9OptionsTemplateRecord:
+		t.Kind = templateKindNFv9Options
+		t.Scopes = newDebugFields(key.version, record.Scopes)
+		t.Options = newDebugFields(key.version, record.Options)
+	case netflow.IPFIXOptionsTemplateRecord:
+		t.Kind = templateKindIPFIXOptions
+		t.Scopes = newDebugFields(key.version, record.Scopes)
+		t.Options = newDebugFields(key.version, record.Options)
+	}
+	return t
+}
+
+func newDebugFields(version uint16, fields []netflow.Field) []debugField {
+	debugFields := make([]debugField, 0, len(fields))
+	for _, field := range fields {
+		f := debugField{Type: field.Type, Length: field.Length}
+		switch {
+		case field.PenProvided:
+			// The names are only known for the fields of the IANA registry
+			f.Enterprise = field.Pen
+		case version == 9:
+			f.Name = netflow.NFv9TypeToString(field.Type)
+		default:
+			f.Name = netflow.IPFIXTypeToString(field.Type)
+		}
+		debugFields = append(debugFields, f)
+	}
+	return debugFields
+}
+
+// debugProducer records the sampling rates of the sampling options and of the flows for the debug endpoint
+type debugProducer struct {
+	wrapped producer.ProducerInterface
+	debug   *debugServer
+}
+
+func (p *debugProducer) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	p.debug.observeSamplingOptions(msg, args.SamplerAddress)
+	flowMessageSet, err := p.wrapped.Produce(msg, args)
+	if err == nil {
+		p.debug.observeSamplingRates(flowMessageSet)
+	}
+	return flowMessageSet, err
+}
+
+func (p *debugProducer) Close() {
+	p.wrapped.Close()
+}
+
+func (p *debugProducer) Commit(flowMessageSet []producer.ProducerMessage) {
+	p.wrapped.Commit(flowMessageSet)
+}
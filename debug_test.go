@@ -0,0 +1,115 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	flowpb "github.com/netsampler/goflow2/v2/pb"
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	"go.uber.org/zap"
+)
+
+func TestDebugTemplates(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Debug.Endpoint = "localhost:0"
+	nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *cfg)
+	require.NoError(t, err)
+	nr.logConsumer = &consumertest.LogsSink{}
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+	require.NoError(t, decode(testMessage(ipfixPacket())))
+
+	// The handler serves the templates that the NetFlow pipe decoded the packet with
+	rec := httptest.NewRecorder()
+	nr.debug.handleTemplates(rec, httptest.NewRequest(http.MethodGet, "/templates", nil))
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var exporters []debugExporter
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &exporters))
+
+	expected := []debugExporter{{
+		Listener: ":2055",
+		Exporter: "127.0.0.1:5000",
+		ObservationDomains: []debugObservationDomain{{
+			Version:           10,
+			ObservationDomain: 1,
+			Templates: []debugTemplate{{
+				TemplateID: 256,
+				Kind:       templateKindData,
+				Fields: []debugField{
+					{Type: 1, Name: "octetDeltaCount", Length: 8},
+					{Type: 61, Name: "flowDirection", Length: 1},
+					{Type: 239, Name: "biflowDirection", Length: 1},
+					{Type: 10, Name: "ingressInterface", Length: 4},
+					{Type: 1, Length: 4, Enterprise: 9},
+				},
+			}},
+		}},
+	}}
+	assert.Equal(t, expected, exporters)
+}
+
+func TestDebugSamplingRates(t *testing.T) {
+	d := newDebugServer(DebugConfig{Endpoint: "localhost:0"}, zap.NewNop())
+	system := newBasicTemplateSystem()
+	src := testMessage(nil).Src
+	d.register(":2055", src, system)
+	require.NoError(t, system.AddTemplate(9, 3, 256, nil))
+
+	d.observeSamplingRates([]producer.ProducerMessage{
+		&protoproducer.ProtoProducerMessage{FlowMessage: flowpb.FlowMessage{
+			Type:                flowpb.FlowMessage_NETFLOW_V9,
+			SamplerAddress:      src.Addr().AsSlice(),
+			ObservationDomainId: 3,
+			SamplingRate:        1000,
+		}},
+	})
+
+	exporters := d.exporters()
+	require.Len(t, exporters, 1)
+	require.Len(t, exporters[0].ObservationDomains, 1)
+	assert.Equal(t, uint64(1000), exporters[0].ObservationDomains[0].SamplingRate)
+}
+
+func TestDebugSamplingOptions(t *testing.T) {
+	d := newDebugServer(DebugConfig{Endpoint: "localhost:0"}, zap.NewNop())
+	system := newBasicTemplateSystem()
+	src := testMessage(nil).Src
+	d.register(":2055", src, system)
+	require.NoError(t, system.AddTemplate(9, 7, 257, netflow.NFv9OptionsTemplateRecord{}))
+
+	// The exporter sent its sampling interval, but no flows yet
+	d.observeSamplingOptions(&netflow.NFv9Packet{
+		SourceId: 7,
+		FlowSets: []any{netflow.OptionsDataFlowSet{Records: []netflow.OptionsDataRecord{{
+			ScopesValues:  []netflow.DataField{{Type: 1, Value: []byte{0, 0, 0, 1}}},
+			OptionsValues: []netflow.DataField{{Type: 34, Value: []byte{0, 0, 0x01, 0xf4}}},
+		}}}},
+	}, netip.AddrFrom16(src.Addr().As16()))
+
+	exporters := d.exporters()
+	require.Len(t, exporters, 1)
+	require.Len(t, exporters[0].ObservationDomains, 1)
+	assert.Equal(t, uint64(500), exporters[0].ObservationDomains[0].SamplingRate)
+}
+
+func TestDebugServer(t *testing.T) {
+	d := newDebugServer(DebugConfig{Endpoint: "127.0.0.1:0"}, zap.NewNop())
+	require.NoError(t, d.start())
+	d.register(":2055", testMessage(nil).Src, newBasicTemplateSystem())
+	d.stop()
+	assert.Empty(t, d.exporters())
+	// Stopping twice does nothing
+	d.stop()
+}
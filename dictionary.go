@@ -0,0 +1,187 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Private enterprise numbers of the vendors of the built-in dictionary
+// https://www.iana.org/assignments/enterprise-numbers/
+const (
+	penCisco    = 9
+	penJuniper  = 2636
+	penVMware   = 6876
+	penFortinet = 12356
+)
+
+// dictionaryElement is an information element of a dictionary, it is decoded as a custom field with the name of the element
+// The elements without a private enterprise number are IANA or vendor NetFlow v9 fields, they are decoded in NetFlow v9 and IPFIX
+type dictionaryElement struct {
+	Pen   uint32 `yaml:"pen"`
+	Field uint16 `yaml:"field"`
+	Name  string `yaml:"name"`
+	Type  string `yaml:"type"`
+}
+
+// builtinDictionary are the vendor elements that are always decoded
+// A dictionary file or a mapping of the same element replaces them
+var builtinDictionary = []dictionaryElement{
+	// Cisco AVC application attributes
+	{Pen: penCisco, Field: 12232, Name: "cisco.application.category", Type: customFieldTypeString},
+	{Pen: penCisco, Field: 12233, Name: "cisco.application.sub_category", Type: customFieldTypeString},
+	{Pen: penCisco, Field: 12234, Name: "cisco.application.group", Type: customFieldTypeString},
+
+	// Juniper forwarding class, loss priority and forwarding exceptions of the MX inline flow monitoring
+	{Pen: penJuniper, Field: 137, Name: "juniper.common_properties_id", Type: customFieldTypeVarint},
+
+	// VMware NSX overlay flows, the tenant fields are the inner 5-tuple of the VXLAN traffic
+	{Pen: penVMware, Field: 880, Name: "vmware.tenant.network.transport", Type: customFieldTypeVarint},
+	{Pen: penVMware, Field: 881, Name: "vmware.tenant.source.address", Type: customFieldTypeAddress},
+	{Pen: penVMware, Field: 882, Name: "vmware.tenant.destination.address", Type: customFieldTypeAddress},
+	{Pen: penVMware, Field: 883, Name: "vmware.tenant.source.address", Type: customFieldTypeAddress},
+	{Pen: penVMware, Field: 884, Name: "vmware.tenant.destination.address", Type: customFieldTypeAddress},
+	{Pen: penVMware, Field: 886, Name: "vmware.tenant.source.port", Type: customFieldTypeVarint},
+	{Pen: penVMware, Field: 887, Name: "vmware.tenant.destination.port", Type: customFieldTypeVarint},
+	{Pen: penVMware, Field: 888, Name: "vmware.interface.out.type", Type: customFieldTypeVarint},
+	{Pen: penVMware, Field: 889, Name: "vmware.vxlan.export_role", Type: customFieldTypeVarint},
+	{Pen: penVMware, Field: 890, Name: "vmware.interface.in.type", Type: customFieldTypeVarint},
+
+	// Fortinet FortiGate application control, authenticated user and web filter
+	{Pen: penFortinet, Field: 1, Name: "fortinet.application.name", Type: customFieldTypeString},
+	{Pen: penFortinet, Field: 2, Name: "fortinet.user.name", Type: customFieldTypeString},
+	{Pen: penFortinet, Field: 3, Name: "fortinet.url", Type: customFieldTypeString},
+
+	// Palo Alto Networks NetFlow v9 fields
+	{Field: 56701, Name: "paloalto.app_id", Type: customFieldTypeString},
+	{Field: 56702, Name: "paloalto.user_id", Type: customFieldTypeString},
+
+	// IANA elements that goflow2 does not decode
+	// The layer 2 segment of a VXLAN flow has the type 1 in its first octet and the VNI in its last three
+	{Field: 351, Name: "flow.layer2.segment_id", Type: customFieldTypeVarint},
+	{Field: 371, Name: "flow.user.name", Type: customFieldTypeString},
+	{Field: 460, Name: "flow.http.request.host", Type: customFieldTypeString},
+	{Field: 461, Name: "flow.http.request.target", Type: customFieldTypeString},
+}
+
+// readDictionaryFile reads the elements of a YAML file with a list of elements
+// or of a CSV file with a private enterprise number, a field, a name and optionally a type in each line
+func readDictionaryFile(path string) ([]dictionaryElement, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var elements []dictionaryElement
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.NewDecoder(f).Decode(&elements); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		for i, element := range elements {
+			if err := element.validate(); err != nil {
+				return nil, fmt.Errorf("element %d: %w", i+1, err)
+			}
+		}
+	case ".csv":
+		r := csv.NewReader(f)
+		r.Comment = '#'
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		for i, record := range records {
+			element, err := parseDictionaryRecord(record)
+			if err == nil {
+				err = element.validate()
+			}
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			elements = append(elements, element)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file extension %q, must be .csv, .yaml or .yml", filepath.Ext(path))
+	}
+	return elements, nil
+}
+
+// parseDictionaryRecord parses a line of a CSV dictionary file
+func parseDictionaryRecord(record []string) (dictionaryElement, error) {
+	if len(record) < 3 || len(record) > 4 {
+		return dictionaryElement{}, fmt.Errorf("expected 3 or 4 fields, got %d", len(record))
+	}
+	pen, err := strconv.ParseUint(record[0], 10, 32)
+	if err != nil {
+		return dictionaryElement{}, fmt.Errorf("pen %q is not valid: %w", record[0], err)
+	}
+	field, err := strconv.ParseUint(record[1], 10, 16)
+	if err != nil {
+		return dictionaryElement{}, fmt.Errorf("field %q is not valid: %w", record[1], err)
+	}
+	element := dictionaryElement{Pen: uint32(pen), Field: uint16(field), Name: record[2]}
+	if len(record) > 3 {
+		element.Type = record[3]
+	}
+	return element, nil
+}
+
+// validate checks the element, its name is checked against the flow attributes with the mappings
+func (e dictionaryElement) validate() error {
+	if e.Field == 0 {
+		return fmt.Errorf("field must be greater than 0")
+	}
+	if e.Name == "" {
+		return fmt.Errorf("name of field %d must not be empty", e.Field)
+	}
+	return nil
+}
+
+// mapping returns the mapping that decodes the element
+func (e dictionaryElement) mapping() NetFlowFieldMapping {
+	return NetFlowFieldMapping{
+		Field:            e.Field,
+		Pen:              e.Pen,
+		FieldDestination: FieldDestination{Destination: e.Name, Type: e.Type},
+	}
+}
+
+// withDictionaries returns a copy of the mappings with the elements of the built-in dictionary and of the dictionary files
+// The elements go before the mappings, so a mapping of the same field replaces them
+func (mc *MappingConfig) withDictionaries() (MappingConfig, error) {
+	elements := builtinDictionary
+	for _, path := range mc.Dictionaries {
+		file, err := readDictionaryFile(path)
+		if err != nil {
+			return MappingConfig{}, fmt.Errorf("failed to read the dictionary %q: %w", path, err)
+		}
+		elements = append(elements[:len(elements):len(elements)], file...)
+	}
+
+	full := *mc
+	full.IPFIX = nil
+	full.NetFlowV9 = nil
+	for _, element := range elements {
+		full.IPFIX = append(full.IPFIX, element.mapping())
+		// NetFlow v9 has no private enterprise numbers
+		if element.Pen == 0 {
+			full.NetFlowV9 = append(full.NetFlowV9, element.mapping())
+		}
+	}
+	full.IPFIX = append(full.IPFIX, mc.IPFIX...)
+	full.NetFlowV9 = append(full.NetFlowV9, mc.NetFlowV9...)
+	return full, nil
+}
@@ -0,0 +1,167 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+)
+
+// vendorIPFIXPacket builds an IPFIX packet with a record of a NSX overlay flow
+// The record has the octet count, the VMware tenant source address, the Cisco application category and the example application name
+func vendorIPFIXPacket() []byte {
+	template := []byte{
+		0, 2, 0, 0, // template set, length
+		1, 0, 0, 4, // template 256 with 4 fields
+		0, 1, 0, 8, // octetDeltaCount
+		0x83, 0x71, 0, 4, // tenantSourceIPv4
+		0, 0, 0x1a, 0xdc, // pen 6876
+		0xaf, 0xc8, 0, 16, // application category name
+		0, 0, 0, 9, // pen 9
+		0x80, 0x01, 0, 8, // example field 1
+		0, 0, 0x7e, 0xd9, // pen 32473
+	}
+	binary.BigEndian.PutUint16(template[2:], uint16(len(template)))
+
+	data := []byte{1, 0, 0, 0}
+	data = binary.BigEndian.AppendUint64(data, 1500)
+	data = append(data, 172, 16, 0, 10)
+	data = append(data, make([]byte, 16)...)
+	copy(data[len(data)-16:], "email")
+	data = append(data, "webmail!"...)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)))
+
+	header := make([]byte, 16)
+	binary.BigEndian.PutUint16(header[0:], 10)
+	binary.BigEndian.PutUint16(header[2:], uint16(16+len(template)+len(data)))
+	binary.BigEndian.PutUint32(header[12:], 1) // observation domain
+
+	return append(append(header, template...), data...)
+}
+
+func TestDictionaryElements(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Mapping.Dictionaries = []string{filepath.Join("testdata", "dictionary.yaml")}
+	require.NoError(t, cfg.Mapping.Validate())
+	nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *cfg)
+	require.NoError(t, err)
+	sink := &consumertest.LogsSink{}
+	nr.logConsumer = sink
+	nr.enrichers = nr.buildEnrichers()
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+
+	require.NoError(t, decode(testMessage(vendorIPFIXPacket())))
+	attrs := firstLogRecord(t, sink.AllLogs()).Attributes()
+	assertIntAttribute(t, attrs, "flow.io.bytes", 1500)
+	assertStrAttribute(t, attrs, "vmware.tenant.source.address", "172.16.0.10")
+	assertStrAttribute(t, attrs, "cisco.application.category", "email")
+	assertStrAttribute(t, attrs, "example.application.name", "webmail!")
+}
+
+// fortinetIPFIXPacket builds an IPFIX packet with a record of a FortiGate flow in a VXLAN segment
+// The record has the octet count, the layer 2 segment with the VNI 5000 and the Fortinet application and URL
+func fortinetIPFIXPacket() []byte {
+	template := []byte{
+		0, 2, 0, 0, // template set, length
+		1, 0, 0, 4, // template 256 with 4 fields
+		0, 1, 0, 8, // octetDeltaCount
+		1, 0x5f, 0, 8, // layer2SegmentId
+		0x80, 0x01, 0, 16, // application name
+		0, 0, 0x30, 0x44, // pen 12356
+		0x80, 0x03, 0xff, 0xff, // url, variable length
+		0, 0, 0x30, 0x44, // pen 12356
+	}
+	binary.BigEndian.PutUint16(template[2:], uint16(len(template)))
+
+	data := []byte{1, 0, 0, 0}
+	data = binary.BigEndian.AppendUint64(data, 1500)
+	data = binary.BigEndian.AppendUint64(data, 0x0100000000001388)
+	data = append(data, make([]byte, 16)...)
+	copy(data[len(data)-16:], "YouTube")
+	url := "www.youtube.com/watch"
+	data = append(append(data, byte(len(url))), url...)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)))
+
+	header := make([]byte, 16)
+	binary.BigEndian.PutUint16(header[0:], 10)
+	binary.BigEndian.PutUint16(header[2:], uint16(16+len(template)+len(data)))
+	binary.BigEndian.PutUint32(header[12:], 1) // observation domain
+
+	return append(append(header, template...), data...)
+}
+
+func TestDictionaryFortinetElements(t *testing.T) {
+	decode, sink := newTestDefaultDecoder(t)
+
+	require.NoError(t, decode(testMessage(fortinetIPFIXPacket())))
+	attrs := firstLogRecord(t, sink.AllLogs()).Attributes()
+	assertIntAttribute(t, attrs, "flow.io.bytes", 1500)
+	assertIntAttribute(t, attrs, "flow.layer2.segment_id", 0x0100000000001388)
+	assertStrAttribute(t, attrs, "fortinet.application.name", "YouTube")
+	assertStrAttribute(t, attrs, "fortinet.url", "www.youtube.com/watch")
+}
+
+func TestDictionaryMappingOverride(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Mapping.IPFIX = []NetFlowFieldMapping{
+		{Field: 12232, Pen: penCisco, FieldDestination: FieldDestination{Destination: "application.category", Type: customFieldTypeBytes}},
+	}
+	nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *cfg)
+	require.NoError(t, err)
+	sink := &consumertest.LogsSink{}
+	nr.logConsumer = sink
+	nr.enrichers = nr.buildEnrichers()
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+
+	require.NoError(t, decode(testMessage(vendorIPFIXPacket())))
+	attrs := firstLogRecord(t, sink.AllLogs()).Attributes()
+	category, ok := attrs.Get("application.category")
+	require.True(t, ok)
+	assert.Len(t, category.Bytes().AsRaw(), 16)
+	_, ok = attrs.Get("cisco.application.category")
+	assert.False(t, ok)
+}
+
+func TestReadDictionaryFile(t *testing.T) {
+	expected := []dictionaryElement{
+		{Pen: 32473, Field: 1, Name: "example.application.name", Type: customFieldTypeString},
+		{Pen: 32473, Field: 2, Name: "example.tenant.address", Type: customFieldTypeAddress},
+		{Pen: 32473, Field: 3, Name: "example.vni"},
+	}
+	for _, name := range []string{"dictionary.yaml", "dictionary.csv"} {
+		t.Run(name, func(t *testing.T) {
+			elements, err := readDictionaryFile(filepath.Join("testdata", name))
+			require.NoError(t, err)
+			assert.Equal(t, expected, elements)
+		})
+	}
+
+	_, err := readDictionaryFile(filepath.Join("testdata", "config.yaml"))
+	assert.ErrorContains(t, err, "yaml")
+	_, err = readDictionaryFile("dictionary.json")
+	assert.Error(t, err)
+	_, err = parseDictionaryRecord([]string{"9", "70000", "too.large"})
+	assert.ErrorContains(t, err, `field "70000" is not valid`)
+	assert.EqualError(t, dictionaryElement{Field: 1}.validate(), "name of field 1 must not be empty")
+}
+
+func TestDictionaryValidate(t *testing.T) {
+	mapping := MappingConfig{
+		IPFIX: []NetFlowFieldMapping{
+			{Field: 1, Pen: 9, FieldDestination: FieldDestination{Destination: "cisco.application.category"}},
+		},
+	}
+	assert.EqualError(t, mapping.Validate(), `mapping destination "cisco.application.category" is used with different types`)
+
+	mapping = MappingConfig{Dictionaries: []string{filepath.Join("testdata", "missing.csv")}}
+	assert.ErrorContains(t, mapping.Validate(), `failed to read the dictionary "testdata/missing.csv"`)
+}
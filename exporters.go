@@ -0,0 +1,286 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+
+	"github.com/dynatrace-extensions/netflowreceiver/internal/metadata"
+)
+
+// exporterSilentEvent is the name of the log record sent when an exporter goes silent
+const exporterSilentEvent = "exporter.silent"
+
+// exporterCheckInterval is the longest time between two checks for silent exporters
+const exporterCheckInterval = 10 * time.Second
+
+// exporterStats are the statistics of a single exporter, identified by the address its packets come from
+type exporterStats struct {
+	lastSeen time.Time
+	packets  int64
+	// flows counts the flows by flow type, an exporter can send different versions
+	flows map[string]int64
+	// templates is the number of NetFlow v9 and IPFIX templates the exporter sent that are still known
+	templates int64
+	// silent is set once the exporter did not send packets for the inactivity timeout
+	silent bool
+}
+
+// exporterTracker keeps the statistics of every exporter and logs when an exporter stops sending packets
+// It is shared by all the listeners, so an exporter sending to several listeners is tracked once
+type exporterTracker struct {
+	cfg    ExportersConfig
+	logger *zap.Logger
+	// logConsumer receives a log record for every exporter that goes silent, it is nil without a logs pipeline
+	logConsumer consumer.Logs
+	// now is replaced in the tests
+	now func() time.Time
+
+	mu        sync.Mutex
+	exporters map[netip.Addr]*exporterStats
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newExporterTracker(cfg ExportersConfig, logger *zap.Logger) *exporterTracker {
+	return &exporterTracker{
+		cfg:       cfg,
+		logger:    logger,
+		now:       time.Now,
+		exporters: make(map[netip.Addr]*exporterStats),
+	}
+}
+
+// registerMetrics reports the statistics of the exporters as internal metrics, every one of them has the exporter address
+func (t *exporterTracker) registerMetrics(meter metric.Meter) error {
+	packets, err := meter.Int64ObservableCounter("otelcol_netflow_receiver_exporter_packets",
+		metric.WithUnit("{packet}"), metric.WithDescription("Number of packets with flows received from the exporter"))
+	if err != nil {
+		return err
+	}
+	flows, err := meter.Int64ObservableCounter("otelcol_netflow_receiver_exporter_flows",
+		metric.WithUnit("{flow}"), metric.WithDescription("Number of flows received from the exporter, by flow type"))
+	if err != nil {
+		return err
+	}
+	templates, err := meter.Int64ObservableGauge("otelcol_netflow_receiver_exporter_templates",
+		metric.WithUnit("{template}"), metric.WithDescription("Number of NetFlow v9 and IPFIX templates known for the exporter"))
+	if err != nil {
+		return err
+	}
+	idle, err := meter.Float64ObservableGauge("otelcol_netflow_receiver_exporter_idle_time",
+		metric.WithUnit("s"), metric.WithDescription("Time since the last packet with flows was received from the exporter"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		now := t.now()
+		for addr, stats := range t.exporters {
+			exporter := attribute.String("sampler.address", addr.String())
+			attrs := metric.WithAttributes(exporter)
+			o.ObserveInt64(packets, stats.packets, attrs)
+			for flowType, count := range stats.flows {
+				o.ObserveInt64(flows, count, metric.WithAttributes(exporter, attribute.String("flow.type", flowType)))
+			}
+			o.ObserveInt64(templates, stats.templates, attrs)
+			if !stats.lastSeen.IsZero() {
+				o.ObserveFloat64(idle, now.Sub(stats.lastSeen).Seconds(), attrs)
+			}
+		}
+		return nil
+	}, packets, flows, templates, idle)
+	return err
+}
+
+// exporter returns the statistics of an exporter, it must be called with the lock held
+func (t *exporterTracker) exporter(addr netip.Addr) *exporterStats {
+	stats, ok := t.exporters[addr]
+	if !ok {
+		stats = &exporterStats{flows: make(map[string]int64)}
+		t.exporters[addr] = stats
+	}
+	return stats
+}
+
+// observe records a packet of an exporter and its flows
+func (t *exporterTracker) observe(addr netip.Addr, flowMessageSet []producer.ProducerMessage) {
+	addr = addr.Unmap()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats := t.exporter(addr)
+	stats.lastSeen = t.now()
+	stats.packets++
+	for _, m := range flowMessageSet {
+		if pm, ok := m.(*protoproducer.ProtoProducerMessage); ok {
+			stats.flows[getFlowTypeName(int32(pm.Type))]++
+		}
+	}
+
+	if stats.silent {
+		stats.silent = false
+		t.logger.Info("Exporter is sending flows again", zap.Stringer("exporter", addr))
+	}
+}
+
+// templatesChanged adds delta to the number of templates of an exporter
+func (t *exporterTracker) templatesChanged(addr netip.Addr, delta int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.exporter(addr.Unmap()).templates += delta
+}
+
+// checkSilent logs the exporters that did not send packets for the inactivity timeout
+// and sends a log record for each of them to the logs consumer
+func (t *exporterTracker) checkSilent() {
+	t.mu.Lock()
+	now := t.now()
+	logs := plog.NewLogs()
+	scopeLog := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty()
+	scopeLog.Scope().SetName(metadata.ScopeName)
+	scopeLog.Scope().Attributes().PutStr("receiver", metadata.Type.String())
+	for addr, stats := range t.exporters {
+		if stats.silent || stats.lastSeen.IsZero() || now.Sub(stats.lastSeen) < t.cfg.InactivityTimeout {
+			continue
+		}
+		stats.silent = true
+		t.logger.Warn("Exporter went silent",
+			zap.Stringer("exporter", addr),
+			zap.Time("last_seen", stats.lastSeen),
+			zap.Duration("inactivity_timeout", t.cfg.InactivityTimeout),
+		)
+		r := scopeLog.LogRecords().AppendEmpty()
+		r.SetEventName(exporterSilentEvent)
+		r.SetSeverityNumber(plog.SeverityNumberWarn)
+		r.SetSeverityText(plog.SeverityNumberWarn.String())
+		r.SetTimestamp(pcommon.NewTimestampFromTime(now))
+		r.SetObservedTimestamp(pcommon.NewTimestampFromTime(now))
+		r.Body().SetStr("Exporter went silent")
+		r.Attributes().PutStr("flow.sampler_address", addr.String())
+		r.Attributes().PutInt("flow.sampler_last_seen", stats.lastSeen.UnixNano())
+	}
+	t.mu.Unlock()
+
+	// The consumer is called without the lock, it can block while the pipeline is busy
+	if t.logConsumer == nil || logs.LogRecordCount() == 0 {
+		return
+	}
+	if err := t.logConsumer.ConsumeLogs(context.Background(), logs); err != nil {
+		t.logger.Warn("failed to send the silent exporters", zap.Error(err))
+	}
+}
+
+// start checks for silent exporters until stop is called, it does nothing when the inactivity timeout is zero
+func (t *exporterTracker) start() {
+	if t.cfg.InactivityTimeout == 0 {
+		return
+	}
+	t.done = make(chan struct{})
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(min(t.cfg.InactivityTimeout/2, exporterCheckInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-ticker.C:
+				t.checkSilent()
+			}
+		}
+	}()
+}
+
+func (t *exporterTracker) stop() {
+	if t.done == nil {
+		return
+	}
+	close(t.done)
+	t.wg.Wait()
+	t.done = nil
+}
+
+// templateSystem returns a template system that counts the templates of the exporter
+func (t *exporterTracker) templateSystem(exporter netip.Addr, templates netflow.NetFlowTemplateSystem) netflow.NetFlowTemplateSystem {
+	return &trackedTemplateSystem{
+		NetFlowTemplateSystem: templates,
+		exporter:              exporter,
+		tracker:               t,
+	}
+}
+
+// trackedTemplateSystem counts the templates of an exporter
+type trackedTemplateSystem struct {
+	netflow.NetFlowTemplateSystem
+	exporter netip.Addr
+	tracker  *exporterTracker
+	// mu makes checking if a template is new and adding it atomic, the workers of a listener share the template system
+	mu sync.Mutex
+}
+
+func (s *trackedTemplateSystem) AddTemplate(version uint16, obsDomainID uint32, templateID uint16, template any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Exporters send the same templates again and again, only new ones are counted
+	_, err := s.NetFlowTemplateSystem.GetTemplate(version, obsDomainID, templateID)
+	isNew := err != nil
+	if err := s.NetFlowTemplateSystem.AddTemplate(version, obsDomainID, templateID, template); err != nil {
+		return err
+	}
+	if isNew {
+		s.tracker.templatesChanged(s.exporter, 1)
+	}
+	return nil
+}
+
+func (s *trackedTemplateSystem) RemoveTemplate(version uint16, obsDomainID uint32, templateID uint16) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	template, err := s.NetFlowTemplateSystem.RemoveTemplate(version, obsDomainID, templateID)
+	if err == nil {
+		s.tracker.templatesChanged(s.exporter, -1)
+	}
+	return template, err
+}
+
+// exporterProducer records the packets and flows of every exporter in the tracker
+type exporterProducer struct {
+	wrapped producer.ProducerInterface
+	tracker *exporterTracker
+}
+
+func (p *exporterProducer) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	flowMessageSet, err := p.wrapped.Produce(msg, args)
+	if err != nil {
+		return flowMessageSet, err
+	}
+	p.tracker.observe(args.SamplerAddress, flowMessageSet)
+	return flowMessageSet, nil
+}
+
+func (p *exporterProducer) Close() {
+	p.wrapped.Close()
+}
+
+func (p *exporterProducer) Commit(flowMessageSet []producer.ProducerMessage) {
+	p.wrapped.Commit(flowMessageSet)
+}
@@ -0,0 +1,100 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestExporterTracker(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	params := receivertest.NewNopSettings()
+	params.TelemetrySettings.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	params.TelemetrySettings.MetricsLevel = configtelemetry.LevelDetailed
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Listeners[0].Scheme = "flow"
+	nr, err := newNetflowReceiver(params, *cfg)
+	require.NoError(t, err)
+	nr.logConsumer = &consumertest.LogsSink{}
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+
+	// The exporter sends the same template twice, it is only counted once
+	require.NoError(t, decode(testMessage(ipfixPacket())))
+	require.NoError(t, decode(testMessage(ipfixPacket())))
+	require.NoError(t, decode(testMessage(netflowV5Packet())))
+
+	exporter := attribute.String("sampler.address", "127.0.0.1")
+	assert.Equal(t, int64(3), counterValue(t, reader, "otelcol_netflow_receiver_exporter_packets", exporter))
+	assert.Equal(t, int64(2), counterValue(t, reader, "otelcol_netflow_receiver_exporter_flows", exporter, attribute.String("flow.type", "ipfix")))
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_netflow_receiver_exporter_flows", exporter, attribute.String("flow.type", "netflow_v5")))
+
+	nr.exporters.mu.Lock()
+	defer nr.exporters.mu.Unlock()
+	stats := nr.exporters.exporters[netip.MustParseAddr("127.0.0.1")]
+	require.NotNil(t, stats)
+	assert.Equal(t, int64(1), stats.templates)
+}
+
+func TestExporterTrackerSilent(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	clock := &fakeClock{now: time.Now()}
+	tracker := newExporterTracker(ExportersConfig{InactivityTimeout: time.Minute}, zap.New(core))
+	tracker.now = clock.Now
+	sink := &consumertest.LogsSink{}
+	tracker.logConsumer = sink
+
+	router := netip.MustParseAddr("192.0.2.1")
+	tracker.observe(router, nil)
+	lastSeen := clock.Now()
+	// Exporters only known from their templates are not checked
+	tracker.templatesChanged(netip.MustParseAddr("192.0.2.2"), 1)
+
+	clock.advance(59 * time.Second)
+	tracker.checkSilent()
+	assert.Equal(t, 0, logs.Len())
+	assert.Equal(t, 0, sink.LogRecordCount())
+
+	clock.advance(time.Second)
+	tracker.checkSilent()
+	tracker.checkSilent()
+	silent := logs.FilterMessage("Exporter went silent").All()
+	require.Len(t, silent, 1)
+	assert.Equal(t, "192.0.2.1", silent[0].ContextMap()["exporter"])
+
+	// The exporter is sent once as a log record
+	require.Equal(t, 1, sink.LogRecordCount())
+	record := firstLogRecord(t, sink.AllLogs())
+	assert.Equal(t, exporterSilentEvent, record.EventName())
+	assert.Equal(t, plog.SeverityNumberWarn, record.SeverityNumber())
+	assert.Equal(t, pcommon.NewTimestampFromTime(clock.Now()), record.Timestamp())
+	assertStrAttribute(t, record.Attributes(), "flow.sampler_address", "192.0.2.1")
+	assertIntAttribute(t, record.Attributes(), "flow.sampler_last_seen", lastSeen.UnixNano())
+
+	tracker.observe(netip.AddrFrom16(router.As16()), nil)
+	assert.Equal(t, 1, logs.FilterMessage("Exporter is sending flows again").Len())
+}
+
+func TestExporterTrackerWithoutTimeout(t *testing.T) {
+	tracker := newExporterTracker(ExportersConfig{}, zap.NewNop())
+	tracker.start()
+	assert.Nil(t, tracker.done)
+	tracker.stop()
+}
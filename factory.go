@@ -5,12 +5,15 @@ package netflowreceiver // import "github.com/open-telemetry/opentelemetry-colle
 
 import (
 	"context"
+	"time"
 
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/receiver"
+	semconv "go.opentelemetry.io/collector/semconv/v1.27.0"
 
 	"github.com/dynatrace-extensions/netflowreceiver/internal/metadata"
+	"github.com/dynatrace-extensions/netflowreceiver/internal/sharedcomponent"
 )
 
 const (
@@ -20,6 +23,23 @@ const (
 	// that for a full queue of 1000 messages, the size in memory will be 9MB.
 	// Source: https://github.com/netsampler/goflow2/blob/v2.2.1/README.md#security-notes-and-assumptions
 	defaultQueueSize = 1_000
+	// The aggregation keeps at most this many keys per listener and window
+	defaultAggregationMaxKeys = 10_000
+	// The stitching keeps at most this many flows waiting for their pair per listener
+	defaultStitchingMaxFlows = 10_000
+	// The files loaded by the receiver are checked for changes this often
+	defaultReloadInterval = time.Minute
+	// The exporters are polled with SNMP on the standard port and their interfaces are cached for an hour
+	defaultSNMPPort     = 161
+	defaultSNMPTimeout  = 5 * time.Second
+	defaultSNMPCacheTTL = time.Hour
+	// An exporter is logged as silent after this long without flows
+	defaultInactivityTimeout = 5 * time.Minute
+	// The templates are saved every minute and forgotten a day after the exporter last sent them
+	defaultTemplatesSaveInterval = time.Minute
+	defaultTemplatesMaxAge       = 24 * time.Hour
+	// The packets that wait for their template are dropped after a minute
+	defaultTemplateBufferMaxAge = time.Minute
 )
 
 // NewFactory creates a factory for netflow receiver.
@@ -27,13 +47,67 @@ func NewFactory() receiver.Factory {
 	return receiver.NewFactory(
 		metadata.Type,
 		createDefaultConfig,
-		receiver.WithLogs(createLogsReceiver, metadata.LogsStability))
+		receiver.WithLogs(createLogsReceiver, metadata.LogsStability),
+		receiver.WithMetrics(createMetricsReceiver, metadata.MetricsStability))
 }
 
 // Config defines configuration for netflow receiver.
 // By default we listen for netflow traffic on port 2055
 func createDefaultConfig() component.Config {
 	return &Config{
+		Listeners: []ListenerConfig{defaultListenerConfig()},
+		Metrics: MetricsConfig{
+			Dimensions: []string{
+				semconv.AttributeNetworkTransport,
+				semconv.AttributeNetworkType,
+				"flow.type",
+				"flow.sampler_address",
+			},
+		},
+		Aggregation: AggregationConfig{
+			Key: []string{
+				semconv.AttributeSourceAddress,
+				semconv.AttributeDestinationAddress,
+				semconv.AttributeNetworkTransport,
+			},
+			MaxKeys: defaultAggregationMaxKeys,
+		},
+		Stitching: StitchingConfig{
+			MaxFlows: defaultStitchingMaxFlows,
+		},
+		Sampling: SamplingConfig{
+			Scaling: samplingScalingNone,
+		},
+		GeoIP: GeoIPConfig{
+			ReloadInterval: defaultReloadInterval,
+		},
+		Networks: NetworksConfig{
+			ReloadInterval: defaultReloadInterval,
+		},
+		Interfaces: InterfacesConfig{
+			ReloadInterval: defaultReloadInterval,
+		},
+		Exporters: ExportersConfig{
+			InactivityTimeout: defaultInactivityTimeout,
+		},
+		Templates: TemplatesConfig{
+			SaveInterval: defaultTemplatesSaveInterval,
+			MaxAge:       defaultTemplatesMaxAge,
+			Buffer: TemplateBufferConfig{
+				MaxAge: defaultTemplateBufferMaxAge,
+			},
+		},
+		SNMP: SNMPConfig{
+			Port:     defaultSNMPPort,
+			Timeout:  defaultSNMPTimeout,
+			CacheTTL: defaultSNMPCacheTTL,
+		},
+	}
+}
+
+// defaultListenerConfig defines the settings used for every listener unless overridden
+func defaultListenerConfig() ListenerConfig {
+	return ListenerConfig{
 		Scheme:    "netflow",
 		Port:      2055,
 		Sockets:   defaultSockets,
@@ -43,15 +117,39 @@ func createDefaultConfig() component.Config {
 }
 
 // createLogsReceiver creates a netflow receiver.
-// We also create the UDP receiver, which is the piece of software that actually listens
-// for incoming netflow traffic on an UDP port.
+// We also create the UDP receivers, which are the pieces of software that actually listen
+// for incoming netflow traffic on an UDP port, one for each configured listener.
 func createLogsReceiver(_ context.Context, params receiver.Settings, cfg component.Config, consumer consumer.Logs) (receiver.Logs, error) {
-	conf := *(cfg.(*Config))
+	nr, err := loadOrCreateReceiver(params, cfg)
+	if err != nil {
+		return nil, err
+	}
 
-	nr, err := newNetflowLogsReceiver(params, conf, consumer)
+	nr.Unwrap().logConsumer = consumer
+	return nr, nil
+}
+
+// createMetricsReceiver creates a netflow receiver that converts flows into metrics.
+// If the same receiver is also used in a logs pipeline, both share the UDP listeners.
+func createMetricsReceiver(_ context.Context, params receiver.Settings, cfg component.Config, consumer consumer.Metrics) (receiver.Metrics, error) {
+	nr, err := loadOrCreateReceiver(params, cfg)
 	if err != nil {
 		return nil, err
 	}
 
+	nr.Unwrap().metricsConsumer = consumer
 	return nr, nil
 }
+
+// loadOrCreateReceiver returns the receiver for the given config, creating it if it does not exist yet.
+// The collector passes the same config to every pipeline of a receiver ID, so we use it as the key.
+func loadOrCreateReceiver(params receiver.Settings, cfg component.Config) (*sharedcomponent.Component[*netflowReceiver], error) {
+	return receivers.LoadOrStore(cfg, func() (*netflowReceiver, error) {
+		conf := *(cfg.(*Config))
+		return newNetflowReceiver(params, conf)
+	})
+}
+
+// receivers holds the receivers that have been created, so that the
+// logs and metrics pipelines of the same receiver share the same listeners
+var receivers = sharedcomponent.NewMap[component.Config, *netflowReceiver]()
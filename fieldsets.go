@@ -0,0 +1,187 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"encoding/binary"
+	"net"
+	"net/netip"
+
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+const (
+	fieldSetInterfaces = "interfaces"
+	fieldSetIP         = "ip"
+	fieldSetTransport  = "transport"
+	fieldSetRouting    = "routing"
+	fieldSetLayer2     = "layer2"
+	fieldSetMPLS       = "mpls"
+	fieldSetHeaders    = "headers"
+)
+
+// fieldSet is a group of optional flow attributes that is only extracted when it is enabled
+// It is a flowEnricher so it is applied to logs and metrics the same way
+type fieldSet struct {
+	// attributes lists every attribute the set can add, some are only added when the device sends them
+	attributes []string
+	put        func(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map)
+}
+
+func (fs fieldSet) enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	fs.put(pm, attrs)
+}
+
+// fieldSets are the optional attributes, grouped so only the ones that are used have a cost
+var fieldSets = map[string]fieldSet{
+	fieldSetInterfaces: {
+		attributes: []string{"flow.interface.in.index", "flow.interface.out.index", "flow.direction"},
+		put: func(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+			attrs.PutInt("flow.interface.in.index", int64(pm.InIf))
+			attrs.PutInt("flow.interface.out.index", int64(pm.OutIf))
+			// The direction is not part of the goflow2 flow message, see flowDirectionIndex
+			if direction, ok := getFlowDirection(pm); ok {
+				attrs.PutStr("flow.direction", direction)
+			}
+		},
+	},
+	fieldSetIP: {
+		attributes: []string{
+			"flow.ip.tos", "flow.ip.dscp", "flow.ip.ecn", "flow.ip.ttl", "flow.ip.flags",
+			"flow.ip.fragment.id", "flow.ip.fragment.offset", "flow.ipv6.flow_label",
+		},
+		put: func(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+			attrs.PutInt("flow.ip.tos", int64(pm.IpTos))
+			// DSCP is in the upper 6 bits of the ToS byte and ECN in the lower 2
+			attrs.PutInt("flow.ip.dscp", int64(pm.IpTos>>2))
+			attrs.PutInt("flow.ip.ecn", int64(pm.IpTos&0x3))
+			attrs.PutInt("flow.ip.ttl", int64(pm.IpTtl))
+			attrs.PutInt("flow.ip.flags", int64(pm.IpFlags))
+			attrs.PutInt("flow.ip.fragment.id", int64(pm.FragmentId))
+			attrs.PutInt("flow.ip.fragment.offset", int64(pm.FragmentOffset))
+			attrs.PutInt("flow.ipv6.flow_label", int64(pm.Ipv6FlowLabel))
+		},
+	},
+	fieldSetTransport: {
+		attributes: []string{"flow.tcp.flags", "flow.icmp.type", "flow.icmp.code"},
+		put: func(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+			attrs.PutInt("flow.tcp.flags", int64(pm.TcpFlags))
+			attrs.PutInt("flow.icmp.type", int64(pm.IcmpType))
+			attrs.PutInt("flow.icmp.code", int64(pm.IcmpCode))
+		},
+	},
+	fieldSetRouting: {
+		attributes: []string{
+			"flow.as.source", "flow.as.destination", "flow.as.path",
+			"flow.next_hop.address", "flow.next_hop.as", "flow.bgp.next_hop.address", "flow.bgp.communities",
+			"flow.net.source.mask", "flow.net.destination.mask", "flow.forwarding_status",
+		},
+		put: func(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+			attrs.PutInt("flow.as.source", int64(pm.SrcAs))
+			attrs.PutInt("flow.as.destination", int64(pm.DstAs))
+			putIntSlice(attrs, "flow.as.path", pm.AsPath)
+			putAddress(attrs, "flow.next_hop.address", pm.NextHop)
+			attrs.PutInt("flow.next_hop.as", int64(pm.NextHopAs))
+			putAddress(attrs, "flow.bgp.next_hop.address", pm.BgpNextHop)
+			putIntSlice(attrs, "flow.bgp.communities", pm.BgpCommunities)
+			attrs.PutInt("flow.net.source.mask", int64(pm.SrcNet))
+			attrs.PutInt("flow.net.destination.mask", int64(pm.DstNet))
+			attrs.PutInt("flow.forwarding_status", int64(pm.ForwardingStatus))
+		},
+	},
+	fieldSetLayer2: {
+		attributes: []string{"flow.mac.source", "flow.mac.destination", "flow.vlan.id", "flow.vlan.source", "flow.vlan.destination"},
+		put: func(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+			attrs.PutStr("flow.mac.source", macString(pm.SrcMac))
+			attrs.PutStr("flow.mac.destination", macString(pm.DstMac))
+			attrs.PutInt("flow.vlan.id", int64(pm.VlanId))
+			attrs.PutInt("flow.vlan.source", int64(pm.SrcVlan))
+			attrs.PutInt("flow.vlan.destination", int64(pm.DstVlan))
+		},
+	},
+	fieldSetMPLS: {
+		attributes: []string{"flow.mpls.labels", "flow.mpls.ttls", "flow.mpls.addresses"},
+		put: func(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+			putIntSlice(attrs, "flow.mpls.labels", pm.MplsLabel)
+			putIntSlice(attrs, "flow.mpls.ttls", pm.MplsTtl)
+			if len(pm.MplsIp) > 0 {
+				addresses := attrs.PutEmptySlice("flow.mpls.addresses")
+				addresses.EnsureCapacity(len(pm.MplsIp))
+				for _, ip := range pm.MplsIp {
+					addr, _ := netip.AddrFromSlice(ip)
+					addresses.AppendEmpty().SetStr(addr.String())
+				}
+			}
+		},
+	},
+	// The headers sampled by sFlow are decoded again for the details goflow2 does not keep, see sampledHeaderProducer
+	fieldSetHeaders: {
+		attributes: []string{
+			"flow.vlan.ids", "flow.tcp.window", "flow.tunnel.type", "flow.tunnel.id",
+			"flow.inner.source.address", "flow.inner.source.port", "flow.inner.destination.address", "flow.inner.destination.port",
+			"flow.inner.network.transport", "flow.inner.network.type", "flow.inner.tcp.flags", "flow.inner.tcp.window",
+		},
+		put: putHeaderAttributes,
+	},
+}
+
+// fieldSetOf returns the field set that adds the attribute
+func fieldSetOf(attribute string) (string, bool) {
+	for name, fs := range fieldSets {
+		for _, attr := range fs.attributes {
+			if attr == attribute {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// allFlowAttributes returns a map with every attribute that can be extracted from a flow
+func allFlowAttributes() pcommon.Map {
+	attrs := pcommon.NewMap()
+	addFlowAttributes(&protoproducer.ProtoProducerMessage{}, attrs)
+	for _, fs := range fieldSets {
+		for _, attr := range fs.attributes {
+			attrs.PutEmpty(attr)
+		}
+	}
+	for _, attr := range reverseFieldAttributes() {
+		attrs.PutEmpty(attr)
+	}
+	for _, attr := range firewallFieldAttributes() {
+		attrs.PutEmpty(attr)
+	}
+	for _, attr := range applicationAttributes {
+		attrs.PutEmpty(attr)
+	}
+	return attrs
+}
+
+// macString formats the 48 bits MAC address that goflow2 stores in a uint64
+func macString(mac uint64) string {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, mac)
+	return net.HardwareAddr(b[2:]).String()
+}
+
+// putAddress adds an IP address attribute, it is skipped when the device did not send the address
+func putAddress(attrs pcommon.Map, key string, ip []byte) {
+	if addr, ok := netip.AddrFromSlice(ip); ok {
+		attrs.PutStr(key, addr.String())
+	}
+}
+
+// putIntSlice adds a slice attribute, it is skipped when the slice is empty
+func putIntSlice(attrs pcommon.Map, key string, values []uint32) {
+	if len(values) == 0 {
+		return
+	}
+	s := attrs.PutEmptySlice(key)
+	s.EnsureCapacity(len(values))
+	for _, v := range values {
+		s.AppendEmpty().SetInt(int64(v))
+	}
+}
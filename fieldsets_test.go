@@ -0,0 +1,100 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/netsampler/goflow2/v2/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+func TestFieldSets(t *testing.T) {
+	pm := &protoproducer.ProtoProducerMessage{}
+	pm.InIf = 3
+	pm.OutIf = 4
+	pm.IpTos = 0xb9 // dscp 46, ecn 1
+	pm.IpTtl = 64
+	pm.TcpFlags = 0x12
+	pm.IcmpType = 8
+	pm.SrcAs = 65001
+	pm.DstAs = 65002
+	pm.AsPath = []uint32{65001, 65003}
+	pm.NextHop = []byte{10, 0, 0, 254}
+	pm.SrcMac = 0x0a1b2c3d4e5f
+	pm.VlanId = 100
+	pm.MplsLabel = []uint32{16, 17}
+	pm.MplsIp = [][]byte{{10, 1, 1, 1}}
+
+	attrs := pcommon.NewMap()
+	for _, fs := range fieldSets {
+		fs.enrich(pm, attrs)
+	}
+
+	assertIntAttribute(t, attrs, "flow.interface.in.index", 3)
+	assertIntAttribute(t, attrs, "flow.interface.out.index", 4)
+	assertIntAttribute(t, attrs, "flow.ip.tos", 0xb9)
+	assertIntAttribute(t, attrs, "flow.ip.dscp", 46)
+	assertIntAttribute(t, attrs, "flow.ip.ecn", 1)
+	assertIntAttribute(t, attrs, "flow.ip.ttl", 64)
+	assertIntAttribute(t, attrs, "flow.tcp.flags", 0x12)
+	assertIntAttribute(t, attrs, "flow.icmp.type", 8)
+	assertIntAttribute(t, attrs, "flow.as.source", 65001)
+	assertIntAttribute(t, attrs, "flow.as.destination", 65002)
+	assertStrAttribute(t, attrs, "flow.next_hop.address", "10.0.0.254")
+	assertStrAttribute(t, attrs, "flow.mac.source", "0a:1b:2c:3d:4e:5f")
+	assertStrAttribute(t, attrs, "flow.mac.destination", "00:00:00:00:00:00")
+	assertIntAttribute(t, attrs, "flow.vlan.id", 100)
+
+	asPath, ok := attrs.Get("flow.as.path")
+	require.True(t, ok)
+	assert.Equal(t, []any{int64(65001), int64(65003)}, asPath.Slice().AsRaw())
+	labels, ok := attrs.Get("flow.mpls.labels")
+	require.True(t, ok)
+	assert.Equal(t, []any{int64(16), int64(17)}, labels.Slice().AsRaw())
+	addresses, ok := attrs.Get("flow.mpls.addresses")
+	require.True(t, ok)
+	assert.Equal(t, []any{"10.1.1.1"}, addresses.Slice().AsRaw())
+
+	// Optional values that the device did not send are skipped
+	for _, key := range []string{"flow.bgp.next_hop.address", "flow.bgp.communities", "flow.mpls.ttls", "flow.direction"} {
+		_, ok := attrs.Get(key)
+		assert.False(t, ok, key)
+	}
+
+	// Every attribute that is added is listed by its field set
+	attrs.Range(func(k string, _ pcommon.Value) bool {
+		_, ok := fieldSetOf(k)
+		assert.True(t, ok, k)
+		return true
+	})
+}
+
+func TestFieldSetsFlowDirection(t *testing.T) {
+	cfgm, err := (&MappingConfig{}).producerConfig().Compile()
+	require.NoError(t, err)
+	protoProducer, err := protoproducer.CreateProtoProducer(cfgm, protoproducer.CreateSamplingSystem)
+	require.NoError(t, err)
+
+	sink := &consumertest.LogsSink{}
+	pipe := utils.NewNetFlowPipe(&utils.PipeConfig{
+		Producer: newOtelLogsProducer(protoProducer, sink, zap.NewNop(), fieldSets[fieldSetInterfaces]),
+	})
+	defer pipe.Close()
+
+	err = pipe.DecodeFlow(&utils.Message{Src: netip.MustParseAddrPort("127.0.0.1:5000"), Payload: ipfixPacket(), Received: time.Now()})
+	require.NoError(t, err)
+
+	require.Len(t, sink.AllLogs(), 1)
+	attrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes()
+	assertStrAttribute(t, attrs, "flow.direction", "egress")
+	assertIntAttribute(t, attrs, "flow.interface.in.index", 7)
+}
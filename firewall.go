@@ -0,0 +1,316 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	semconv "go.opentelemetry.io/collector/semconv/v1.27.0"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// firewallFirstIndex is the protobuf index where goflow2 stores the first NSEL or NEL field
+	// The indexes up to reverseFirstIndex are reserved for them
+	firewallFirstIndex = 950
+
+	// The NSEL and NEL fields that describe the event, rather than the flow
+	firewallEventType = 233
+	natEventType      = 230
+	eventTimeType     = 323
+	// firewallEventLegacyType is the firewall event of the ASA versions before 8.4
+	firewallEventLegacyType = 40005
+
+	// The counters of both directions of the connection, the ASA sends the traffic since its last update
+	initiatorOctetsType  = 231
+	responderOctetsType  = 232
+	initiatorPacketsType = 298
+	responderPacketsType = 299
+
+	// The firewall events whose records carry the traffic of the connection
+	firewallEventDeleted = 2
+	firewallEventUpdated = 5
+)
+
+// firewallField is a field of the Cisco NSEL (NetFlow Security Event Logging) and NEL (NAT Event Logging) records
+// NSEL is only exported with NetFlow v9, NEL uses the IANA elements that IPFIX devices also send
+type firewallField struct {
+	// name is the name of the element in the IANA registry or in the Cisco documentation, it is the name of the custom field in goflow2
+	name   string
+	ieType uint16
+	// fieldType is the type of the custom field, varint or bytes
+	fieldType string
+	// attr is the attribute the field is added as, empty for the fields that only describe the event
+	attr string
+	put  func(attrs pcommon.Map, key string, value uint64, raw []byte)
+}
+
+// firewallFields are the NSEL and NEL fields added to the attributes of the flows and events
+var firewallFields = []firewallField{
+	{name: "firewallEvent", ieType: firewallEventType, fieldType: customFieldTypeVarint, attr: "flow.firewall.event", put: putEventName(firewallEventNames)},
+	{name: "NF_F_FW_EVENT_84", ieType: firewallEventLegacyType, fieldType: customFieldTypeVarint, attr: "flow.firewall.event", put: putEventName(firewallEventNames)},
+	{name: "NF_F_FW_EXT_EVENT", ieType: 33002, fieldType: customFieldTypeVarint, attr: "flow.firewall.extended_event", put: putFirewallInt},
+	{name: "natEvent", ieType: natEventType, fieldType: customFieldTypeVarint, attr: "flow.nat.event", put: putEventName(natEventNames)},
+	{name: "observationTimeMilliseconds", ieType: eventTimeType, fieldType: customFieldTypeVarint},
+	{name: "postNATSourceIPv4Address", ieType: 225, fieldType: customFieldTypeBytes, attr: "flow.nat.source.address", put: putFirewallAddress},
+	{name: "postNATDestinationIPv4Address", ieType: 226, fieldType: customFieldTypeBytes, attr: "flow.nat.destination.address", put: putFirewallAddress},
+	{name: "postNATSourceIPv6Address", ieType: 281, fieldType: customFieldTypeBytes, attr: "flow.nat.source.address", put: putFirewallAddress},
+	{name: "postNATDestinationIPv6Address", ieType: 282, fieldType: customFieldTypeBytes, attr: "flow.nat.destination.address", put: putFirewallAddress},
+	{name: "postNAPTSourceTransportPort", ieType: 227, fieldType: customFieldTypeVarint, attr: "flow.nat.source.port", put: putFirewallInt},
+	{name: "postNAPTDestinationTransportPort", ieType: 228, fieldType: customFieldTypeVarint, attr: "flow.nat.destination.port", put: putFirewallInt},
+	{name: "NF_F_XLATE_SRC_ADDR_84", ieType: 40001, fieldType: customFieldTypeBytes, attr: "flow.nat.source.address", put: putFirewallAddress},
+	{name: "NF_F_XLATE_DST_ADDR_84", ieType: 40002, fieldType: customFieldTypeBytes, attr: "flow.nat.destination.address", put: putFirewallAddress},
+	{name: "NF_F_XLATE_SRC_PORT_84", ieType: 40003, fieldType: customFieldTypeVarint, attr: "flow.nat.source.port", put: putFirewallInt},
+	{name: "NF_F_XLATE_DST_PORT_84", ieType: 40004, fieldType: customFieldTypeVarint, attr: "flow.nat.destination.port", put: putFirewallInt},
+	{name: "NF_F_INGRESS_ACL_ID", ieType: 33000, fieldType: customFieldTypeBytes, attr: "flow.firewall.acl.ingress", put: putACL},
+	{name: "NF_F_EGRESS_ACL_ID", ieType: 33001, fieldType: customFieldTypeBytes, attr: "flow.firewall.acl.egress", put: putACL},
+	{name: "NF_F_USERNAME", ieType: 40000, fieldType: customFieldTypeBytes, attr: "flow.firewall.user", put: putUsername},
+	{name: "initiatorOctets", ieType: initiatorOctetsType, fieldType: customFieldTypeVarint, attr: "flow.initiator.io.bytes", put: putFirewallInt},
+	{name: "responderOctets", ieType: responderOctetsType, fieldType: customFieldTypeVarint, attr: "flow.responder.io.bytes", put: putFirewallInt},
+	{name: "initiatorPackets", ieType: initiatorPacketsType, fieldType: customFieldTypeVarint, attr: "flow.initiator.io.packets", put: putFirewallInt},
+	{name: "responderPackets", ieType: responderPacketsType, fieldType: customFieldTypeVarint, attr: "flow.responder.io.packets", put: putFirewallInt},
+}
+
+// firewallEventNames are the values of the NSEL NF_F_FW_EVENT field and of the IPFIX firewallEvent element
+var firewallEventNames = map[uint64]string{
+	0: "ignore",
+	1: "flow_created",
+	2: "flow_deleted",
+	3: "flow_denied",
+	4: "flow_alert",
+	5: "flow_updated",
+}
+
+// natEventNames are the values of the natEvent element, the NEL records only use the first two
+// https://www.iana.org/assignments/ipfix/ipfix.xhtml#ipfix-nat-event-type
+var natEventNames = map[uint64]string{
+	1:  "nat44_session_create",
+	2:  "nat44_session_delete",
+	3:  "nat_addresses_exhausted",
+	4:  "nat64_session_create",
+	5:  "nat64_session_delete",
+	6:  "nat44_bib_create",
+	7:  "nat44_bib_delete",
+	8:  "nat64_bib_create",
+	9:  "nat64_bib_delete",
+	10: "nat_ports_exhausted",
+	11: "quota_exceeded",
+	12: "address_binding_create",
+	13: "address_binding_delete",
+	14: "port_block_allocation",
+	15: "port_block_deallocation",
+	16: "threshold_reached",
+}
+
+// firewallEventSeverities are the severities of the firewall events that are not informational
+var firewallEventSeverities = map[uint64]plog.SeverityNumber{
+	3: plog.SeverityNumberWarn,
+	4: plog.SeverityNumberError,
+}
+
+// natEventSeverities are the severities of the NAT events that are not informational, the exhausted resources
+var natEventSeverities = map[uint64]plog.SeverityNumber{
+	3:  plog.SeverityNumberError,
+	10: plog.SeverityNumberError,
+	11: plog.SeverityNumberWarn,
+	16: plog.SeverityNumberWarn,
+}
+
+// firewallFieldsByIndex are the NSEL and NEL fields by the protobuf index goflow2 stores them under
+var firewallFieldsByIndex = func() map[protowire.Number]firewallField {
+	fields := make(map[protowire.Number]firewallField, len(firewallFields))
+	for i, field := range firewallFields {
+		fields[protowire.Number(firewallFirstIndex+i)] = field
+	}
+	return fields
+}()
+
+// firewallFieldAttributes returns the attributes the NSEL and NEL fields can add
+func firewallFieldAttributes() []string {
+	attrs := make([]string, 0, len(firewallFields))
+	for _, field := range firewallFields {
+		if field.attr != "" {
+			attrs = append(attrs, field.attr)
+		}
+	}
+	return attrs
+}
+
+// isFirewallFieldName returns true if the name is the custom field of a NSEL or NEL field, it cannot be used by the mappings
+func isFirewallFieldName(name string) bool {
+	for _, field := range firewallFields {
+		if field.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// firewallFormatter declares the custom fields of the NSEL and NEL fields so goflow2 stores them
+func firewallFormatter() []protoproducer.ProtobufFormatterConfig {
+	formatter := make([]protoproducer.ProtobufFormatterConfig, 0, len(firewallFields))
+	for i, field := range firewallFields {
+		formatter = append(formatter, protoproducer.ProtobufFormatterConfig{
+			Name:  field.name,
+			Index: int32(firewallFirstIndex + i),
+			Type:  field.fieldType,
+		})
+	}
+	return formatter
+}
+
+// firewallMapping maps the NSEL and NEL fields to their custom fields
+// The Cisco fields above 32767 never match in IPFIX, where those types always have an enterprise number
+func firewallMapping() []protoproducer.NetFlowMapField {
+	mapping := make([]protoproducer.NetFlowMapField, 0, len(firewallFields))
+	for _, field := range firewallFields {
+		mapping = append(mapping, protoproducer.NetFlowMapField{
+			Type:        field.ieType,
+			Destination: field.name,
+		})
+	}
+	return mapping
+}
+
+// firewallEnricher adds the NSEL and NEL fields, only the fields the device sent are added
+type firewallEnricher struct{}
+
+func (firewallEnricher) enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	rangeUnknownFields(pm, func(num protowire.Number, value uint64, raw []byte) {
+		if field, ok := firewallFieldsByIndex[num]; ok && field.attr != "" {
+			field.put(attrs, field.attr, value, raw)
+		}
+	})
+}
+
+func putFirewallInt(attrs pcommon.Map, key string, value uint64, _ []byte) {
+	attrs.PutInt(key, int64(value))
+}
+
+func putFirewallAddress(attrs pcommon.Map, key string, _ uint64, raw []byte) {
+	putAddress(attrs, key, raw)
+}
+
+// putACL adds the ACL id, the ACE id and the extended ACE id of a NSEL ACL field, as hexadecimal numbers
+func putACL(attrs pcommon.Map, key string, _ uint64, raw []byte) {
+	if len(raw) != 12 {
+		return
+	}
+	attrs.PutStr(key, fmt.Sprintf("%08x-%08x-%08x",
+		binary.BigEndian.Uint32(raw), binary.BigEndian.Uint32(raw[4:]), binary.BigEndian.Uint32(raw[8:])))
+}
+
+// putUsername adds the user of a NSEL record, the field is padded with zeros
+func putUsername(attrs pcommon.Map, key string, _ uint64, raw []byte) {
+	if user := strings.TrimRight(string(raw), "\x00"); user != "" {
+		attrs.PutStr(key, user)
+	}
+}
+
+func putEventName(names map[uint64]string) func(attrs pcommon.Map, key string, value uint64, _ []byte) {
+	return func(attrs pcommon.Map, key string, value uint64, _ []byte) {
+		attrs.PutStr(key, eventName(names, value))
+	}
+}
+
+func eventName(names map[uint64]string, value uint64) string {
+	if name, ok := names[value]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// securityEvent is the firewall or NAT event of a NSEL or NEL record
+type securityEvent struct {
+	name     string
+	severity plog.SeverityNumber
+	// timeMs is the time of the event in milliseconds, zero when the device did not send it
+	timeMs uint64
+	// hasTraffic is set for the deleted and updated flows, bytes and packets are the traffic of both directions
+	hasTraffic     bool
+	bytes, packets uint64
+}
+
+// getSecurityEvent returns the event of a NSEL or NEL record
+// The records without event, or with the NSEL event 0 that the devices send in their flow updates, are flows
+func getSecurityEvent(pm *protoproducer.ProtoProducerMessage) (securityEvent, bool) {
+	var event securityEvent
+	var found bool
+	rangeUnknownFields(pm, func(num protowire.Number, value uint64, _ []byte) {
+		field, ok := firewallFieldsByIndex[num]
+		if !ok {
+			return
+		}
+		switch field.ieType {
+		case firewallEventType, firewallEventLegacyType:
+			if value == 0 {
+				return
+			}
+			found = true
+			event.name = "firewall." + eventName(firewallEventNames, value)
+			event.severity = eventSeverity(firewallEventSeverities, value)
+			event.hasTraffic = value == firewallEventDeleted || value == firewallEventUpdated
+		case natEventType:
+			if value == 0 {
+				return
+			}
+			found = true
+			event.name = "nat." + eventName(natEventNames, value)
+			event.severity = eventSeverity(natEventSeverities, value)
+		case eventTimeType:
+			event.timeMs = value
+		case initiatorOctetsType, responderOctetsType:
+			event.bytes += value
+		case initiatorPacketsType, responderPacketsType:
+			event.packets += value
+		}
+	})
+	return event, found
+}
+
+func eventSeverity(severities map[uint64]plog.SeverityNumber, value uint64) plog.SeverityNumber {
+	if severity, ok := severities[value]; ok {
+		return severity
+	}
+	return plog.SeverityNumberInfo
+}
+
+// addEventAttributes adds the attributes of a firewall or NAT event to a log record
+// The events describe a connection rather than the traffic, so the counters and timestamps of flows are left out
+func addEventAttributes(pm *protoproducer.ProtoProducerMessage, event securityEvent, r *plog.LogRecord, enrichers ...flowEnricher) {
+	attrs := r.Attributes()
+	srcAddr, _ := netip.AddrFromSlice(pm.SrcAddr)
+	dstAddr, _ := netip.AddrFromSlice(pm.DstAddr)
+	samplerAddr, _ := netip.AddrFromSlice(pm.SamplerAddress)
+	attrs.PutStr(semconv.AttributeSourceAddress, srcAddr.String())
+	attrs.PutInt(semconv.AttributeSourcePort, int64(pm.SrcPort))
+	attrs.PutStr(semconv.AttributeDestinationAddress, dstAddr.String())
+	attrs.PutInt(semconv.AttributeDestinationPort, int64(pm.DstPort))
+	attrs.PutStr(semconv.AttributeNetworkTransport, getTransportName(pm.Proto))
+	attrs.PutStr(semconv.AttributeNetworkType, getEtypeName(pm.Etype))
+	attrs.PutStr("flow.type", getFlowTypeName(int32(pm.Type)))
+	attrs.PutInt("flow.time_received", int64(pm.TimeReceivedNs))
+	attrs.PutStr("flow.sampler_address", samplerAddr.String())
+	for _, enricher := range enrichers {
+		enricher.enrich(pm, attrs)
+	}
+
+	r.SetEventName(event.name)
+	r.SetSeverityNumber(event.severity)
+	r.SetSeverityText(event.severity.String())
+
+	receivedTime := pcommon.NewTimestampFromTime(time.Unix(0, int64(pm.TimeReceivedNs)))
+	r.SetObservedTimestamp(receivedTime)
+	r.SetTimestamp(receivedTime)
+	if event.timeMs != 0 {
+		r.SetTimestamp(pcommon.NewTimestampFromTime(time.UnixMilli(int64(event.timeMs))))
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// nselPacket builds a NetFlow v9 packet with a NSEL record of a TCP connection from 192.168.0.1:40000 to 198.51.100.7:443
+// The record has the firewall event, the extended event, the post-NAT source, the ingress ACL, the event time, the user
+// and the traffic of both directions
+func nselPacket(event uint8) []byte {
+	template := []byte{
+		0, 0, 0, 0, // template flowset, length
+		1, 0, 0, 16, // template 256 with 16 fields
+		0, 8, 0, 4, // IPV4_SRC_ADDR
+		0, 12, 0, 4, // IPV4_DST_ADDR
+		0, 7, 0, 2, // L4_SRC_PORT
+		0, 11, 0, 2, // L4_DST_PORT
+		0, 4, 0, 1, // PROTOCOL
+		0, 233, 0, 1, // NF_F_FW_EVENT
+		0x80, 0xea, 0, 2, // NF_F_FW_EXT_EVENT
+		0, 225, 0, 4, // NF_F_XLATE_SRC_ADDR_IPV4
+		0, 227, 0, 2, // NF_F_XLATE_SRC_PORT
+		0x80, 0xe8, 0, 12, // NF_F_INGRESS_ACL_ID
+		1, 0x43, 0, 8, // NF_F_EVENT_TIME_MSEC
+		0x9c, 0x40, 0, 20, // NF_F_USERNAME
+		0, 231, 0, 4, // NF_F_FWD_FLOW_DELTA_BYTES
+		0, 232, 0, 4, // NF_F_REV_FLOW_DELTA_BYTES
+		1, 0x2a, 0, 4, // initiatorPackets
+		1, 0x2b, 0, 4, // responderPackets
+	}
+	binary.BigEndian.PutUint16(template[2:], uint16(len(template)))
+
+	data := []byte{1, 0, 0, 0}
+	data = append(data, 192, 168, 0, 1, 198, 51, 100, 7)
+	data = binary.BigEndian.AppendUint16(data, 40000)
+	data = binary.BigEndian.AppendUint16(data, 443)
+	data = append(data, 6, event)
+	data = binary.BigEndian.AppendUint16(data, 1001)
+	data = append(data, 203, 0, 113, 5)
+	data = binary.BigEndian.AppendUint16(data, 61000)
+	data = binary.BigEndian.AppendUint32(data, 0x1a2b3c4d)
+	data = binary.BigEndian.AppendUint32(data, 0x10)
+	data = binary.BigEndian.AppendUint32(data, 0)
+	data = binary.BigEndian.AppendUint64(data, 1700000000123)
+	data = append(data, make([]byte, 20)...)
+	copy(data[len(data)-20:], "alice")
+	data = binary.BigEndian.AppendUint32(data, 600)
+	data = binary.BigEndian.AppendUint32(data, 9000)
+	data = binary.BigEndian.AppendUint32(data, 8)
+	data = binary.BigEndian.AppendUint32(data, 10)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)))
+
+	header := make([]byte, 20)
+	binary.BigEndian.PutUint16(header[0:], 9)
+	binary.BigEndian.PutUint16(header[2:], 2)  // count
+	binary.BigEndian.PutUint32(header[16:], 1) // source id
+
+	return append(append(header, template...), data...)
+}
+
+// firstLogRecord returns the first log record of the logs
+func firstLogRecord(t *testing.T, logs []plog.Logs) plog.LogRecord {
+	t.Helper()
+	require.NotEmpty(t, logs)
+	return logs[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+}
+
+func TestFirewallEvent(t *testing.T) {
+	decode, sink := newTestDefaultDecoder(t)
+
+	require.NoError(t, decode(testMessage(nselPacket(3))))
+	require.Equal(t, 1, sink.LogRecordCount())
+	record := firstLogRecord(t, sink.AllLogs())
+
+	assert.Equal(t, "firewall.flow_denied", record.EventName())
+	assert.Equal(t, plog.SeverityNumberWarn, record.SeverityNumber())
+	assert.Equal(t, "Warn", record.SeverityText())
+	assert.Equal(t, pcommon.Timestamp(1700000000123000000), record.Timestamp())
+
+	attrs := record.Attributes()
+	assertStrAttribute(t, attrs, "source.address", "192.168.0.1")
+	assertIntAttribute(t, attrs, "destination.port", 443)
+	assertStrAttribute(t, attrs, "network.transport", "tcp")
+	assertStrAttribute(t, attrs, "flow.type", "netflow_v9")
+	assertStrAttribute(t, attrs, "flow.firewall.event", "flow_denied")
+	assertIntAttribute(t, attrs, "flow.firewall.extended_event", 1001)
+	assertStrAttribute(t, attrs, "flow.nat.source.address", "203.0.113.5")
+	assertIntAttribute(t, attrs, "flow.nat.source.port", 61000)
+	assertStrAttribute(t, attrs, "flow.firewall.acl.ingress", "1a2b3c4d-00000010-00000000")
+	assertStrAttribute(t, attrs, "flow.firewall.user", "alice")
+	assertIntAttribute(t, attrs, "flow.initiator.io.bytes", 600)
+	assertIntAttribute(t, attrs, "flow.responder.io.bytes", 9000)
+	assertIntAttribute(t, attrs, "flow.initiator.io.packets", 8)
+	assertIntAttribute(t, attrs, "flow.responder.io.packets", 10)
+	for _, key := range []string{"flow.io.bytes", "flow.start", "flow.nat.destination.address", "flow.firewall.acl.egress"} {
+		_, ok := attrs.Get(key)
+		assert.False(t, ok, key)
+	}
+}
+
+func TestFirewallEventIgnored(t *testing.T) {
+	decode, sink := newTestDefaultDecoder(t)
+
+	// The event 0 is sent in the updates of long lived flows, they are logged as flows
+	require.NoError(t, decode(testMessage(nselPacket(0))))
+	record := firstLogRecord(t, sink.AllLogs())
+	assert.Empty(t, record.EventName())
+	assert.Equal(t, plog.SeverityNumberUnspecified, record.SeverityNumber())
+	assertIntAttribute(t, record.Attributes(), "flow.io.bytes", 0)
+	assertStrAttribute(t, record.Attributes(), "flow.nat.source.address", "203.0.113.5")
+}
+
+// newTestNATEvent returns a NEL record of the NAT event
+func newTestNATEvent(src string, natEvent uint64) *protoproducer.ProtoProducerMessage {
+	pm := newTestFlow(src, 6, 0, 0, 0, 0)
+	for i, field := range firewallFields {
+		if field.ieType == natEventType {
+			unknown := protowire.AppendTag(nil, protowire.Number(firewallFirstIndex+i), protowire.VarintType)
+			pm.ProtoReflect().SetUnknown(protowire.AppendVarint(unknown, natEvent))
+		}
+	}
+	return pm
+}
+
+func TestNATEvent(t *testing.T) {
+	event, ok := getSecurityEvent(newTestNATEvent("192.168.0.1", 3)) // nat addresses exhausted
+	require.True(t, ok)
+	assert.Equal(t, "nat.nat_addresses_exhausted", event.name)
+	assert.Equal(t, plog.SeverityNumberError, event.severity)
+
+	_, ok = getSecurityEvent(newTestFlow("192.168.0.1", 6, 0, 0, 0, 0))
+	assert.False(t, ok)
+}
+
+func TestFirewallEventMetrics(t *testing.T) {
+	nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *createDefaultConfig().(*Config))
+	require.NoError(t, err)
+	sink := &consumertest.MetricsSink{}
+	nr.metricsConsumer = sink
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+
+	// The events are not flows, a packet with only events has no data points
+	require.NoError(t, decode(testMessage(nselPacket(3))))
+	assert.Empty(t, sink.AllMetrics())
+
+	require.NoError(t, decode(testMessage(nselPacket(0))))
+	require.Len(t, sink.AllMetrics(), 1)
+	assert.Equal(t, 3, sink.AllMetrics()[0].DataPointCount())
+
+	// The deleted flows carry the traffic of both directions of the connection
+	require.NoError(t, decode(testMessage(nselPacket(2))))
+	require.Len(t, sink.AllMetrics(), 2)
+	assert.Equal(t, map[string]int64{metricBytes: 9600, metricPackets: 18, metricFlows: 1}, firstDataPoints(sink.AllMetrics()[1]))
+
+	metrics := buildMetrics([]producer.ProducerMessage{
+		newTestNATEvent("192.168.0.1", 1),
+		newTestFlow("192.168.0.1", 6, 100, 1, 0, 0),
+	}, nil)
+	assert.Equal(t, map[string]int64{metricBytes: 100, metricPackets: 1, metricFlows: 1}, firstDataPoints(metrics))
+}
+
+// firstDataPoints returns the value of the first data point of every flow metric
+func firstDataPoints(metrics pmetric.Metrics) map[string]int64 {
+	values := map[string]int64{}
+	ms := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < ms.Len(); i++ {
+		values[ms.At(i).Name()] = ms.At(i).Sum().DataPoints().At(0).IntValue()
+	}
+	return values
+}
+
+func TestMappingFirewallFieldReserved(t *testing.T) {
+	mapping := MappingConfig{
+		NetFlowV9: []NetFlowFieldMapping{
+			{Field: 233, FieldDestination: FieldDestination{Destination: "firewallEvent"}},
+		},
+	}
+	assert.EqualError(t, mapping.Validate(), `mapping destination "firewallEvent" is reserved`)
+
+	mapping.NetFlowV9[0].Destination = "flow.nat.source.port"
+	assert.EqualError(t, mapping.Validate(), `mapping destination "flow.nat.source.port" is a flow attribute`)
+}
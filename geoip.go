@@ -0,0 +1,157 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/oschwald/maxminddb-golang"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+// reservedPrefixes are the ranges that are not routed on the internet, besides the private and loopback ones
+// https://www.iana.org/assignments/iana-ipv4-special-registry/iana-ipv4-special-registry.xhtml
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// geoCityRecord is the part of a GeoLite2-City record that is added to the flows
+type geoCityRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Continent struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"continent"`
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// geoASNRecord is a GeoLite2-ASN record
+type geoASNRecord struct {
+	Number       uint32 `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// geoIPEnricher adds the location and the autonomous system of the source and destination addresses
+type geoIPEnricher struct {
+	city *fileReloader[*maxminddb.Reader]
+	asn  *fileReloader[*maxminddb.Reader]
+}
+
+func newGeoIPEnricher(cfg GeoIPConfig, logger *zap.Logger) *geoIPEnricher {
+	e := &geoIPEnricher{}
+	if cfg.CityDatabase != "" {
+		e.city = newFileReloader(cfg.CityDatabase, cfg.ReloadInterval, logger, loadMaxMindDB)
+	}
+	if cfg.ASNDatabase != "" {
+		e.asn = newFileReloader(cfg.ASNDatabase, cfg.ReloadInterval, logger, loadMaxMindDB)
+	}
+	return e
+}
+
+// loadMaxMindDB reads the whole database in memory
+// Unlike a memory mapped file, the previous database can still be used while a new version of the file is written
+func loadMaxMindDB(path string) (*maxminddb.Reader, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return maxminddb.FromBytes(b)
+}
+
+func (e *geoIPEnricher) start() error {
+	if e.city != nil {
+		if err := e.city.start(); err != nil {
+			return fmt.Errorf("failed to load the geoip city database: %w", err)
+		}
+	}
+	if e.asn != nil {
+		if err := e.asn.start(); err != nil {
+			e.stop()
+			return fmt.Errorf("failed to load the geoip asn database: %w", err)
+		}
+	}
+	return nil
+}
+
+func (e *geoIPEnricher) stop() {
+	if e.city != nil {
+		e.city.stop()
+	}
+	if e.asn != nil {
+		e.asn.stop()
+	}
+}
+
+func (e *geoIPEnricher) enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	e.enrichAddress(pm.SrcAddr, "source", attrs)
+	e.enrichAddress(pm.DstAddr, "destination", attrs)
+}
+
+func (e *geoIPEnricher) enrichAddress(ip []byte, prefix string, attrs pcommon.Map) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok || !isPublicAddress(addr.Unmap()) {
+		return
+	}
+
+	if e.city != nil {
+		var record geoCityRecord
+		if err := e.city.get().Lookup(net.IP(ip), &record); err == nil {
+			putNonEmptyStr(attrs, prefix+".geo.country.iso_code", record.Country.ISOCode)
+			putNonEmptyStr(attrs, prefix+".geo.continent.code", record.Continent.Code)
+			putNonEmptyStr(attrs, prefix+".geo.locality.name", record.City.Names["en"])
+			if record.Location.Latitude != nil && record.Location.Longitude != nil {
+				attrs.PutDouble(prefix+".geo.location.lat", *record.Location.Latitude)
+				attrs.PutDouble(prefix+".geo.location.lon", *record.Location.Longitude)
+			}
+		}
+	}
+
+	if e.asn != nil {
+		var record geoASNRecord
+		if err := e.asn.get().Lookup(net.IP(ip), &record); err == nil && record.Number != 0 {
+			attrs.PutInt(prefix+".as.number", int64(record.Number))
+			putNonEmptyStr(attrs, prefix+".as.organization.name", record.Organization)
+		}
+	}
+}
+
+// isPublicAddress returns false for the addresses that cannot be found in a geoip database
+func isPublicAddress(addr netip.Addr) bool {
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// putNonEmptyStr adds a string attribute unless the value is empty
+func putNonEmptyStr(attrs pcommon.Map, key, value string) {
+	if value != "" {
+		attrs.PutStr(key, value)
+	}
+}
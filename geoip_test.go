@@ -0,0 +1,137 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+// writeTestMMDB writes a MaxMind database with a single record for the network
+func writeTestMMDB(t *testing.T, path, databaseType, network string, record mmdbtype.Map) {
+	t.Helper()
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DatabaseType: databaseType, RecordSize: 24})
+	require.NoError(t, err)
+	_, ipNet, err := net.ParseCIDR(network)
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(ipNet, record))
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = tree.WriteTo(f)
+	require.NoError(t, err)
+}
+
+func testCityRecord(isoCode, city string) mmdbtype.Map {
+	return mmdbtype.Map{
+		"city":      mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String(city)}},
+		"continent": mmdbtype.Map{"code": mmdbtype.String("EU")},
+		"country":   mmdbtype.Map{"iso_code": mmdbtype.String(isoCode)},
+		"location":  mmdbtype.Map{"latitude": mmdbtype.Float64(48.2), "longitude": mmdbtype.Float64(16.37)},
+	}
+}
+
+func TestGeoIPEnricher(t *testing.T) {
+	dir := t.TempDir()
+	cityPath := filepath.Join(dir, "city.mmdb")
+	asnPath := filepath.Join(dir, "asn.mmdb")
+	writeTestMMDB(t, cityPath, "GeoLite2-City", "81.0.0.0/8", testCityRecord("AT", "Vienna"))
+	writeTestMMDB(t, asnPath, "GeoLite2-ASN", "81.0.0.0/8", mmdbtype.Map{
+		"autonomous_system_number":       mmdbtype.Uint32(8447),
+		"autonomous_system_organization": mmdbtype.String("A1 Telekom Austria AG"),
+	})
+
+	enricher := newGeoIPEnricher(GeoIPConfig{CityDatabase: cityPath, ASNDatabase: asnPath}, zap.NewNop())
+	require.NoError(t, enricher.start())
+	defer enricher.stop()
+
+	pm := &protoproducer.ProtoProducerMessage{}
+	pm.SrcAddr = netip.MustParseAddr("81.10.20.30").AsSlice()
+	pm.DstAddr = netip.MustParseAddr("192.168.1.1").AsSlice()
+
+	attrs := pcommon.NewMap()
+	enricher.enrich(pm, attrs)
+
+	assertStrAttribute(t, attrs, "source.geo.country.iso_code", "AT")
+	assertStrAttribute(t, attrs, "source.geo.continent.code", "EU")
+	assertStrAttribute(t, attrs, "source.geo.locality.name", "Vienna")
+	lat, ok := attrs.Get("source.geo.location.lat")
+	require.True(t, ok)
+	assert.InDelta(t, 48.2, lat.Double(), 0.001)
+	assertIntAttribute(t, attrs, "source.as.number", 8447)
+	assertStrAttribute(t, attrs, "source.as.organization.name", "A1 Telekom Austria AG")
+
+	// Private addresses are not looked up
+	attrs.Range(func(k string, _ pcommon.Value) bool {
+		assert.NotContains(t, k, "destination.")
+		return true
+	})
+}
+
+func TestGeoIPEnricherReload(t *testing.T) {
+	cityPath := filepath.Join(t.TempDir(), "city.mmdb")
+	writeTestMMDB(t, cityPath, "GeoLite2-City", "81.0.0.0/8", testCityRecord("AT", "Vienna"))
+
+	enricher := newGeoIPEnricher(GeoIPConfig{CityDatabase: cityPath}, zap.NewNop())
+	require.NoError(t, enricher.start())
+	defer enricher.stop()
+
+	pm := &protoproducer.ProtoProducerMessage{}
+	pm.SrcAddr = netip.MustParseAddr("81.10.20.30").AsSlice()
+
+	attrs := pcommon.NewMap()
+	enricher.enrich(pm, attrs)
+	assertStrAttribute(t, attrs, "source.geo.country.iso_code", "AT")
+
+	writeTestMMDB(t, cityPath, "GeoLite2-City", "81.0.0.0/8", testCityRecord("DE", "Berlin"))
+	// The new database has the same size, so it is only detected by the modification time
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(cityPath, later, later))
+	reloaded, err := enricher.city.reload()
+	require.NoError(t, err)
+	assert.True(t, reloaded)
+
+	attrs.Clear()
+	enricher.enrich(pm, attrs)
+	assertStrAttribute(t, attrs, "source.geo.country.iso_code", "DE")
+	assertStrAttribute(t, attrs, "source.geo.locality.name", "Berlin")
+}
+
+func TestGeoIPEnricherMissingDatabase(t *testing.T) {
+	enricher := newGeoIPEnricher(GeoIPConfig{ASNDatabase: filepath.Join(t.TempDir(), "missing.mmdb")}, zap.NewNop())
+	assert.ErrorContains(t, enricher.start(), "failed to load the geoip asn database")
+}
+
+func TestIsPublicAddress(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"8.8.8.8":       true,
+		"2a00:1450::1":  true,
+		"10.1.2.3":      false,
+		"172.16.0.1":    false,
+		"127.0.0.1":     false,
+		"169.254.1.1":   false,
+		"100.64.0.1":    false,
+		"203.0.113.5":   false,
+		"224.0.0.1":     false,
+		"fd00::1":       false,
+		"2001:db8::1":   false,
+		"0.0.0.0":       false,
+		"255.255.255.0": false,
+	} {
+		assert.Equal(t, public, isPublicAddress(netip.MustParseAddr(addr)), addr)
+	}
+}
@@ -0,0 +1,408 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"encoding/binary"
+	"net/netip"
+
+	"github.com/netsampler/goflow2/v2/decoders/sflow"
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// sampledHeaderIndex is the protobuf index where the packet header sampled by sFlow is stored
+	// It is set by the sampled header producer, not by goflow2, and is below the indexes of the custom fields
+	sampledHeaderIndex = 997
+
+	// sflowHeaderProtocolEthernet is the header protocol of the sampled Ethernet frames
+	sflowHeaderProtocolEthernet = 1
+
+	etypeIPv4       = 0x0800
+	etypeIPv6       = 0x86dd
+	etypeVLAN       = 0x8100
+	etypeQinQ       = 0x88a8
+	etypeQinQLegacy = 0x9100
+	etypeMPLS       = 0x8847
+	etypeMPLSMulti  = 0x8848
+	etypeTEB        = 0x6558 // transparent Ethernet bridging, an Ethernet frame in GRE or Geneve
+
+	protoIPIP = 4
+	protoTCP  = 6
+	protoUDP  = 17
+	protoIPv6 = 41
+	protoGRE  = 47
+
+	portVXLAN  = 4789
+	portGeneve = 6081
+
+	tunnelVXLAN  = "vxlan"
+	tunnelGRE    = "gre"
+	tunnelGeneve = "geneve"
+	tunnelIPIP   = "ipip"
+)
+
+// headerTuple is the 5-tuple and TCP details of one of the IP layers of a sampled header
+type headerTuple struct {
+	found     bool
+	etype     uint32
+	src, dst  netip.Addr
+	proto     uint32
+	srcPort   uint32
+	dstPort   uint32
+	tcp       bool
+	tcpFlags  uint32
+	tcpWindow uint32
+}
+
+// sampledHeader holds the details of a sampled header that goflow2 does not decode
+// goflow2 only fills the flow message with the outer layers, the inner 5-tuple of tunnels is lost
+type sampledHeader struct {
+	vlans    []uint32
+	tunnel   string
+	tunnelID uint32
+	hasID    bool
+	outer    headerTuple
+	inner    headerTuple
+}
+
+// decodeSampledHeader decodes an Ethernet frame sampled by sFlow
+// Only one level of tunnel is followed and the decoding stops where the device cut the header
+func decodeSampledHeader(data []byte) *sampledHeader {
+	h := &sampledHeader{}
+	h.decodeEthernet(data, &h.outer)
+	return h
+}
+
+func (h *sampledHeader) decodeEthernet(data []byte, t *headerTuple) {
+	if len(data) < 14 {
+		return
+	}
+	etype := binary.BigEndian.Uint16(data[12:])
+	data = data[14:]
+	// 802.1Q tags, a QinQ stack has the service tag first
+	for etype == etypeVLAN || etype == etypeQinQ || etype == etypeQinQLegacy {
+		if len(data) < 4 {
+			return
+		}
+		if t == &h.outer {
+			h.vlans = append(h.vlans, uint32(binary.BigEndian.Uint16(data)&0x0fff))
+		}
+		etype = binary.BigEndian.Uint16(data[2:])
+		data = data[4:]
+	}
+	h.decodeEtype(etype, data, t)
+}
+
+func (h *sampledHeader) decodeEtype(etype uint16, data []byte, t *headerTuple) {
+	switch etype {
+	case etypeMPLS, etypeMPLSMulti:
+		// The labels are in the mpls field set, the stack is skipped to reach the IP header
+		for {
+			if len(data) < 4 {
+				return
+			}
+			bottom := data[2]&0x01 != 0
+			data = data[4:]
+			if bottom {
+				break
+			}
+		}
+		if len(data) == 0 {
+			return
+		}
+		switch data[0] >> 4 {
+		case 4:
+			h.decodeIPv4(data, t)
+		case 6:
+			h.decodeIPv6(data, t)
+		}
+	case etypeIPv4:
+		h.decodeIPv4(data, t)
+	case etypeIPv6:
+		h.decodeIPv6(data, t)
+	}
+}
+
+func (h *sampledHeader) decodeIPv4(data []byte, t *headerTuple) {
+	if len(data) < 20 {
+		return
+	}
+	ihl := int(data[0]&0x0f) * 4
+	if ihl < 20 || len(data) < ihl {
+		return
+	}
+	t.found = true
+	t.etype = etypeIPv4
+	t.src = netip.AddrFrom4([4]byte(data[12:16]))
+	t.dst = netip.AddrFrom4([4]byte(data[16:20]))
+	t.proto = uint32(data[9])
+	// The transport header is only in the first fragment
+	if binary.BigEndian.Uint16(data[6:])&0x1fff != 0 {
+		return
+	}
+	h.decodeTransport(t.proto, data[ihl:], t)
+}
+
+func (h *sampledHeader) decodeIPv6(data []byte, t *headerTuple) {
+	if len(data) < 40 {
+		return
+	}
+	t.found = true
+	t.etype = etypeIPv6
+	t.src = netip.AddrFrom16([16]byte(data[8:24]))
+	t.dst = netip.AddrFrom16([16]byte(data[24:40]))
+	next := data[6]
+	data = data[40:]
+	// The extension headers are skipped to reach the transport header
+	for {
+		switch next {
+		case 0, 43, 60: // hop-by-hop, routing and destination options
+			if len(data) < 8 || len(data) < (int(data[1])+1)*8 {
+				return
+			}
+			next, data = data[0], data[(int(data[1])+1)*8:]
+			continue
+		case 44: // fragment
+			if len(data) < 8 {
+				return
+			}
+			if binary.BigEndian.Uint16(data[2:])&0xfff8 != 0 {
+				t.proto = uint32(data[0])
+				return
+			}
+			next, data = data[0], data[8:]
+			continue
+		}
+		break
+	}
+	t.proto = uint32(next)
+	h.decodeTransport(t.proto, data, t)
+}
+
+func (h *sampledHeader) decodeTransport(proto uint32, data []byte, t *headerTuple) {
+	switch proto {
+	case protoTCP:
+		if len(data) < 16 {
+			return
+		}
+		t.srcPort = uint32(binary.BigEndian.Uint16(data))
+		t.dstPort = uint32(binary.BigEndian.Uint16(data[2:]))
+		t.tcp = true
+		t.tcpFlags = uint32(data[13])
+		t.tcpWindow = uint32(binary.BigEndian.Uint16(data[14:]))
+	case protoUDP:
+		if len(data) < 8 {
+			return
+		}
+		t.srcPort = uint32(binary.BigEndian.Uint16(data))
+		t.dstPort = uint32(binary.BigEndian.Uint16(data[2:]))
+		if t != &h.outer {
+			return
+		}
+		switch t.dstPort {
+		case portVXLAN:
+			h.decodeVXLAN(data[8:])
+		case portGeneve:
+			h.decodeGeneve(data[8:])
+		}
+	case protoGRE:
+		if t == &h.outer {
+			h.decodeGRE(data)
+		}
+	case protoIPIP:
+		if t == &h.outer {
+			h.tunnel = tunnelIPIP
+			h.decodeIPv4(data, &h.inner)
+		}
+	case protoIPv6:
+		if t == &h.outer {
+			h.tunnel = tunnelIPIP
+			h.decodeIPv6(data, &h.inner)
+		}
+	}
+}
+
+func (h *sampledHeader) decodeVXLAN(data []byte) {
+	h.tunnel = tunnelVXLAN
+	if len(data) < 8 {
+		return
+	}
+	// The VNI is only valid when the I flag is set
+	if data[0]&0x08 != 0 {
+		h.tunnelID = uint32(data[4])<<16 | uint32(data[5])<<8 | uint32(data[6])
+		h.hasID = true
+	}
+	h.decodeEthernet(data[8:], &h.inner)
+}
+
+func (h *sampledHeader) decodeGeneve(data []byte) {
+	h.tunnel = tunnelGeneve
+	if len(data) < 8 {
+		return
+	}
+	options := int(data[0]&0x3f) * 4
+	etype := binary.BigEndian.Uint16(data[2:])
+	h.tunnelID = uint32(data[4])<<16 | uint32(data[5])<<8 | uint32(data[6])
+	h.hasID = true
+	if len(data) < 8+options {
+		return
+	}
+	h.decodeTunnelPayload(etype, data[8+options:])
+}
+
+func (h *sampledHeader) decodeGRE(data []byte) {
+	h.tunnel = tunnelGRE
+	if len(data) < 4 {
+		return
+	}
+	flags := binary.BigEndian.Uint16(data)
+	etype := binary.BigEndian.Uint16(data[2:])
+	offset := 4
+	if flags&0x8000 != 0 { // checksum
+		offset += 4
+	}
+	if flags&0x2000 != 0 { // key
+		if len(data) < offset+4 {
+			return
+		}
+		h.tunnelID = binary.BigEndian.Uint32(data[offset:])
+		h.hasID = true
+		offset += 4
+	}
+	if flags&0x1000 != 0 { // sequence number
+		offset += 4
+	}
+	if len(data) < offset {
+		return
+	}
+	h.decodeTunnelPayload(etype, data[offset:])
+}
+
+// decodeTunnelPayload decodes the inner layers of a tunnel that carries either Ethernet frames or network packets
+func (h *sampledHeader) decodeTunnelPayload(etype uint16, data []byte) {
+	if etype == etypeTEB {
+		h.decodeEthernet(data, &h.inner)
+		return
+	}
+	h.decodeEtype(etype, data, &h.inner)
+}
+
+// putHeaderAttributes adds the attributes of the headers field set
+func putHeaderAttributes(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	rangeUnknownFields(pm, func(num protowire.Number, _ uint64, raw []byte) {
+		if num != sampledHeaderIndex {
+			return
+		}
+		h := decodeSampledHeader(raw)
+		putIntSlice(attrs, "flow.vlan.ids", h.vlans)
+		if h.outer.tcp {
+			attrs.PutInt("flow.tcp.window", int64(h.outer.tcpWindow))
+		}
+		if h.tunnel == "" {
+			return
+		}
+		attrs.PutStr("flow.tunnel.type", h.tunnel)
+		if h.hasID {
+			attrs.PutInt("flow.tunnel.id", int64(h.tunnelID))
+		}
+		if !h.inner.found {
+			return
+		}
+		attrs.PutStr("flow.inner.source.address", h.inner.src.String())
+		attrs.PutInt("flow.inner.source.port", int64(h.inner.srcPort))
+		attrs.PutStr("flow.inner.destination.address", h.inner.dst.String())
+		attrs.PutInt("flow.inner.destination.port", int64(h.inner.dstPort))
+		attrs.PutStr("flow.inner.network.transport", getTransportName(h.inner.proto))
+		attrs.PutStr("flow.inner.network.type", getEtypeName(h.inner.etype))
+		if h.inner.tcp {
+			attrs.PutInt("flow.inner.tcp.flags", int64(h.inner.tcpFlags))
+			attrs.PutInt("flow.inner.tcp.window", int64(h.inner.tcpWindow))
+		}
+	})
+}
+
+// sampledHeaderProducer keeps the Ethernet headers sampled by sFlow in the messages of their flow samples
+// goflow2 produces one message per flow sample, in the order of the samples
+type sampledHeaderProducer struct {
+	wrapped producer.ProducerInterface
+}
+
+func (p *sampledHeaderProducer) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	flowMessageSet, err := p.wrapped.Produce(msg, args)
+	packet, ok := msg.(*sflow.Packet)
+	if err != nil || !ok {
+		return flowMessageSet, err
+	}
+	samples := protoproducer.GetSFlowFlowSamples(packet)
+	for i, sample := range samples {
+		if i >= len(flowMessageSet) {
+			break
+		}
+		pm, ok := flowMessageSet[i].(*protoproducer.ProtoProducerMessage)
+		if !ok {
+			continue
+		}
+		header, ok := sampledEthernetHeader(sample)
+		if !ok {
+			continue
+		}
+		// The header is copied, it points into the packet buffer which is reused once the packet is decoded
+		unknown := pm.ProtoReflect().GetUnknown()
+		unknown = protowire.AppendTag(unknown, sampledHeaderIndex, protowire.BytesType)
+		unknown = protowire.AppendBytes(unknown, header)
+		pm.ProtoReflect().SetUnknown(unknown)
+
+		// goflow2 stops at the ether types it does not know, like the QinQ service tags
+		if len(pm.SrcAddr) == 0 {
+			fillOuterTuple(pm, decodeSampledHeader(header))
+		}
+	}
+	return flowMessageSet, nil
+}
+
+// fillOuterTuple sets the outer 5-tuple of a message that goflow2 could not decode
+func fillOuterTuple(pm *protoproducer.ProtoProducerMessage, h *sampledHeader) {
+	if !h.outer.found {
+		return
+	}
+	pm.Etype = h.outer.etype
+	pm.SrcAddr = h.outer.src.AsSlice()
+	pm.DstAddr = h.outer.dst.AsSlice()
+	pm.Proto = h.outer.proto
+	pm.SrcPort = h.outer.srcPort
+	pm.DstPort = h.outer.dstPort
+	pm.TcpFlags = h.outer.tcpFlags
+	if len(h.vlans) > 0 {
+		pm.VlanId = h.vlans[0]
+	}
+}
+
+func (p *sampledHeaderProducer) Close() {
+	p.wrapped.Close()
+}
+
+func (p *sampledHeaderProducer) Commit(flowMessageSet []producer.ProducerMessage) {
+	p.wrapped.Commit(flowMessageSet)
+}
+
+// sampledEthernetHeader returns the Ethernet header of a flow sample, if it has one
+func sampledEthernetHeader(sample any) ([]byte, bool) {
+	var records []sflow.FlowRecord
+	switch sample := sample.(type) {
+	case sflow.FlowSample:
+		records = sample.Records
+	case sflow.ExpandedFlowSample:
+		records = sample.Records
+	}
+	for _, record := range records {
+		if header, ok := record.Data.(sflow.SampledHeader); ok && header.Protocol == sflowHeaderProtocolEthernet {
+			return header.HeaderData, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,213 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+)
+
+// testEthernet builds an Ethernet header with the 802.1Q tags of the VLANs, the first one is a QinQ service tag
+func testEthernet(etype uint16, vlans ...uint16) []byte {
+	frame := make([]byte, 12)
+	for i, vlan := range vlans {
+		tpid := uint16(etypeVLAN)
+		if i == 0 && len(vlans) > 1 {
+			tpid = etypeQinQ
+		}
+		frame = binary.BigEndian.AppendUint16(frame, tpid)
+		frame = binary.BigEndian.AppendUint16(frame, vlan)
+	}
+	return binary.BigEndian.AppendUint16(frame, etype)
+}
+
+// testIPv4 builds an IPv4 header
+func testIPv4(proto byte, src, dst [4]byte) []byte {
+	header := make([]byte, 20)
+	header[0] = 0x45
+	header[8] = 64
+	header[9] = proto
+	copy(header[12:], src[:])
+	copy(header[16:], dst[:])
+	return header
+}
+
+// testTCP builds a TCP header
+func testTCP(srcPort, dstPort uint16, flags byte, window uint16) []byte {
+	header := make([]byte, 20)
+	binary.BigEndian.PutUint16(header[0:], srcPort)
+	binary.BigEndian.PutUint16(header[2:], dstPort)
+	header[12] = 0x50
+	header[13] = flags
+	binary.BigEndian.PutUint16(header[14:], window)
+	return header
+}
+
+// testUDP builds a UDP header
+func testUDP(srcPort, dstPort uint16) []byte {
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint16(header[0:], srcPort)
+	binary.BigEndian.PutUint16(header[2:], dstPort)
+	return header
+}
+
+// testVXLANFrame builds a frame of a QinQ network that carries a TCP connection in VXLAN 5000
+func testVXLANFrame() []byte {
+	inner := append(testEthernet(etypeIPv4), testIPv4(protoTCP, [4]byte{192, 168, 1, 1}, [4]byte{192, 168, 1, 2})...)
+	inner = append(inner, testTCP(443, 51000, 0x18, 1024)...)
+
+	frame := append(testEthernet(etypeIPv4, 100, 200), testIPv4(protoUDP, [4]byte{10, 1, 0, 1}, [4]byte{10, 1, 0, 2})...)
+	frame = append(frame, testUDP(49152, portVXLAN)...)
+	frame = append(frame, 0x08, 0, 0, 0, 0, 0x13, 0x88, 0) // VNI 5000
+	return append(frame, inner...)
+}
+
+// sflowHeaderPacket builds a sFlow v5 datagram with a flow sample of the frame
+func sflowHeaderPacket(frame []byte) []byte {
+	record := binary.BigEndian.AppendUint32(nil, sflowHeaderProtocolEthernet)
+	record = binary.BigEndian.AppendUint32(record, 1500) // frame length
+	record = binary.BigEndian.AppendUint32(record, 4)    // stripped
+	record = binary.BigEndian.AppendUint32(record, uint32(len(frame)))
+	record = append(record, frame...)
+	for len(record)%4 != 0 {
+		record = append(record, 0)
+	}
+
+	sample := binary.BigEndian.AppendUint32(nil, 1)         // sequence number
+	for _, v := range []uint32{3, 1000, 1000, 0, 3, 4, 1} { // source id, sampling rate, pool, drops, input, output, records
+		sample = binary.BigEndian.AppendUint32(sample, v)
+	}
+	sample = binary.BigEndian.AppendUint32(sample, 1) // sampled header
+	sample = binary.BigEndian.AppendUint32(sample, uint32(len(record)))
+	sample = append(sample, record...)
+
+	packet := sflowV5Packet()
+	binary.BigEndian.PutUint32(packet[24:], 1)        // samples
+	packet = binary.BigEndian.AppendUint32(packet, 1) // flow sample
+	packet = binary.BigEndian.AppendUint32(packet, uint32(len(sample)))
+	return append(packet, sample...)
+}
+
+func TestSampledHeaderAttributes(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Listeners[0].Scheme = "sflow"
+	cfg.Attributes.FieldSets = []string{fieldSetHeaders}
+	nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *cfg)
+	require.NoError(t, err)
+	sink := &consumertest.LogsSink{}
+	nr.logConsumer = sink
+	nr.enrichers = nr.buildEnrichers()
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+
+	require.NoError(t, decode(testMessage(sflowHeaderPacket(testVXLANFrame()))))
+	require.Equal(t, 1, sink.LogRecordCount())
+	attrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes()
+
+	// goflow2 stops at the QinQ service tag, the outer 5-tuple comes from the sampled header
+	assertStrAttribute(t, attrs, "source.address", "10.1.0.1")
+	assertIntAttribute(t, attrs, "destination.port", portVXLAN)
+	assertStrAttribute(t, attrs, "network.transport", "udp")
+
+	vlans, ok := attrs.Get("flow.vlan.ids")
+	require.True(t, ok)
+	assert.Equal(t, []any{int64(100), int64(200)}, vlans.Slice().AsRaw())
+	assertStrAttribute(t, attrs, "flow.tunnel.type", tunnelVXLAN)
+	assertIntAttribute(t, attrs, "flow.tunnel.id", 5000)
+	assertStrAttribute(t, attrs, "flow.inner.source.address", "192.168.1.1")
+	assertIntAttribute(t, attrs, "flow.inner.source.port", 443)
+	assertStrAttribute(t, attrs, "flow.inner.destination.address", "192.168.1.2")
+	assertIntAttribute(t, attrs, "flow.inner.destination.port", 51000)
+	assertStrAttribute(t, attrs, "flow.inner.network.transport", "tcp")
+	assertStrAttribute(t, attrs, "flow.inner.network.type", "ipv4")
+	assertIntAttribute(t, attrs, "flow.inner.tcp.flags", 0x18)
+	assertIntAttribute(t, attrs, "flow.inner.tcp.window", 1024)
+	_, ok = attrs.Get("flow.tcp.window")
+	assert.False(t, ok, "the outer transport is not TCP")
+}
+
+func TestSampledHeaderDisabled(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Listeners[0].Scheme = "sflow"
+	nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *cfg)
+	require.NoError(t, err)
+	sink := &consumertest.LogsSink{}
+	nr.logConsumer = sink
+	nr.enrichers = nr.buildEnrichers()
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+
+	require.NoError(t, decode(testMessage(sflowHeaderPacket(testVXLANFrame()))))
+	require.Equal(t, 1, sink.LogRecordCount())
+	sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().Range(func(k string, _ pcommon.Value) bool {
+		_, ok := fieldSetOf(k)
+		assert.False(t, ok, k)
+		return true
+	})
+}
+
+func TestDecodeSampledHeader(t *testing.T) {
+	innerIPv4 := append(testIPv4(protoTCP, [4]byte{192, 168, 1, 1}, [4]byte{192, 168, 1, 2}), testTCP(22, 40000, 0x02, 64240)...)
+
+	gre := []byte{0x20, 0, 0x08, 0, 0, 0, 0x30, 0x39} // key 12345
+	greFrame := append(testEthernet(etypeIPv4), testIPv4(protoGRE, [4]byte{10, 1, 0, 1}, [4]byte{10, 1, 0, 2})...)
+	greFrame = append(append(greFrame, gre...), innerIPv4...)
+
+	ipipFrame := append(testEthernet(etypeIPv4), testIPv4(protoIPIP, [4]byte{10, 1, 0, 1}, [4]byte{10, 1, 0, 2})...)
+	ipipFrame = append(ipipFrame, innerIPv4...)
+
+	geneve := []byte{0x01, 0, 0x08, 0, 0, 0, 0x07, 0, 0, 0, 0, 0} // one option word, VNI 7
+	geneveFrame := append(testEthernet(etypeIPv4), testIPv4(protoUDP, [4]byte{10, 1, 0, 1}, [4]byte{10, 1, 0, 2})...)
+	geneveFrame = append(append(append(geneveFrame, testUDP(49152, portGeneve)...), geneve...), innerIPv4...)
+
+	mpls := []byte{0, 0x01, 0x00, 64, 0, 0x02, 0x01, 64} // labels 16 and 32, the second is the bottom of the stack
+	mplsFrame := append(append(testEthernet(etypeMPLS, 300), mpls...), innerIPv4...)
+
+	tests := []struct {
+		name     string
+		frame    []byte
+		tunnel   string
+		tunnelID uint32
+		hasID    bool
+		vlans    []uint32
+	}{
+		{name: "gre", frame: greFrame, tunnel: tunnelGRE, tunnelID: 12345, hasID: true},
+		{name: "ipip", frame: ipipFrame, tunnel: tunnelIPIP},
+		{name: "geneve", frame: geneveFrame, tunnel: tunnelGeneve, tunnelID: 7, hasID: true},
+		{name: "mpls", frame: mplsFrame, vlans: []uint32{300}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := decodeSampledHeader(tt.frame)
+			assert.Equal(t, tt.tunnel, h.tunnel)
+			assert.Equal(t, tt.tunnelID, h.tunnelID)
+			assert.Equal(t, tt.hasID, h.hasID)
+			assert.Equal(t, tt.vlans, h.vlans)
+
+			tuple := h.inner
+			if tt.tunnel == "" {
+				tuple = h.outer
+				assert.False(t, h.inner.found)
+			}
+			require.True(t, tuple.found)
+			assert.Equal(t, "192.168.1.1", tuple.src.String())
+			assert.Equal(t, uint32(22), tuple.srcPort)
+			assert.Equal(t, uint32(40000), tuple.dstPort)
+			assert.Equal(t, uint32(0x02), tuple.tcpFlags)
+			assert.Equal(t, uint32(64240), tuple.tcpWindow)
+		})
+	}
+
+	// The decoding stops where the device cut the header
+	h := decodeSampledHeader(testVXLANFrame()[:60])
+	assert.Equal(t, tunnelVXLAN, h.tunnel)
+	assert.True(t, h.outer.found)
+	assert.False(t, h.inner.found)
+}
@@ -0,0 +1,161 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// interfaceKey identifies an interface of an exporter
+type interfaceKey struct {
+	sampler netip.Addr
+	ifIndex uint32
+}
+
+// interfaceFileEntry is an interface in a YAML interfaces file
+type interfaceFileEntry struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Speed       uint64 `yaml:"speed"`
+}
+
+// readInterfacesFile reads the interfaces of a YAML file with a map of sampler addresses to a map of ifIndexes to interfaces
+// or of a CSV file with a sampler address, an ifIndex, a name and optionally a description and a speed in each line
+func readInterfacesFile(path string) (map[interfaceKey]interfaceInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	interfaces := make(map[interfaceKey]interfaceInfo)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var samplers map[string]map[uint32]interfaceFileEntry
+		if err := yaml.NewDecoder(f).Decode(&samplers); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		for s, entries := range samplers {
+			sampler, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, fmt.Errorf("sampler address %q is not valid: %w", s, err)
+			}
+			for ifIndex, entry := range entries {
+				interfaces[interfaceKey{sampler: sampler.Unmap(), ifIndex: ifIndex}] = interfaceInfo{
+					name:  entry.Name,
+					alias: entry.Description,
+					speed: entry.Speed,
+				}
+			}
+		}
+	case ".csv":
+		r := csv.NewReader(f)
+		r.Comment = '#'
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		for i, record := range records {
+			key, info, err := parseInterfaceRecord(record)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			interfaces[key] = info
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file extension %q, must be .csv, .yaml or .yml", filepath.Ext(path))
+	}
+	return interfaces, nil
+}
+
+// parseInterfaceRecord parses a line of a CSV interfaces file
+func parseInterfaceRecord(record []string) (interfaceKey, interfaceInfo, error) {
+	if len(record) < 3 || len(record) > 5 {
+		return interfaceKey{}, interfaceInfo{}, fmt.Errorf("expected 3 to 5 fields, got %d", len(record))
+	}
+	sampler, err := netip.ParseAddr(record[0])
+	if err != nil {
+		return interfaceKey{}, interfaceInfo{}, fmt.Errorf("sampler address %q is not valid: %w", record[0], err)
+	}
+	ifIndex, err := strconv.ParseUint(record[1], 10, 32)
+	if err != nil {
+		return interfaceKey{}, interfaceInfo{}, fmt.Errorf("ifIndex %q is not valid: %w", record[1], err)
+	}
+
+	info := interfaceInfo{name: record[2]}
+	if len(record) > 3 {
+		info.alias = record[3]
+	}
+	if len(record) > 4 && record[4] != "" {
+		if info.speed, err = strconv.ParseUint(record[4], 10, 64); err != nil {
+			return interfaceKey{}, interfaceInfo{}, fmt.Errorf("speed %q is not valid: %w", record[4], err)
+		}
+	}
+	return interfaceKey{sampler: sampler.Unmap(), ifIndex: uint32(ifIndex)}, info, nil
+}
+
+// interfacesEnricher adds the names of the interfaces from a file
+// The names of the file replace the names read with SNMP, so it runs after the snmp enricher
+type interfacesEnricher struct {
+	defaultName string
+	file        *fileReloader[map[interfaceKey]interfaceInfo]
+}
+
+func newInterfacesEnricher(cfg InterfacesConfig, logger *zap.Logger) *interfacesEnricher {
+	return &interfacesEnricher{
+		defaultName: cfg.DefaultName,
+		file:        newFileReloader(cfg.File, cfg.ReloadInterval, logger, readInterfacesFile),
+	}
+}
+
+func (e *interfacesEnricher) start() error {
+	if err := e.file.start(); err != nil {
+		return fmt.Errorf("failed to load the interfaces file: %w", err)
+	}
+	return nil
+}
+
+func (e *interfacesEnricher) stop() {
+	e.file.stop()
+}
+
+func (e *interfacesEnricher) enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	sampler, ok := netip.AddrFromSlice(pm.SamplerAddress)
+	if !ok {
+		return
+	}
+	sampler = sampler.Unmap()
+	interfaces := e.file.get()
+	e.enrichInterface(attrs, interfaces, "in", interfaceKey{sampler: sampler, ifIndex: pm.InIf})
+	e.enrichInterface(attrs, interfaces, "out", interfaceKey{sampler: sampler, ifIndex: pm.OutIf})
+}
+
+func (e *interfacesEnricher) enrichInterface(attrs pcommon.Map, interfaces map[interfaceKey]interfaceInfo, direction string, key interfaceKey) {
+	if key.ifIndex == 0 {
+		return
+	}
+	if info, ok := interfaces[key]; ok {
+		putInterfaceAttributes(attrs, direction, info)
+		return
+	}
+	// Interfaces that were already named with SNMP keep their name
+	if _, ok := attrs.Get("flow.interface." + direction + ".name"); !ok && e.defaultName != "" {
+		attrs.PutStr("flow.interface."+direction+".name", e.defaultName)
+	}
+}
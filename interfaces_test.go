@@ -0,0 +1,111 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+func TestReadInterfacesFile(t *testing.T) {
+	dir := t.TempDir()
+	expected := map[interfaceKey]interfaceInfo{
+		{sampler: netip.MustParseAddr("192.0.2.1"), ifIndex: 1}: {name: "Gi0/0/1", alias: "uplink", speed: 10_000_000_000},
+		{sampler: netip.MustParseAddr("192.0.2.1"), ifIndex: 2}: {name: "Gi0/0/2"},
+	}
+
+	csvPath := filepath.Join(dir, "interfaces.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("# sampler_address,if_index,name,description,speed\n192.0.2.1,1,Gi0/0/1,uplink,10000000000\n::ffff:192.0.2.1,2,Gi0/0/2\n"), 0o600))
+	interfaces, err := readInterfacesFile(csvPath)
+	require.NoError(t, err)
+	assert.Equal(t, expected, interfaces)
+
+	yamlPath := filepath.Join(dir, "interfaces.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`192.0.2.1:
+  1:
+    name: Gi0/0/1
+    description: uplink
+    speed: 10000000000
+  2:
+    name: Gi0/0/2
+`), 0o600))
+	interfaces, err = readInterfacesFile(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, expected, interfaces)
+
+	for content, err := range map[string]string{
+		"192.0.2.1,1\n":                 "line 1: expected 3 to 5 fields, got 2",
+		"192.0.2.300,1,Gi0/0/1\n":       `line 1: sampler address "192.0.2.300" is not valid`,
+		"192.0.2.1,-1,Gi0/0/1\n":        `line 1: ifIndex "-1" is not valid`,
+		"192.0.2.1,1,Gi0/0/1,,fast\n":   `line 1: speed "fast" is not valid`,
+		"192.0.2.1,1,Gi0/0/1,a,1,2,3\n": "line 1: expected 3 to 5 fields, got 7",
+	} {
+		invalidPath := filepath.Join(dir, "invalid.csv")
+		require.NoError(t, os.WriteFile(invalidPath, []byte(content), 0o600))
+		_, readErr := readInterfacesFile(invalidPath)
+		assert.ErrorContains(t, readErr, err, content)
+	}
+}
+
+func TestInterfacesEnricher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interfaces.csv")
+	require.NoError(t, os.WriteFile(path, []byte("192.0.2.1,1,Gi0/0/1,uplink,10000000000\n"), 0o600))
+
+	enricher := newInterfacesEnricher(InterfacesConfig{
+		File:           path,
+		ReloadInterval: 10 * time.Millisecond,
+		DefaultName:    "unknown",
+	}, zap.NewNop())
+	require.NoError(t, enricher.start())
+	defer enricher.stop()
+
+	pm := testSNMPMessage()
+	attrs := pcommon.NewMap()
+	enricher.enrich(pm, attrs)
+	assertStrAttribute(t, attrs, "flow.interface.in.name", "Gi0/0/1")
+	assertStrAttribute(t, attrs, "flow.interface.in.alias", "uplink")
+	assertIntAttribute(t, attrs, "flow.interface.in.speed", 10_000_000_000)
+	// Interfaces missing from the file get the default name
+	assertStrAttribute(t, attrs, "flow.interface.out.name", "unknown")
+
+	require.NoError(t, os.WriteFile(path, []byte("192.0.2.1,1,Te0/0/1\n192.0.2.1,2,Te0/0/2\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		attrs.Clear()
+		enricher.enrich(pm, attrs)
+		name, ok := attrs.Get("flow.interface.out.name")
+		return ok && name.Str() == "Te0/0/2"
+	}, time.Second, 10*time.Millisecond)
+	assertStrAttribute(t, attrs, "flow.interface.in.name", "Te0/0/1")
+}
+
+func TestInterfacesEnricherKeepsSNMPNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interfaces.csv")
+	require.NoError(t, os.WriteFile(path, []byte("192.0.2.1,1,Gi0/0/1\n"), 0o600))
+
+	enricher := newInterfacesEnricher(InterfacesConfig{File: path, DefaultName: "unknown"}, zap.NewNop())
+	require.NoError(t, enricher.start())
+	defer enricher.stop()
+
+	attrs := pcommon.NewMap()
+	attrs.PutStr("flow.interface.in.name", "snmp-in")
+	attrs.PutStr("flow.interface.out.name", "snmp-out")
+	enricher.enrich(testSNMPMessage(), attrs)
+
+	// The file replaces the names read with SNMP, but the default name does not
+	assertStrAttribute(t, attrs, "flow.interface.in.name", "Gi0/0/1")
+	assertStrAttribute(t, attrs, "flow.interface.out.name", "snmp-out")
+}
+
+func TestInterfacesEnricherMissingFile(t *testing.T) {
+	enricher := newInterfacesEnricher(InterfacesConfig{File: filepath.Join(t.TempDir(), "missing.csv")}, zap.NewNop())
+	assert.ErrorContains(t, enricher.start(), "failed to load the interfaces file")
+}
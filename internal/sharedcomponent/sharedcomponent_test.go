@@ -0,0 +1,77 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package sharedcomponent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+type countingComponent struct {
+	starts    int
+	shutdowns int
+}
+
+func (c *countingComponent) Start(context.Context, component.Host) error {
+	c.starts++
+	return nil
+}
+
+func (c *countingComponent) Shutdown(context.Context) error {
+	c.shutdowns++
+	return nil
+}
+
+func TestLoadOrStore(t *testing.T) {
+	comps := NewMap[string, *countingComponent]()
+
+	created := 0
+	create := func() (*countingComponent, error) {
+		created++
+		return &countingComponent{}, nil
+	}
+
+	first, err := comps.LoadOrStore("netflow", create)
+	require.NoError(t, err)
+	second, err := comps.LoadOrStore("netflow", create)
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, created)
+
+	_, err = comps.LoadOrStore("other", func() (*countingComponent, error) {
+		return nil, errors.New("failed")
+	})
+	assert.EqualError(t, err, "failed")
+}
+
+func TestStartShutdownOnce(t *testing.T) {
+	comps := NewMap[string, *countingComponent]()
+
+	comp, err := comps.LoadOrStore("netflow", func() (*countingComponent, error) {
+		return &countingComponent{}, nil
+	})
+	require.NoError(t, err)
+
+	host := componenttest.NewNopHost()
+	require.NoError(t, comp.Start(context.Background(), host))
+	require.NoError(t, comp.Start(context.Background(), host))
+	require.NoError(t, comp.Shutdown(context.Background()))
+	require.NoError(t, comp.Shutdown(context.Background()))
+
+	assert.Equal(t, 1, comp.Unwrap().starts)
+	assert.Equal(t, 1, comp.Unwrap().shutdowns)
+
+	// Once stopped, a new instance is created for the same key
+	next, err := comps.LoadOrStore("netflow", func() (*countingComponent, error) {
+		return &countingComponent{}, nil
+	})
+	require.NoError(t, err)
+	assert.NotSame(t, comp, next)
+}
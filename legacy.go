@@ -0,0 +1,173 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflowlegacy"
+	flowpb "github.com/netsampler/goflow2/v2/pb"
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/netsampler/goflow2/v2/utils"
+)
+
+const (
+	// flowTypeNetFlowV1 and flowTypeNetFlowV7 are the flow types of the legacy versions goflow2 does not decode
+	// They are well above the values of the goflow2 flow types so they never collide
+	flowTypeNetFlowV1 flowpb.FlowMessage_FlowType = 101
+	flowTypeNetFlowV7 flowpb.FlowMessage_FlowType = 107
+
+	netflowV1HeaderSize = 16
+	netflowV1RecordSize = 48
+	netflowV7HeaderSize = 24
+	netflowV7RecordSize = 52
+)
+
+var _ utils.FlowPipe = (*netflowPipe)(nil)
+
+// legacyNetFlowPacket is a NetFlow v1 or v7 packet
+// The records are converted to the NetFlow v5 format, so the flows have the same attributes as the v5 ones
+type legacyNetFlowPacket struct {
+	netflowlegacy.PacketNetFlowV5
+}
+
+// decodeLegacyNetFlow decodes a NetFlow v1 or v7 packet
+// v1 has no sequence, engine, sampling or AS fields and v7 has no engine or sampling fields, they are left empty
+func decodeLegacyNetFlow(payload []byte) (*legacyNetFlowPacket, error) {
+	if len(payload) < 4 {
+		return nil, fmt.Errorf("packet is too short")
+	}
+	packet := &legacyNetFlowPacket{}
+	packet.Version = binary.BigEndian.Uint16(payload)
+	packet.Count = binary.BigEndian.Uint16(payload[2:])
+
+	headerSize, recordSize := netflowV1HeaderSize, netflowV1RecordSize
+	if packet.Version == 7 {
+		headerSize, recordSize = netflowV7HeaderSize, netflowV7RecordSize
+	}
+	if len(payload) < headerSize+int(packet.Count)*recordSize {
+		return nil, fmt.Errorf("netflow v%d packet of %d bytes is too short for %d records", packet.Version, len(payload), packet.Count)
+	}
+	packet.SysUptime = binary.BigEndian.Uint32(payload[4:])
+	packet.UnixSecs = binary.BigEndian.Uint32(payload[8:])
+	packet.UnixNSecs = binary.BigEndian.Uint32(payload[12:])
+	if packet.Version == 7 {
+		packet.FlowSequence = binary.BigEndian.Uint32(payload[16:])
+	}
+
+	packet.Records = make([]netflowlegacy.RecordsNetFlowV5, packet.Count)
+	for i := range packet.Records {
+		data := payload[headerSize+i*recordSize:]
+		record := netflowlegacy.RecordsNetFlowV5{
+			SrcAddr: netflowlegacy.IPAddress(binary.BigEndian.Uint32(data[0:])),
+			DstAddr: netflowlegacy.IPAddress(binary.BigEndian.Uint32(data[4:])),
+			NextHop: netflowlegacy.IPAddress(binary.BigEndian.Uint32(data[8:])),
+			Input:   binary.BigEndian.Uint16(data[12:]),
+			Output:  binary.BigEndian.Uint16(data[14:]),
+			DPkts:   binary.BigEndian.Uint32(data[16:]),
+			DOctets: binary.BigEndian.Uint32(data[20:]),
+			First:   binary.BigEndian.Uint32(data[24:]),
+			Last:    binary.BigEndian.Uint32(data[28:]),
+			SrcPort: binary.BigEndian.Uint16(data[32:]),
+			DstPort: binary.BigEndian.Uint16(data[34:]),
+		}
+		if packet.Version == 1 {
+			record.Proto = data[38]
+			record.Tos = data[39]
+			record.TCPFlags = data[40]
+		} else {
+			// The v7 record is the v5 record followed by the address of the router that bypassed the flow
+			record.TCPFlags = data[37]
+			record.Proto = data[38]
+			record.Tos = data[39]
+			record.SrcAS = binary.BigEndian.Uint16(data[40:])
+			record.DstAS = binary.BigEndian.Uint16(data[42:])
+			record.SrcMask = data[44]
+			record.DstMask = data[45]
+		}
+		packet.Records[i] = record
+	}
+	return packet, nil
+}
+
+// netflowPipe decodes the NetFlow v1 and v7 packets and forwards the other versions to the goflow2 netflow pipe
+type netflowPipe struct {
+	*utils.NetFlowPipe
+	producer producer.ProducerInterface
+}
+
+func newNetFlowPipe(cfg *utils.PipeConfig) *netflowPipe {
+	return &netflowPipe{
+		NetFlowPipe: utils.NewNetFlowPipe(cfg),
+		producer:    cfg.Producer,
+	}
+}
+
+func (p *netflowPipe) DecodeFlow(msg any) error {
+	pkt, ok := msg.(*utils.Message)
+	if !ok || len(pkt.Payload) < 2 {
+		return p.NetFlowPipe.DecodeFlow(msg)
+	}
+	switch binary.BigEndian.Uint16(pkt.Payload) {
+	case 1, 7:
+	default:
+		return p.NetFlowPipe.DecodeFlow(msg)
+	}
+
+	packet, err := decodeLegacyNetFlow(pkt.Payload)
+	if err != nil {
+		return &utils.PipeMessageError{Message: pkt, Err: err}
+	}
+	if p.producer == nil {
+		return nil
+	}
+	args := producer.ProduceArgs{
+		Src:            pkt.Src,
+		Dst:            pkt.Dst,
+		TimeReceived:   pkt.Received,
+		SamplerAddress: pkt.Src.Addr(),
+	}
+	flowMessageSet, err := p.producer.Produce(packet, &args)
+	defer p.producer.Commit(flowMessageSet)
+	if err != nil {
+		return &utils.PipeMessageError{Message: pkt, Err: err}
+	}
+	return nil
+}
+
+// legacyProducer produces the flows of the NetFlow v1 and v7 packets with the NetFlow v5 producer of goflow2
+type legacyProducer struct {
+	wrapped producer.ProducerInterface
+}
+
+func (p *legacyProducer) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	packet, ok := msg.(*legacyNetFlowPacket)
+	if !ok {
+		return p.wrapped.Produce(msg, args)
+	}
+	flowMessageSet, err := p.wrapped.Produce(&packet.PacketNetFlowV5, args)
+	if err != nil {
+		return flowMessageSet, err
+	}
+	flowType := flowTypeNetFlowV1
+	if packet.Version == 7 {
+		flowType = flowTypeNetFlowV7
+	}
+	for _, m := range flowMessageSet {
+		if pm, ok := m.(*protoproducer.ProtoProducerMessage); ok {
+			pm.Type = flowType
+		}
+	}
+	return flowMessageSet, nil
+}
+
+func (p *legacyProducer) Close() {
+	p.wrapped.Close()
+}
+
+func (p *legacyProducer) Commit(flowMessageSet []producer.ProducerMessage) {
+	p.wrapped.Commit(flowMessageSet)
+}
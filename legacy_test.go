@@ -0,0 +1,100 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+)
+
+// netflowLegacyPacket builds a NetFlow v1 or v7 packet with a TCP flow from 192.168.0.1:40000 to 192.168.0.2:443
+func netflowLegacyPacket(version uint16) []byte {
+	header := make([]byte, netflowV1HeaderSize)
+	record := make([]byte, netflowV1RecordSize)
+	if version == 7 {
+		header = make([]byte, netflowV7HeaderSize)
+		binary.BigEndian.PutUint32(header[16:], 42) // sequence
+		record = make([]byte, netflowV7RecordSize)
+		record[37] = 0x12 // tcp flags
+		record[38] = 6    // tcp
+		binary.BigEndian.PutUint16(record[40:], 65001)
+		binary.BigEndian.PutUint16(record[42:], 65002)
+		copy(record[48:], []byte{10, 0, 0, 254}) // router shortcut
+	} else {
+		record[38] = 6    // tcp
+		record[40] = 0x12 // tcp flags
+	}
+	binary.BigEndian.PutUint16(header[0:], version)
+	binary.BigEndian.PutUint16(header[2:], 1)          // count
+	binary.BigEndian.PutUint32(header[4:], 60_000)     // uptime
+	binary.BigEndian.PutUint32(header[8:], 1700000000) // unix seconds
+
+	copy(record[0:], []byte{192, 168, 0, 1})
+	copy(record[4:], []byte{192, 168, 0, 2})
+	binary.BigEndian.PutUint16(record[12:], 3)
+	binary.BigEndian.PutUint32(record[16:], 10)     // packets
+	binary.BigEndian.PutUint32(record[20:], 840)    // bytes
+	binary.BigEndian.PutUint32(record[24:], 59_000) // first
+	binary.BigEndian.PutUint32(record[28:], 59_500) // last
+	binary.BigEndian.PutUint16(record[32:], 40000)
+	binary.BigEndian.PutUint16(record[34:], 443)
+
+	return append(header, record...)
+}
+
+func TestLegacyNetFlow(t *testing.T) {
+	tests := []struct {
+		version  uint16
+		flowType string
+		srcAS    int64
+	}{
+		{version: 1, flowType: "netflow_v1"},
+		{version: 7, flowType: "netflow_v7", srcAS: 65001},
+	}
+	for _, tt := range tests {
+		t.Run(tt.flowType, func(t *testing.T) {
+			cfg := createDefaultConfig().(*Config)
+			cfg.Attributes.FieldSets = []string{fieldSetTransport, fieldSetRouting}
+			nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *cfg)
+			require.NoError(t, err)
+			sink := &consumertest.LogsSink{}
+			nr.logConsumer = sink
+			nr.enrichers = nr.buildEnrichers()
+			decode, err := nr.buildDecodeFunc(nr.listeners[0])
+			require.NoError(t, err)
+
+			require.NoError(t, decode(testMessage(netflowLegacyPacket(tt.version))))
+			require.Equal(t, 1, sink.LogRecordCount())
+			attrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes()
+
+			assertStrAttribute(t, attrs, "flow.type", tt.flowType)
+			assertStrAttribute(t, attrs, "source.address", "192.168.0.1")
+			assertIntAttribute(t, attrs, "source.port", 40000)
+			assertStrAttribute(t, attrs, "destination.address", "192.168.0.2")
+			assertIntAttribute(t, attrs, "destination.port", 443)
+			assertStrAttribute(t, attrs, "network.transport", "tcp")
+			assertIntAttribute(t, attrs, "flow.io.bytes", 840)
+			assertIntAttribute(t, attrs, "flow.io.packets", 10)
+			assertIntAttribute(t, attrs, "flow.start", 1699999999000000000)
+			assertIntAttribute(t, attrs, "flow.end", 1699999999500000000)
+			assertIntAttribute(t, attrs, "flow.tcp.flags", 0x12)
+			assertIntAttribute(t, attrs, "flow.as.source", tt.srcAS)
+		})
+	}
+}
+
+func TestDecodeLegacyNetFlowTooShort(t *testing.T) {
+	packet := netflowLegacyPacket(7)
+	_, err := decodeLegacyNetFlow(packet[:len(packet)-1])
+	assert.EqualError(t, err, "netflow v7 packet of 75 bytes is too short for 1 records")
+
+	decode, sink := newTestDefaultDecoder(t)
+	assert.Error(t, decode(testMessage(packet[:len(packet)-1])))
+	assert.Equal(t, 0, sink.LogRecordCount())
+}
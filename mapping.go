@@ -0,0 +1,367 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// customFieldTypeVarint decodes the field as an unsigned integer
+	customFieldTypeVarint = "varint"
+	// customFieldTypeString keeps the raw value of the field as a string
+	customFieldTypeString = "string"
+	// customFieldTypeBytes keeps the raw value of the field as bytes
+	customFieldTypeBytes = "bytes"
+	// customFieldTypeAddress decodes an IPv4 or IPv6 address field as a string
+	customFieldTypeAddress = "address"
+
+	// customFieldFirstIndex is the protobuf index of the first custom field
+	// It is well above the indexes used by the goflow2 flow message
+	customFieldFirstIndex = 1000
+
+	// flowDirectionIndex is the protobuf index where goflow2 stores the flow direction
+	// The direction is not part of the goflow2 flow message, so it is always mapped as a custom field
+	flowDirectionIndex = 999
+	// flowDirectionField is the name of the flow direction custom field, it cannot be used by the mappings
+	flowDirectionField = "flow_direction"
+	// flowDirectionType is the flowDirection field in IPFIX and the DIRECTION field in NetFlow v9
+	flowDirectionType = 61
+)
+
+// flowDirectionNames are the values of the flow direction field
+var flowDirectionNames = map[uint64]string{
+	0: "ingress",
+	1: "egress",
+}
+
+// MappingConfig represents the custom field mappings passed to the goflow2 producer
+type MappingConfig struct {
+	// The IPFIX information elements to decode
+	IPFIX []NetFlowFieldMapping `mapstructure:"ipfix"`
+
+	// The NetFlow v9 fields to decode
+	NetFlowV9 []NetFlowFieldMapping `mapstructure:"netflowv9"`
+
+	// The bits to extract from the packet headers sampled by sFlow
+	// They are also applied to the packet sections exported in IPFIX
+	SFlow []SFlowFieldMapping `mapstructure:"sflow"`
+
+	// The paths of CSV or YAML files with enterprise information elements to decode in addition to the built-in dictionary
+	Dictionaries []string `mapstructure:"dictionaries"`
+}
+
+// NetFlowFieldMapping maps a NetFlow v9 or IPFIX field to a destination
+type NetFlowFieldMapping struct {
+	// The type of the field in the template
+	Field uint16 `mapstructure:"field"`
+
+	// The private enterprise number of enterprise specific fields, zero for the IANA fields
+	Pen uint32 `mapstructure:"pen"`
+
+	FieldDestination `mapstructure:",squash"`
+}
+
+// SFlowFieldMapping maps a range of bits of a sampled packet header to a destination
+type SFlowFieldMapping struct {
+	// The protocol layer the offset is relative to, for example ipv4, ipv6, tcp or udp
+	Layer string `mapstructure:"layer"`
+
+	// The offset in bits from the start of the layer
+	Offset int `mapstructure:"offset"`
+
+	// The length in bits of the value
+	Length int `mapstructure:"length"`
+
+	// Only extract the value when the layer is encapsulated in a tunnel
+	Encapsulated bool `mapstructure:"encapsulated"`
+
+	FieldDestination `mapstructure:",squash"`
+}
+
+// FieldDestination describes where a decoded value is stored
+type FieldDestination struct {
+	// The name of a goflow2 flow message field, for example InIf or SamplingRate, which is overwritten by the value
+	// Any other name creates a custom field that is added to the log attributes with this name
+	Destination string `mapstructure:"destination"`
+
+	// The type of a custom field, one of varint, string, bytes or address
+	// It is ignored when the destination is a flow message field
+	Type string `mapstructure:"type"`
+
+	// The byte order of the value, big or little
+	Endianness string `mapstructure:"endianness"`
+}
+
+// customField is a field that is not part of the goflow2 flow message
+// goflow2 stores it in the unknown fields of the message, under its protobuf index
+type customField struct {
+	name      string
+	fieldType string
+	index     int32
+}
+
+// Validate checks if the mapping configuration is valid
+// The producer configuration is also compiled so its errors are reported before the receiver starts
+func (mc *MappingConfig) Validate() error {
+	// The built-in attributes cannot be overwritten by custom fields
+	attrs := allFlowAttributes()
+
+	types := make(map[string]string)
+	validate := func(d FieldDestination) error {
+		if d.Destination == "" {
+			return fmt.Errorf("mapping destination must not be empty")
+		}
+		if d.Destination == flowDirectionField || d.Destination == applicationIDField || isReverseFieldName(d.Destination) || isFirewallFieldName(d.Destination) {
+			return fmt.Errorf("mapping destination %q is reserved", d.Destination)
+		}
+		if d.Endianness != "" && d.Endianness != string(protoproducer.BigEndian) && d.Endianness != string(protoproducer.LittleEndian) {
+			return fmt.Errorf("mapping endianness of %q must be big or little", d.Destination)
+		}
+		if isFlowMessageField(d.Destination) {
+			return nil
+		}
+		if _, ok := attrs.Get(d.Destination); ok {
+			return fmt.Errorf("mapping destination %q is a flow attribute", d.Destination)
+		}
+		switch d.customType() {
+		case customFieldTypeVarint, customFieldTypeString, customFieldTypeBytes, customFieldTypeAddress:
+		default:
+			return fmt.Errorf("mapping type of %q must be varint, string, bytes or address", d.Destination)
+		}
+		if t, ok := types[d.Destination]; ok && t != d.customType() {
+			return fmt.Errorf("mapping destination %q is used with different types", d.Destination)
+		}
+		types[d.Destination] = d.customType()
+		return nil
+	}
+
+	// The dictionary elements are validated with the mappings, so a mapping cannot use their names with another type
+	full, err := mc.withDictionaries()
+	if err != nil {
+		return err
+	}
+	for _, m := range full.IPFIX {
+		if err := validate(m.FieldDestination); err != nil {
+			return err
+		}
+	}
+	for _, m := range full.NetFlowV9 {
+		if err := validate(m.FieldDestination); err != nil {
+			return err
+		}
+	}
+	for _, m := range mc.SFlow {
+		if m.Layer == "" {
+			return fmt.Errorf("mapping layer of %q must not be empty", m.Destination)
+		}
+		if m.Offset < 0 || m.Length <= 0 {
+			return fmt.Errorf("mapping offset of %q must not be negative and its length must be greater than 0", m.Destination)
+		}
+		if err := validate(m.FieldDestination); err != nil {
+			return err
+		}
+	}
+
+	if _, err := full.producerConfig().Compile(); err != nil {
+		return fmt.Errorf("invalid field mapping: %w", err)
+	}
+	return nil
+}
+
+// customType returns the type of a custom field, varint when it is not set
+func (d FieldDestination) customType() string {
+	if d.Type == "" {
+		return customFieldTypeVarint
+	}
+	return d.Type
+}
+
+// customFields returns the custom fields in the order they first appear in the mappings
+func (mc *MappingConfig) customFields() []customField {
+	var fields []customField
+	seen := make(map[string]struct{})
+	add := func(d FieldDestination) {
+		if _, ok := seen[d.Destination]; ok || isFlowMessageField(d.Destination) {
+			return
+		}
+		seen[d.Destination] = struct{}{}
+		fields = append(fields, customField{
+			name:      d.Destination,
+			fieldType: d.customType(),
+			index:     int32(customFieldFirstIndex + len(fields)),
+		})
+	}
+
+	for _, m := range mc.IPFIX {
+		add(m.FieldDestination)
+	}
+	for _, m := range mc.NetFlowV9 {
+		add(m.FieldDestination)
+	}
+	for _, m := range mc.SFlow {
+		add(m.FieldDestination)
+	}
+	return fields
+}
+
+// protoType returns the type goflow2 stores the field with
+func (f customField) protoType() string {
+	if f.fieldType == customFieldTypeAddress {
+		return customFieldTypeBytes
+	}
+	return f.fieldType
+}
+
+// producerConfig converts the mappings into a goflow2 producer configuration
+func (mc *MappingConfig) producerConfig() *protoproducer.ProducerConfig {
+	cfg := &protoproducer.ProducerConfig{}
+
+	// goflow2 only stores a custom field when it is declared in the formatter
+	cfg.Formatter.Protobuf = append(cfg.Formatter.Protobuf, protoproducer.ProtobufFormatterConfig{
+		Name:  flowDirectionField,
+		Index: flowDirectionIndex,
+		Type:  customFieldTypeVarint,
+	})
+	cfg.Formatter.Protobuf = append(cfg.Formatter.Protobuf, protoproducer.ProtobufFormatterConfig{
+		Name:  applicationIDField,
+		Index: applicationIDIndex,
+		Type:  customFieldTypeBytes,
+	})
+	cfg.Formatter.Protobuf = append(cfg.Formatter.Protobuf, reverseFormatter()...)
+	cfg.Formatter.Protobuf = append(cfg.Formatter.Protobuf, firewallFormatter()...)
+	for _, field := range mc.customFields() {
+		cfg.Formatter.Protobuf = append(cfg.Formatter.Protobuf, protoproducer.ProtobufFormatterConfig{
+			Name:  field.name,
+			Index: field.index,
+			Type:  field.protoType(),
+		})
+	}
+
+	netflowMapping := func(builtin []protoproducer.NetFlowMapField, mappings []NetFlowFieldMapping) []protoproducer.NetFlowMapField {
+		// The built-in fields go first so a mapping of the same field replaces them
+		fields := make([]protoproducer.NetFlowMapField, 0, len(builtin)+len(mappings)+2)
+		fields = append(fields, protoproducer.NetFlowMapField{Type: flowDirectionType, Destination: flowDirectionField})
+		fields = append(fields, protoproducer.NetFlowMapField{Type: applicationIDType, Destination: applicationIDField})
+		fields = append(fields, builtin...)
+		for _, m := range mappings {
+			fields = append(fields, protoproducer.NetFlowMapField{
+				PenProvided: m.Pen != 0,
+				Type:        m.Field,
+				Pen:         m.Pen,
+				Destination: m.Destination,
+				Endian:      protoproducer.EndianType(m.Endianness),
+			})
+		}
+		return fields
+	}
+	// The reverse elements of biflows only exist in IPFIX
+	cfg.IPFIX.Mapping = netflowMapping(append(reverseMapping(), firewallMapping()...), mc.IPFIX)
+	cfg.NetFlowV9.Mapping = netflowMapping(firewallMapping(), mc.NetFlowV9)
+
+	for _, m := range mc.SFlow {
+		cfg.SFlow.Mapping = append(cfg.SFlow.Mapping, protoproducer.SFlowMapField{
+			Layer:        m.Layer,
+			Encapsulated: m.Encapsulated,
+			Offset:       m.Offset,
+			Length:       m.Length,
+			Destination:  m.Destination,
+			Endian:       protoproducer.EndianType(m.Endianness),
+		})
+	}
+
+	return cfg
+}
+
+// isFlowMessageField returns true if the name is an exported field of the goflow2 flow message
+func isFlowMessageField(name string) bool {
+	field, ok := reflect.TypeOf((*protoproducer.ProtoProducerMessage)(nil)).Elem().FieldByName(name)
+	return ok && field.IsExported()
+}
+
+// customFieldsEnricher adds the custom fields decoded by goflow2 to the flow attributes
+type customFieldsEnricher struct {
+	fields map[protowire.Number]customField
+}
+
+func newCustomFieldsEnricher(fields []customField) *customFieldsEnricher {
+	e := &customFieldsEnricher{fields: make(map[protowire.Number]customField, len(fields))}
+	for _, field := range fields {
+		e.fields[protowire.Number(field.index)] = field
+	}
+	return e
+}
+
+func (e *customFieldsEnricher) enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	rangeUnknownFields(pm, func(num protowire.Number, value uint64, raw []byte) {
+		field, ok := e.fields[num]
+		if !ok {
+			return
+		}
+		switch field.fieldType {
+		case customFieldTypeVarint:
+			attrs.PutInt(field.name, int64(value))
+		case customFieldTypeString:
+			// Fixed length strings are padded with zeros
+			attrs.PutStr(field.name, strings.TrimRight(string(raw), "\x00"))
+		case customFieldTypeBytes:
+			attrs.PutEmptyBytes(field.name).FromRaw(raw)
+		case customFieldTypeAddress:
+			putAddress(attrs, field.name, raw)
+		}
+	})
+}
+
+// getFlowDirection returns the name of the flow direction, if the device sent it
+func getFlowDirection(pm *protoproducer.ProtoProducerMessage) (string, bool) {
+	var direction string
+	var found bool
+	rangeUnknownFields(pm, func(num protowire.Number, value uint64, _ []byte) {
+		if num != flowDirectionIndex {
+			return
+		}
+		found = true
+		direction = "unknown"
+		if name, ok := flowDirectionNames[value]; ok {
+			direction = name
+		}
+	})
+	return direction, found
+}
+
+// rangeUnknownFields calls f for each of the custom fields that goflow2 stored in the message
+// Varint fields are passed as value and bytes fields as raw
+func rangeUnknownFields(pm *protoproducer.ProtoProducerMessage, f func(num protowire.Number, value uint64, raw []byte)) {
+	unknown := pm.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		num, wireType, n := protowire.ConsumeTag(unknown)
+		if n < 0 {
+			return
+		}
+		unknown = unknown[n:]
+
+		var value uint64
+		var raw []byte
+		switch wireType {
+		case protowire.VarintType:
+			value, n = protowire.ConsumeVarint(unknown)
+		case protowire.BytesType:
+			raw, n = protowire.ConsumeBytes(unknown)
+		default:
+			n = protowire.ConsumeFieldValue(num, wireType, unknown)
+		}
+		if n < 0 {
+			return
+		}
+		unknown = unknown[n:]
+
+		f(num, value, raw)
+	}
+}
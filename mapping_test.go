@@ -0,0 +1,156 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/netsampler/goflow2/v2/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+)
+
+// ipfixPacket builds an IPFIX packet with a template and a single data record
+// The record has the octet count, the flow direction, the biflow direction, the ingress interface and an enterprise specific field
+func ipfixPacket() []byte {
+	template := []byte{
+		0, 2, 0, 0, // template set, length
+		1, 0, 0, 5, // template 256 with 5 fields
+		0, 1, 0, 8, // octetDeltaCount
+		0, 61, 0, 1, // flowDirection
+		0, 239, 0, 1, // biflowDirection
+		0, 10, 0, 4, // ingressInterface
+		0x80, 0x01, 0, 4, // enterprise field 1
+		0, 0, 0, 9, // pen 9
+	}
+	binary.BigEndian.PutUint16(template[2:], uint16(len(template)))
+
+	data := []byte{1, 0, 0, 0}
+	data = binary.BigEndian.AppendUint64(data, 1500)
+	data = append(data, 1, 2)
+	data = binary.BigEndian.AppendUint32(data, 7)
+	data = append(data, 'e', 't', 'h', '0')
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)))
+
+	header := make([]byte, 16)
+	binary.BigEndian.PutUint16(header[0:], 10)
+	binary.BigEndian.PutUint16(header[2:], uint16(16+len(template)+len(data)))
+	binary.BigEndian.PutUint32(header[12:], 1) // observation domain
+
+	return append(append(header, template...), data...)
+}
+
+func TestMappingCustomFields(t *testing.T) {
+	mapping := MappingConfig{
+		IPFIX: []NetFlowFieldMapping{
+			{Field: 239, FieldDestination: FieldDestination{Destination: "flow.biflow_direction"}},
+			{Field: 1, Pen: 9, FieldDestination: FieldDestination{Destination: "interface.name", Type: customFieldTypeString}},
+			{Field: 10, FieldDestination: FieldDestination{Destination: "SrcPort"}},
+		},
+	}
+	require.NoError(t, mapping.Validate())
+
+	cfgm, err := mapping.producerConfig().Compile()
+	require.NoError(t, err)
+	protoProducer, err := protoproducer.CreateProtoProducer(cfgm, protoproducer.CreateSamplingSystem)
+	require.NoError(t, err)
+
+	sink := &consumertest.LogsSink{}
+	pipe := utils.NewNetFlowPipe(&utils.PipeConfig{
+		Producer: newOtelLogsProducer(protoProducer, sink, zap.NewNop(), newCustomFieldsEnricher(mapping.customFields())),
+	})
+	defer pipe.Close()
+
+	err = pipe.DecodeFlow(&utils.Message{Src: netip.MustParseAddrPort("127.0.0.1:5000"), Payload: ipfixPacket(), Received: time.Now()})
+	require.NoError(t, err)
+
+	require.Len(t, sink.AllLogs(), 1)
+	require.Equal(t, 1, sink.AllLogs()[0].LogRecordCount())
+	attrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes()
+
+	assertIntAttribute(t, attrs, "flow.io.bytes", 1500)
+	assertIntAttribute(t, attrs, "flow.biflow_direction", 2)
+	assertStrAttribute(t, attrs, "interface.name", "eth0")
+	// The flow message fields are overwritten instead of added as attributes
+	assertIntAttribute(t, attrs, "source.port", 7)
+	_, ok := attrs.Get("SrcPort")
+	assert.False(t, ok)
+}
+
+func TestMappingCustomFieldIndexes(t *testing.T) {
+	mapping := MappingConfig{
+		IPFIX: []NetFlowFieldMapping{
+			{Field: 239, FieldDestination: FieldDestination{Destination: "flow.biflow_direction"}},
+			{Field: 10, FieldDestination: FieldDestination{Destination: "InIf"}},
+		},
+		NetFlowV9: []NetFlowFieldMapping{
+			{Field: 239, FieldDestination: FieldDestination{Destination: "flow.biflow_direction"}},
+		},
+		SFlow: []SFlowFieldMapping{
+			{Layer: "udp", Offset: 48, Length: 16, FieldDestination: FieldDestination{Destination: "udp.checksum", Type: customFieldTypeBytes}},
+		},
+	}
+
+	assert.Equal(t, []customField{
+		{name: "flow.biflow_direction", fieldType: customFieldTypeVarint, index: customFieldFirstIndex},
+		{name: "udp.checksum", fieldType: customFieldTypeBytes, index: customFieldFirstIndex + 1},
+	}, mapping.customFields())
+}
+
+func TestMappingValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping MappingConfig
+		err     string
+	}{
+		{
+			name: "empty destination",
+			mapping: MappingConfig{
+				IPFIX: []NetFlowFieldMapping{{Field: 61}},
+			},
+			err: "mapping destination must not be empty",
+		},
+		{
+			name: "invalid endianness",
+			mapping: MappingConfig{
+				NetFlowV9: []NetFlowFieldMapping{{Field: 239, FieldDestination: FieldDestination{Destination: "flow.biflow_direction", Endianness: "middle"}}},
+			},
+			err: `mapping endianness of "flow.biflow_direction" must be big or little`,
+		},
+		{
+			name: "different types",
+			mapping: MappingConfig{
+				IPFIX:     []NetFlowFieldMapping{{Field: 239, FieldDestination: FieldDestination{Destination: "flow.biflow_direction"}}},
+				NetFlowV9: []NetFlowFieldMapping{{Field: 239, FieldDestination: FieldDestination{Destination: "flow.biflow_direction", Type: customFieldTypeString}}},
+			},
+			err: `mapping destination "flow.biflow_direction" is used with different types`,
+		},
+		{
+			name: "missing layer",
+			mapping: MappingConfig{
+				SFlow: []SFlowFieldMapping{{Offset: 48, Length: 16, FieldDestination: FieldDestination{Destination: "udp.checksum"}}},
+			},
+			err: `mapping layer of "udp.checksum" must not be empty`,
+		},
+		{
+			name: "zero length",
+			mapping: MappingConfig{
+				SFlow: []SFlowFieldMapping{{Layer: "udp", Offset: 48, FieldDestination: FieldDestination{Destination: "udp.checksum"}}},
+			},
+			err: `mapping offset of "udp.checksum" must not be negative and its length must be greater than 0`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.EqualError(t, tt.mapping.Validate(), tt.err)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"strings"
+
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/dynatrace-extensions/netflowreceiver/internal/metadata"
+)
+
+const (
+	metricBytes   = "flow.io.bytes"
+	metricPackets = "flow.io.packets"
+	metricFlows   = "flow.count"
+)
+
+// flowDataPoints holds the data points of all metrics for a single set of dimensions
+type flowDataPoints struct {
+	bytes   pmetric.NumberDataPoint
+	packets pmetric.NumberDataPoint
+	flows   pmetric.NumberDataPoint
+}
+
+func (d flowDataPoints) add(pm *protoproducer.ProtoProducerMessage, bytes, packets, flows uint64) {
+	d.bytes.SetIntValue(d.bytes.IntValue() + int64(bytes))
+	d.packets.SetIntValue(d.packets.IntValue() + int64(packets))
+	d.flows.SetIntValue(d.flows.IntValue() + int64(flows))
+
+	// The data points cover from the earliest flow start to the latest time received
+	start := pcommon.Timestamp(pm.TimeFlowStartNs)
+	received := pcommon.Timestamp(pm.TimeReceivedNs)
+	for _, dp := range [3]pmetric.NumberDataPoint{d.bytes, d.packets, d.flows} {
+		if start < dp.StartTimestamp() {
+			dp.SetStartTimestamp(start)
+		}
+		if received > dp.Timestamp() {
+			dp.SetTimestamp(received)
+		}
+	}
+}
+
+// newDeltaSum adds a monotonic delta sum metric to the list of metrics
+func newDeltaSum(metrics pmetric.MetricSlice, name, unit, description string) pmetric.Sum {
+	metric := metrics.AppendEmpty()
+	metric.SetName(name)
+	metric.SetUnit(unit)
+	metric.SetDescription(description)
+	sum := metric.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	return sum
+}
+
+// buildMetrics converts a set of flow messages into sum metrics
+// Flows with the same values for the configured dimensions are added into the same data point
+// The firewall and NAT events are left out, they describe a connection rather than its traffic,
+// except the deleted and updated flows of the firewalls that are counted with the traffic of both directions
+func buildMetrics(flowMessageSet []producer.ProducerMessage, dimensions []string, enrichers ...flowEnricher) pmetric.Metrics {
+	metrics := pmetric.NewMetrics()
+	scopeMetrics := metrics.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	scopeMetrics.Scope().SetName(metadata.ScopeName)
+	scopeMetrics.Scope().Attributes().PutStr("receiver", metadata.Type.String())
+
+	bytesSum := newDeltaSum(scopeMetrics.Metrics(), metricBytes, "By", "The number of bytes seen in flows")
+	packetsSum := newDeltaSum(scopeMetrics.Metrics(), metricPackets, "{packet}", "The number of packets seen in flows")
+	flowsSum := newDeltaSum(scopeMetrics.Metrics(), metricFlows, "{flow}", "The number of flow records")
+
+	points := make(map[string]flowDataPoints)
+	attrs := pcommon.NewMap()
+	for _, msg := range flowMessageSet {
+		event, isEvent := securityEventOf(msg)
+		if isEvent && !event.hasTraffic {
+			continue
+		}
+		attrs.Clear()
+		pm, flows, err := putMessageAttributes(msg, attrs, enrichers...)
+		if err != nil {
+			continue
+		}
+		bytes, packets := pm.Bytes, pm.Packets
+		if isEvent {
+			bytes, packets = event.bytes, event.packets
+		}
+		key := dimensionsKey(attrs, dimensions)
+
+		dps, ok := points[key]
+		if !ok {
+			dps = flowDataPoints{
+				bytes:   bytesSum.DataPoints().AppendEmpty(),
+				packets: packetsSum.DataPoints().AppendEmpty(),
+				flows:   flowsSum.DataPoints().AppendEmpty(),
+			}
+			for _, dp := range [3]pmetric.NumberDataPoint{dps.bytes, dps.packets, dps.flows} {
+				copyDimensions(attrs, dp.Attributes(), dimensions)
+				dp.SetIntValue(0)
+				dp.SetStartTimestamp(pcommon.Timestamp(pm.TimeFlowStartNs))
+				dp.SetTimestamp(pcommon.Timestamp(pm.TimeReceivedNs))
+			}
+			points[key] = dps
+		}
+		dps.add(pm, bytes, packets, flows)
+	}
+
+	return metrics
+}
+
+// dimensionsKey builds a key that identifies the values of the dimensions in the attributes
+func dimensionsKey(attrs pcommon.Map, dimensions []string) string {
+	var sb strings.Builder
+	for _, dimension := range dimensions {
+		if v, ok := attrs.Get(dimension); ok {
+			sb.WriteString(v.AsString())
+		}
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// copyDimensions copies the configured dimensions from the flow attributes to the data point attributes
+func copyDimensions(from, to pcommon.Map, dimensions []string) {
+	to.EnsureCapacity(len(dimensions))
+	for _, dimension := range dimensions {
+		if v, ok := from.Get(dimension); ok {
+			v.CopyTo(to.PutEmpty(dimension))
+		}
+	}
+}
+
+// securityEventOf returns the firewall or NAT event of a message, if it is one
+func securityEventOf(msg producer.ProducerMessage) (securityEvent, bool) {
+	pm, ok := msg.(*protoproducer.ProtoProducerMessage)
+	if !ok {
+		return securityEvent{}, false
+	}
+	return getSecurityEvent(pm)
+}
@@ -0,0 +1,72 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"net/netip"
+	"testing"
+
+	flowpb "github.com/netsampler/goflow2/v2/pb"
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	semconv "go.opentelemetry.io/collector/semconv/v1.27.0"
+)
+
+func newTestFlow(src string, proto uint32, bytes, packets uint64, start, received uint64) *protoproducer.ProtoProducerMessage {
+	return &protoproducer.ProtoProducerMessage{
+		FlowMessage: flowpb.FlowMessage{
+			SrcAddr:         netip.MustParseAddr(src).AsSlice(),
+			DstAddr:         netip.MustParseAddr("10.0.0.1").AsSlice(),
+			SamplerAddress:  netip.MustParseAddr("192.168.1.100").AsSlice(),
+			Type:            flowpb.FlowMessage_IPFIX,
+			Etype:           0x800,
+			Proto:           proto,
+			Bytes:           bytes,
+			Packets:         packets,
+			TimeFlowStartNs: start,
+			TimeReceivedNs:  received,
+		},
+	}
+}
+
+func TestBuildMetrics(t *testing.T) {
+	flows := []producer.ProducerMessage{
+		newTestFlow("192.168.1.1", 6, 100, 1, 1000, 5000),
+		newTestFlow("192.168.1.2", 6, 200, 2, 500, 6000),
+		newTestFlow("192.168.1.1", 17, 50, 1, 2000, 4000),
+	}
+
+	metrics := buildMetrics(flows, []string{semconv.AttributeNetworkTransport})
+	require.Equal(t, 3, metrics.MetricCount())
+
+	ms := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	expected := map[string][2]int64{
+		metricBytes:   {300, 50},
+		metricPackets: {3, 1},
+		metricFlows:   {2, 1},
+	}
+	for i := 0; i < ms.Len(); i++ {
+		m := ms.At(i)
+		require.Contains(t, expected, m.Name())
+		assert.Equal(t, pmetric.AggregationTemporalityDelta, m.Sum().AggregationTemporality())
+		assert.True(t, m.Sum().IsMonotonic())
+
+		dps := m.Sum().DataPoints()
+		require.Equal(t, 2, dps.Len())
+
+		tcp := dps.At(0)
+		assert.Equal(t, map[string]any{semconv.AttributeNetworkTransport: "tcp"}, tcp.Attributes().AsRaw())
+		assert.Equal(t, expected[m.Name()][0], tcp.IntValue())
+		assert.Equal(t, pcommon.Timestamp(500), tcp.StartTimestamp())
+		assert.Equal(t, pcommon.Timestamp(6000), tcp.Timestamp())
+
+		udp := dps.At(1)
+		assert.Equal(t, map[string]any{semconv.AttributeNetworkTransport: "udp"}, udp.Attributes().AsRaw())
+		assert.Equal(t, expected[m.Name()][1], udp.IntValue())
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// prefixTrie is a binary trie that finds the longest prefix that contains an address
+// A lookup visits at most one node per bit of the address, no matter how many prefixes there are
+type prefixTrie struct {
+	ipv4 *trieNode
+	ipv6 *trieNode
+}
+
+type trieNode struct {
+	children [2]*trieNode
+	name     string
+	// set is true when a prefix ends in this node
+	set bool
+}
+
+func newPrefixTrie() *prefixTrie {
+	return &prefixTrie{ipv4: &trieNode{}, ipv6: &trieNode{}}
+}
+
+// insert adds the prefix to the trie, replacing the name of the same prefix if it was already added
+// The addresses are unmapped before they are looked up, so an IPv4-mapped IPv6 prefix is added as the IPv4 prefix
+func (t *prefixTrie) insert(prefix netip.Prefix, name string) {
+	prefix = prefix.Masked()
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	node := t.root(prefix.Addr())
+	addr := prefix.Addr().AsSlice()
+	for i := 0; i < prefix.Bits(); i++ {
+		bit := addrBit(addr, i)
+		if node.children[bit] == nil {
+			node.children[bit] = &trieNode{}
+		}
+		node = node.children[bit]
+	}
+	node.name, node.set = name, true
+}
+
+// lookup returns the name of the longest prefix that contains the address
+func (t *prefixTrie) lookup(addr netip.Addr) (string, bool) {
+	addr = addr.Unmap()
+	node := t.root(addr)
+	b := addr.AsSlice()
+
+	var name string
+	var found bool
+	for i := 0; node != nil; i++ {
+		if node.set {
+			name, found = node.name, true
+		}
+		if i == addr.BitLen() {
+			break
+		}
+		node = node.children[addrBit(b, i)]
+	}
+	return name, found
+}
+
+func (t *prefixTrie) root(addr netip.Addr) *trieNode {
+	if addr.Is4() {
+		return t.ipv4
+	}
+	return t.ipv6
+}
+
+// addrBit returns the bit of the address at position i, starting from the most significant bit
+func addrBit(addr []byte, i int) int {
+	return int(addr[i/8]>>(7-i%8)) & 1
+}
+
+// buildPrefixTrie creates a trie with the prefixes of the config and, if it is set, of the file
+// The prefixes of the file replace the ones of the config
+func buildPrefixTrie(prefixes map[string]string, path string) (*prefixTrie, error) {
+	trie := newPrefixTrie()
+	for p, name := range prefixes {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, err
+		}
+		trie.insert(prefix, name)
+	}
+	if path == "" {
+		return trie, nil
+	}
+
+	filePrefixes, err := readPrefixesFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for p, name := range filePrefixes {
+		prefix, err := netip.ParsePrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("prefix %q is not valid: %w", p, err)
+		}
+		trie.insert(prefix, name)
+	}
+	return trie, nil
+}
+
+// readPrefixesFile reads the prefixes of a YAML file with a map of prefixes to names
+// or of a CSV file with a prefix and a name in each line
+func readPrefixesFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prefixes := make(map[string]string)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.NewDecoder(f).Decode(&prefixes); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+	case ".csv":
+		r := csv.NewReader(f)
+		r.Comment = '#'
+		r.FieldsPerRecord = 2
+		r.TrimLeadingSpace = true
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			prefixes[record[0]] = record[1]
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file extension %q, must be .csv, .yaml or .yml", filepath.Ext(path))
+	}
+	return prefixes, nil
+}
+
+// networksEnricher adds the name of the network of the source and destination addresses
+type networksEnricher struct {
+	trie *prefixTrie
+	// file is only set when the prefixes are loaded from a file, it replaces trie
+	file *fileReloader[*prefixTrie]
+}
+
+func newNetworksEnricher(cfg NetworksConfig, logger *zap.Logger) *networksEnricher {
+	if cfg.File == "" {
+		// The prefixes were validated with the config
+		trie, _ := buildPrefixTrie(cfg.Prefixes, "")
+		return &networksEnricher{trie: trie}
+	}
+	return &networksEnricher{
+		file: newFileReloader(cfg.File, cfg.ReloadInterval, logger, func(path string) (*prefixTrie, error) {
+			return buildPrefixTrie(cfg.Prefixes, path)
+		}),
+	}
+}
+
+func (e *networksEnricher) start() error {
+	if e.file == nil {
+		return nil
+	}
+	if err := e.file.start(); err != nil {
+		return fmt.Errorf("failed to load the networks file: %w", err)
+	}
+	return nil
+}
+
+func (e *networksEnricher) stop() {
+	if e.file != nil {
+		e.file.stop()
+	}
+}
+
+func (e *networksEnricher) enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	trie := e.trie
+	if e.file != nil {
+		trie = e.file.get()
+	}
+
+	if addr, ok := netip.AddrFromSlice(pm.SrcAddr); ok {
+		if name, ok := trie.lookup(addr); ok {
+			attrs.PutStr("source.network.name", name)
+		}
+	}
+	if addr, ok := netip.AddrFromSlice(pm.DstAddr); ok {
+		if name, ok := trie.lookup(addr); ok {
+			attrs.PutStr("destination.network.name", name)
+		}
+	}
+}
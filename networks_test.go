@@ -0,0 +1,148 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+func TestPrefixTrie(t *testing.T) {
+	trie := newPrefixTrie()
+	trie.insert(netip.MustParsePrefix("10.0.0.0/8"), "internal")
+	trie.insert(netip.MustParsePrefix("10.1.0.0/16"), "datacenter-fra")
+	trie.insert(netip.MustParsePrefix("10.1.2.3/32"), "gateway")
+	trie.insert(netip.MustParsePrefix("192.168.7.0/16"), "office")
+	trie.insert(netip.MustParsePrefix("2001:db8::/32"), "documentation")
+	trie.insert(netip.MustParsePrefix("0.0.0.0/0"), "internet")
+	trie.insert(netip.MustParsePrefix("::ffff:172.16.0.0/108"), "lab")
+
+	tests := map[string]string{
+		"10.200.0.1":        "internal",
+		"10.1.200.1":        "datacenter-fra",
+		"10.1.2.3":          "gateway",
+		"192.168.200.1":     "office",
+		"::ffff:10.1.0.1":   "datacenter-fra",
+		"2001:db8:1::1":     "documentation",
+		"8.8.8.8":           "internet",
+		"172.16.4.1":        "lab",
+		"::ffff:172.32.0.1": "internet",
+		"2a00:1450:4001::1": "",
+	}
+	for addr, expected := range tests {
+		name, ok := trie.lookup(netip.MustParseAddr(addr))
+		assert.Equal(t, expected != "", ok, addr)
+		assert.Equal(t, expected, name, addr)
+	}
+
+	// The same prefix replaces the previous name
+	trie.insert(netip.MustParsePrefix("10.1.0.0/16"), "datacenter-muc")
+	name, _ := trie.lookup(netip.MustParseAddr("10.1.200.1"))
+	assert.Equal(t, "datacenter-muc", name)
+}
+
+func TestReadPrefixesFile(t *testing.T) {
+	dir := t.TempDir()
+
+	csvPath := filepath.Join(dir, "networks.csv")
+	require.NoError(t, os.WriteFile(csvPath, []byte("# prefix,name\n10.1.0.0/16,datacenter-fra\n192.168.0.0/16, office\n"), 0o600))
+	prefixes, err := readPrefixesFile(csvPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"10.1.0.0/16": "datacenter-fra", "192.168.0.0/16": "office"}, prefixes)
+
+	yamlPath := filepath.Join(dir, "networks.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte("10.1.0.0/16: datacenter-fra\n192.168.0.0/16: office\n"), 0o600))
+	prefixes, err = readPrefixesFile(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"10.1.0.0/16": "datacenter-fra", "192.168.0.0/16": "office"}, prefixes)
+
+	_, err = readPrefixesFile(filepath.Join(dir, "networks.json"))
+	assert.Error(t, err)
+
+	invalidPath := filepath.Join(dir, "invalid.csv")
+	require.NoError(t, os.WriteFile(invalidPath, []byte("10.1.0.0/16\n"), 0o600))
+	_, err = readPrefixesFile(invalidPath)
+	assert.Error(t, err)
+}
+
+func TestNetworksEnricher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "networks.csv")
+	require.NoError(t, os.WriteFile(path, []byte("10.1.0.0/16,datacenter-fra\n"), 0o600))
+
+	enricher := newNetworksEnricher(NetworksConfig{
+		Prefixes:       map[string]string{"10.1.0.0/16": "datacenter", "192.168.0.0/16": "office"},
+		File:           path,
+		ReloadInterval: 10 * time.Millisecond,
+	}, zap.NewNop())
+	require.NoError(t, enricher.start())
+	defer enricher.stop()
+
+	pm := &protoproducer.ProtoProducerMessage{}
+	pm.SrcAddr = netip.MustParseAddr("10.1.2.3").AsSlice()
+	pm.DstAddr = netip.MustParseAddr("192.168.1.1").AsSlice()
+
+	attrs := pcommon.NewMap()
+	enricher.enrich(pm, attrs)
+	// The file replaces the prefix of the config
+	assertStrAttribute(t, attrs, "source.network.name", "datacenter-fra")
+	assertStrAttribute(t, attrs, "destination.network.name", "office")
+
+	require.NoError(t, os.WriteFile(path, []byte("10.1.0.0/16,datacenter-muc\n10.1.2.0/24,servers\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		attrs.Clear()
+		enricher.enrich(pm, attrs)
+		name, ok := attrs.Get("source.network.name")
+		return ok && name.Str() == "servers"
+	}, time.Second, 10*time.Millisecond)
+
+	// Addresses outside of every prefix are not named
+	pm.SrcAddr = netip.MustParseAddr("8.8.8.8").AsSlice()
+	attrs.Clear()
+	enricher.enrich(pm, attrs)
+	_, ok := attrs.Get("source.network.name")
+	assert.False(t, ok)
+}
+
+func TestNetworksEnricherStatic(t *testing.T) {
+	enricher := newNetworksEnricher(NetworksConfig{Prefixes: map[string]string{"10.0.0.0/8": "internal"}}, zap.NewNop())
+	require.NoError(t, enricher.start())
+	defer enricher.stop()
+
+	pm := &protoproducer.ProtoProducerMessage{}
+	pm.SrcAddr = netip.MustParseAddr("10.1.2.3").AsSlice()
+
+	attrs := pcommon.NewMap()
+	enricher.enrich(pm, attrs)
+	assertStrAttribute(t, attrs, "source.network.name", "internal")
+}
+
+func TestNetworksEnricherInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "networks.csv")
+	require.NoError(t, os.WriteFile(path, []byte("not-a-prefix,office\n"), 0o600))
+
+	enricher := newNetworksEnricher(NetworksConfig{File: path}, zap.NewNop())
+	assert.ErrorContains(t, enricher.start(), `prefix "not-a-prefix" is not valid`)
+}
+
+func BenchmarkPrefixTrieLookup(b *testing.B) {
+	trie := newPrefixTrie()
+	for i := 0; i < 10_000; i++ {
+		trie.insert(netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i >> 8), byte(i), 0}), 24), "network")
+	}
+	addr := netip.MustParseAddr("10.20.30.40")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		trie.lookup(addr)
+	}
+}
@@ -180,6 +180,9 @@ var (
 		2: "netflow_v5",
 		3: "netflow_v9",
 		4: "ipfix",
+
+		int32(flowTypeNetFlowV1): "netflow_v1",
+		int32(flowTypeNetFlowV7): "netflow_v7",
 	}
 )
 
@@ -204,18 +207,25 @@ func getFlowTypeName(flowType int32) string {
 	return "unknown"
 }
 
+// flowEnricher adds attributes to a flow on top of the ones extracted from the message
+type flowEnricher interface {
+	enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map)
+}
+
 // addMessageAttributes parses the message attributes and adds them to the log record
-func addMessageAttributes(m producer.ProducerMessage, r *plog.LogRecord) error {
-	// we know msg is ProtoProducerMessage because that is the parent producer
-	pm, ok := m.(*protoproducer.ProtoProducerMessage)
-	if !ok {
-		return errors.New("this flow message is not ProtoProducerMessage, this is not expected")
+func addMessageAttributes(m producer.ProducerMessage, r *plog.LogRecord, enrichers ...flowEnricher) error {
+	// The firewall and NAT events are logged as events rather than as flows
+	if pm, ok := m.(*protoproducer.ProtoProducerMessage); ok {
+		if event, ok := getSecurityEvent(pm); ok {
+			addEventAttributes(pm, event, r, enrichers...)
+			return nil
+		}
 	}
 
-	// Parse IP addresses bytes to netip.Addr
-	srcAddr, _ := netip.AddrFromSlice(pm.SrcAddr)
-	dstAddr, _ := netip.AddrFromSlice(pm.DstAddr)
-	samplerAddr, _ := netip.AddrFromSlice(pm.SamplerAddress)
+	pm, _, err := putMessageAttributes(m, r.Attributes(), enrichers...)
+	if err != nil {
+		return err
+	}
 
 	// Time the receiver received the message
 	receivedTime := time.Unix(0, int64(pm.TimeReceivedNs))
@@ -224,26 +234,64 @@ func addMessageAttributes(m producer.ProducerMessage, r *plog.LogRecord) error {
 	r.SetObservedTimestamp(pcommon.NewTimestampFromTime(startTime))
 	r.SetTimestamp(pcommon.NewTimestampFromTime(receivedTime))
 
+	return nil
+}
+
+// putMessageAttributes adds the attributes of a flow message to the map
+// It returns the proto message behind it and the number of flows that it represents
+func putMessageAttributes(m producer.ProducerMessage, attrs pcommon.Map, enrichers ...flowEnricher) (*protoproducer.ProtoProducerMessage, uint64, error) {
+	var pm *protoproducer.ProtoProducerMessage
+	var flows uint64
+
+	switch msg := m.(type) {
+	// we know msg is ProtoProducerMessage because that is the parent producer
+	case *protoproducer.ProtoProducerMessage:
+		addFlowAttributes(msg, attrs)
+		for _, enricher := range enrichers {
+			enricher.enrich(msg, attrs)
+		}
+		pm, flows = msg, 1
+	// unless the flows were aggregated
+	case *aggregatedFlowMessage:
+		msg.addAttributes(attrs, enrichers...)
+		pm, flows = msg.flow, msg.flows
+	// or stitched, then the flows of both directions are counted
+	case *stitchedFlowMessage:
+		msg.addAttributes(attrs, enrichers...)
+		pm, flows = msg.total(), 2
+	default:
+		return nil, 0, errors.New("this flow message is not ProtoProducerMessage, this is not expected")
+	}
+
+	return pm, flows, nil
+}
+
+// addFlowAttributes adds the attributes of a flow message to a map
+// The same attributes are used for log records and as metric dimensions
+func addFlowAttributes(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	// Parse IP addresses bytes to netip.Addr
+	srcAddr, _ := netip.AddrFromSlice(pm.SrcAddr)
+	dstAddr, _ := netip.AddrFromSlice(pm.DstAddr)
+	samplerAddr, _ := netip.AddrFromSlice(pm.SamplerAddress)
+
 	// Source and destination attributes
-	r.Attributes().PutStr(semconv.AttributeSourceAddress, srcAddr.String())
-	r.Attributes().PutInt(semconv.AttributeSourcePort, int64(pm.SrcPort))
-	r.Attributes().PutStr(semconv.AttributeDestinationAddress, dstAddr.String())
-	r.Attributes().PutInt(semconv.AttributeDestinationPort, int64(pm.DstPort))
+	attrs.PutStr(semconv.AttributeSourceAddress, srcAddr.String())
+	attrs.PutInt(semconv.AttributeSourcePort, int64(pm.SrcPort))
+	attrs.PutStr(semconv.AttributeDestinationAddress, dstAddr.String())
+	attrs.PutInt(semconv.AttributeDestinationPort, int64(pm.DstPort))
 
 	// Network attributes
-	r.Attributes().PutStr(semconv.AttributeNetworkTransport, getTransportName(pm.Proto))
-	r.Attributes().PutStr(semconv.AttributeNetworkType, getEtypeName(pm.Etype))
+	attrs.PutStr(semconv.AttributeNetworkTransport, getTransportName(pm.Proto))
+	attrs.PutStr(semconv.AttributeNetworkType, getEtypeName(pm.Etype))
 
 	// There is no semconv as of today for these
-	r.Attributes().PutInt("flow.io.bytes", int64(pm.Bytes))
-	r.Attributes().PutInt("flow.io.packets", int64(pm.Packets))
-	r.Attributes().PutStr("flow.type", getFlowTypeName(int32(pm.Type)))
-	r.Attributes().PutInt("flow.sequence_num", int64(pm.SequenceNum))
-	r.Attributes().PutInt("flow.time_received", int64(pm.TimeReceivedNs))
-	r.Attributes().PutInt("flow.start", int64(pm.TimeFlowStartNs))
-	r.Attributes().PutInt("flow.end", int64(pm.TimeFlowEndNs))
-	r.Attributes().PutInt("flow.sampling_rate", int64(pm.SamplingRate))
-	r.Attributes().PutStr("flow.sampler_address", samplerAddr.String())
-
-	return nil
+	attrs.PutInt("flow.io.bytes", int64(pm.Bytes))
+	attrs.PutInt("flow.io.packets", int64(pm.Packets))
+	attrs.PutStr("flow.type", getFlowTypeName(int32(pm.Type)))
+	attrs.PutInt("flow.sequence_num", int64(pm.SequenceNum))
+	attrs.PutInt("flow.time_received", int64(pm.TimeReceivedNs))
+	attrs.PutInt("flow.start", int64(pm.TimeFlowStartNs))
+	attrs.PutInt("flow.end", int64(pm.TimeFlowEndNs))
+	attrs.PutInt("flow.sampling_rate", int64(pm.SamplingRate))
+	attrs.PutStr("flow.sampler_address", samplerAddr.String())
 }
@@ -116,3 +116,19 @@ func TestEmptyConvertToOtel(t *testing.T) {
 
 	assert.Equal(t, expectedAttributes, record.Attributes())
 }
+
+func assertIntAttribute(t *testing.T, attrs pcommon.Map, key string, want int64) {
+	t.Helper()
+	v, ok := attrs.Get(key)
+	if assert.True(t, ok, "missing attribute %s", key) {
+		assert.Equal(t, want, v.Int(), "attribute %s", key)
+	}
+}
+
+func assertStrAttribute(t *testing.T, attrs pcommon.Map, key string, want string) {
+	t.Helper()
+	v, ok := attrs.Get(key)
+	if assert.True(t, ok, "missing attribute %s", key) {
+		assert.Equal(t, want, v.Str(), "attribute %s", key)
+	}
+}
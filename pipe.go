@@ -0,0 +1,70 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+
+	"github.com/netsampler/goflow2/v2/utils"
+)
+
+var _ utils.FlowPipe = (*autoFlowPipe)(nil)
+
+// FlowDetectionError is returned when the flow scheme cannot tell which protocol a packet is using
+type FlowDetectionError struct {
+	// Src is the address of the device that sent the packet
+	Src netip.AddrPort
+	// Version is the value read from the packet header, zero if the packet was too short
+	Version uint32
+	Reason  string
+}
+
+func (e *FlowDetectionError) Error() string {
+	return fmt.Sprintf("could not detect the flow protocol of the message from %s: %s", e.Src.String(), e.Reason)
+}
+
+// autoFlowPipe inspects the version header of each packet and forwards it to the netflow or sflow pipe
+// This allows a single port to receive NetFlow v1, v5, v7 and v9, IPFIX and sFlow v5 at the same time
+type autoFlowPipe struct {
+	sflow   *utils.SFlowPipe
+	netflow *netflowPipe
+}
+
+func newAutoFlowPipe(cfg *utils.PipeConfig) *autoFlowPipe {
+	return &autoFlowPipe{
+		sflow:   utils.NewSFlowPipe(cfg),
+		netflow: newNetFlowPipe(cfg),
+	}
+}
+
+func (p *autoFlowPipe) DecodeFlow(msg any) error {
+	pkt, ok := msg.(*utils.Message)
+	if !ok {
+		return fmt.Errorf("flow is not *Message")
+	}
+
+	// sFlow starts with a 32 bit version, NetFlow and IPFIX with a 16 bit version
+	if len(pkt.Payload) < 4 {
+		return &FlowDetectionError{Src: pkt.Src, Reason: "packet is too short"}
+	}
+	version := binary.BigEndian.Uint32(pkt.Payload)
+
+	if version == 5 {
+		return p.sflow.DecodeFlow(msg)
+	}
+
+	switch version >> 16 {
+	case 1, 5, 7, 9, 10:
+		return p.netflow.DecodeFlow(msg)
+	default:
+		return &FlowDetectionError{Src: pkt.Src, Version: version, Reason: fmt.Sprintf("unknown version header %#08x", version)}
+	}
+}
+
+func (p *autoFlowPipe) Close() {
+	p.sflow.Close()
+	p.netflow.Close()
+}
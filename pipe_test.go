@@ -0,0 +1,87 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/netsampler/goflow2/v2/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.uber.org/zap"
+)
+
+// netflowV5Packet builds a NetFlow v5 packet with a single flow record
+func netflowV5Packet() []byte {
+	header := make([]byte, 24)
+	binary.BigEndian.PutUint16(header[0:], 5) // version
+	binary.BigEndian.PutUint16(header[2:], 1) // count
+	binary.BigEndian.PutUint32(header[16:], 42)
+
+	record := make([]byte, 48)
+	copy(record[0:], []byte{192, 168, 0, 1})
+	copy(record[4:], []byte{192, 168, 0, 2})
+	binary.BigEndian.PutUint32(record[16:], 10)  // packets
+	binary.BigEndian.PutUint32(record[20:], 840) // bytes
+	binary.BigEndian.PutUint16(record[32:], 40000)
+	binary.BigEndian.PutUint16(record[34:], 443)
+	record[38] = 6 // tcp
+
+	return append(header, record...)
+}
+
+// sflowV5Packet builds a sFlow v5 datagram without samples
+func sflowV5Packet() []byte {
+	packet := make([]byte, 28)
+	binary.BigEndian.PutUint32(packet[0:], 5) // version
+	binary.BigEndian.PutUint32(packet[4:], 1) // agent address type ipv4
+	copy(packet[8:], []byte{10, 0, 0, 1})
+	return packet
+}
+
+func TestAutoFlowPipe(t *testing.T) {
+	cfgm, err := (&protoproducer.ProducerConfig{}).Compile()
+	require.NoError(t, err)
+	protoProducer, err := protoproducer.CreateProtoProducer(cfgm, protoproducer.CreateSamplingSystem)
+	require.NoError(t, err)
+
+	sink := &consumertest.LogsSink{}
+	pipe := newAutoFlowPipe(&utils.PipeConfig{
+		Producer: newOtelLogsProducer(protoProducer, sink, zap.NewNop()),
+	})
+	defer pipe.Close()
+
+	src := netip.MustParseAddrPort("127.0.0.1:5000")
+	decode := func(payload []byte) error {
+		return pipe.DecodeFlow(&utils.Message{Src: src, Payload: payload, Received: time.Now()})
+	}
+
+	require.NoError(t, decode(netflowV5Packet()))
+	require.Len(t, sink.AllLogs(), 1)
+	assert.Equal(t, 1, sink.AllLogs()[0].LogRecordCount())
+	flowType, _ := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().Get("flow.type")
+	assert.Equal(t, "netflow_v5", flowType.Str())
+
+	require.NoError(t, decode(sflowV5Packet()))
+	require.Len(t, sink.AllLogs(), 2)
+	assert.Equal(t, 0, sink.AllLogs()[1].LogRecordCount())
+
+	var detectionErr *FlowDetectionError
+
+	err = decode([]byte{0, 8, 0, 0, 0, 0})
+	require.ErrorAs(t, err, &detectionErr)
+	assert.Equal(t, uint32(0x80000), detectionErr.Version)
+	assert.Equal(t, src, detectionErr.Src)
+
+	err = decode([]byte{0, 5})
+	require.ErrorAs(t, err, &detectionErr)
+	assert.Equal(t, "packet is too short", detectionErr.Reason)
+
+	assert.Len(t, sink.AllLogs(), 2)
+}
@@ -5,6 +5,7 @@ package netflowreceiver // import "github.com/open-telemetry/opentelemetry-colle
 
 import (
 	"context"
+	"errors"
 
 	"github.com/netsampler/goflow2/v2/producer"
 	"go.opentelemetry.io/collector/consumer"
@@ -19,6 +20,7 @@ type OtelLogsProducerWrapper struct {
 	wrapped     producer.ProducerInterface
 	logConsumer consumer.Logs
 	logger      *zap.Logger
+	enrichers   []flowEnricher
 }
 
 // Produce converts the message into a list log records and sends them to log consumer
@@ -36,7 +38,11 @@ func (o *OtelLogsProducerWrapper) Produce(msg any, args *producer.ProduceArgs) (
 	if err != nil {
 		return flowMessageSet, err
 	}
+	return flowMessageSet, o.consume(flowMessageSet, args)
+}
 
+// consume converts the flows into log records and sends them to the log consumer
+func (o *OtelLogsProducerWrapper) consume(flowMessageSet []producer.ProducerMessage, args *producer.ProduceArgs) error {
 	// Create the otel log structure to hold our messages
 	log := plog.NewLogs()
 	scopeLog := log.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty()
@@ -47,7 +53,7 @@ func (o *OtelLogsProducerWrapper) Produce(msg any, args *producer.ProduceArgs) (
 	// A single netflow packet can contain multiple flow messages
 	for _, msg := range flowMessageSet {
 		logRecord := logRecords.AppendEmpty()
-		parseErr := addMessageAttributes(msg, &logRecord)
+		parseErr := addMessageAttributes(msg, &logRecord, o.enrichers...)
 		if parseErr != nil {
 			continue
 		}
@@ -57,12 +63,7 @@ func (o *OtelLogsProducerWrapper) Produce(msg any, args *producer.ProduceArgs) (
 		o.logger.Info("received a packet with no flow messages from", zap.String("agent", args.SamplerAddress.String()))
 	}
 
-	err = o.logConsumer.ConsumeLogs(context.Background(), log)
-	if err != nil {
-		return flowMessageSet, err
-	}
-
-	return flowMessageSet, nil
+	return o.logConsumer.ConsumeLogs(context.Background(), log)
 }
 
 func (o *OtelLogsProducerWrapper) Close() {
@@ -73,10 +74,105 @@ func (o *OtelLogsProducerWrapper) Commit(flowMessageSet []producer.ProducerMessa
 	o.wrapped.Commit(flowMessageSet)
 }
 
-func newOtelLogsProducer(wrapped producer.ProducerInterface, logConsumer consumer.Logs, logger *zap.Logger) producer.ProducerInterface {
+func newOtelLogsProducer(wrapped producer.ProducerInterface, logConsumer consumer.Logs, logger *zap.Logger, enrichers ...flowEnricher) producer.ProducerInterface {
 	return &OtelLogsProducerWrapper{
 		wrapped:     wrapped,
 		logConsumer: logConsumer,
 		logger:      logger,
+		enrichers:   enrichers,
 	}
 }
+
+// OtelMetricsProducerWrapper is a wrapper around a producer.ProducerInterface that sends the messages to a metrics consumer
+type OtelMetricsProducerWrapper struct {
+	wrapped         producer.ProducerInterface
+	metricsConsumer consumer.Metrics
+	dimensions      []string
+	logger          *zap.Logger
+	enrichers       []flowEnricher
+}
+
+// Produce converts the message into sum metrics and sends them to the metrics consumer
+func (o *OtelMetricsProducerWrapper) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	defer func() {
+		if pErr := recover(); pErr != nil {
+			errMessage, _ := pErr.(string)
+			o.logger.Error("unexpected error processing the message", zap.String("error", errMessage))
+		}
+	}()
+
+	flowMessageSet, err := o.wrapped.Produce(msg, args)
+	if err != nil {
+		return flowMessageSet, err
+	}
+	return flowMessageSet, o.consume(flowMessageSet, args)
+}
+
+// consume converts the flows into sum metrics and sends them to the metrics consumer
+func (o *OtelMetricsProducerWrapper) consume(flowMessageSet []producer.ProducerMessage, _ *producer.ProduceArgs) error {
+	// There are no data points to send for a packet without flows, or with only firewall and NAT events
+	metrics := buildMetrics(flowMessageSet, o.dimensions, o.enrichers...)
+	if metrics.DataPointCount() == 0 {
+		return nil
+	}
+	return o.metricsConsumer.ConsumeMetrics(context.Background(), metrics)
+}
+
+func (o *OtelMetricsProducerWrapper) Close() {
+	o.wrapped.Close()
+}
+
+func (o *OtelMetricsProducerWrapper) Commit(flowMessageSet []producer.ProducerMessage) {
+	o.wrapped.Commit(flowMessageSet)
+}
+
+func newOtelMetricsProducer(wrapped producer.ProducerInterface, metricsConsumer consumer.Metrics, dimensions []string, logger *zap.Logger, enrichers ...flowEnricher) producer.ProducerInterface {
+	return &OtelMetricsProducerWrapper{
+		wrapped:         wrapped,
+		metricsConsumer: metricsConsumer,
+		dimensions:      dimensions,
+		logger:          logger,
+		enrichers:       enrichers,
+	}
+}
+
+// flowConverter converts the flows of a packet into OpenTelemetry data and sends it to a consumer
+type flowConverter interface {
+	consume(flowMessageSet []producer.ProducerMessage, args *producer.ProduceArgs) error
+}
+
+// otelFanoutProducer runs the wrapped producer once and sends the same flows to the logs and metrics converters
+// The converters are siblings, so a consumer that refuses the data does not keep the flows from the other one
+type otelFanoutProducer struct {
+	wrapped    producer.ProducerInterface
+	converters []flowConverter
+	logger     *zap.Logger
+}
+
+func (o *otelFanoutProducer) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	defer func() {
+		if pErr := recover(); pErr != nil {
+			errMessage, _ := pErr.(string)
+			o.logger.Error("unexpected error processing the message", zap.String("error", errMessage))
+		}
+	}()
+
+	flowMessageSet, err := o.wrapped.Produce(msg, args)
+	if err != nil {
+		return flowMessageSet, err
+	}
+
+	errs := make([]error, 0, len(o.converters))
+	for _, c := range o.converters {
+		errs = append(errs, c.consume(flowMessageSet, args))
+	}
+	return flowMessageSet, errors.Join(errs...)
+}
+
+func (o *otelFanoutProducer) Close() {
+	o.wrapped.Close()
+}
+
+func (o *otelFanoutProducer) Commit(flowMessageSet []producer.ProducerMessage) {
+	o.wrapped.Commit(flowMessageSet)
+}
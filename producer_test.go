@@ -106,3 +106,29 @@ func TestProducerPanic(t *testing.T) {
 	assert.Equal(t, "unexpected error processing the message", log.Message)
 	assert.Equal(t, "producer panic!", log.ContextMap()["error"])
 }
+
+func TestProduceMetrics(t *testing.T) {
+	sink := &consumertest.MetricsSink{}
+	wrapper := newOtelMetricsProducer(&staticProducer{
+		messages: []producer.ProducerMessage{newTestFlow("192.168.1.1", 6, 100, 1, 1000, 5000)},
+	}, sink, []string{"flow.sampler_address"}, zap.NewNop())
+
+	messages, err := wrapper.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+	assert.Len(t, messages, 1)
+	require.Len(t, sink.AllMetrics(), 1)
+	assert.Equal(t, 3, sink.AllMetrics()[0].DataPointCount())
+}
+
+// staticProducer always produces the same messages
+type staticProducer struct {
+	messages []producer.ProducerMessage
+}
+
+func (s *staticProducer) Produce(_ any, _ *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	return s.messages, nil
+}
+
+func (s *staticProducer) Close() {}
+
+func (s *staticProducer) Commit(_ []producer.ProducerMessage) {}
@@ -8,8 +8,12 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
+	"slices"
+	"sync"
 
 	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/producer"
 	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
 	"github.com/netsampler/goflow2/v2/utils"
 	"go.opentelemetry.io/collector/component"
@@ -21,81 +25,265 @@ import (
 var _ utils.ReceiverCallback = (*dropHandler)(nil)
 
 type dropHandler struct {
-	logger *zap.Logger
+	logger    *zap.Logger
+	telemetry *listenerTelemetry
 }
 
 func (d dropHandler) Dropped(msg utils.Message) {
+	d.telemetry.packetDropped()
 	d.logger.Warn("Dropped netflow message", zap.Any("msg", msg))
 }
 
-type netflowReceiver struct {
-	config      Config
+// listener is a single UDP listener, owned by the netflow receiver
+type listener struct {
+	config      ListenerConfig
 	logger      *zap.Logger
+	telemetry   *listenerTelemetry
 	udpReceiver *utils.UDPReceiver
-	logConsumer consumer.Logs
-}
-
-func newNetflowLogsReceiver(params receiver.Settings, cfg Config, consumer consumer.Logs) (receiver.Logs, error) {
-	// UDP receiver configuration
-	udpCfg := &utils.UDPReceiverConfig{
-		Sockets:   cfg.Sockets,
-		Workers:   cfg.Workers,
-		QueueSize: cfg.QueueSize,
-		Blocking:  false,
-		ReceiverCallback: &dropHandler{
-			logger: params.Logger,
-		},
+	// aggregator is only set when aggregation is enabled
+	aggregator *flowAggregator
+	// stitcher is only set when stitching is enabled
+	stitcher *flowStitcher
+	// buffer is only set when the packets without a template are buffered
+	buffer *templateBuffer
+
+	// done is closed when the listener is stopped, so the error handler exits
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startableEnricher is an enricher that loads its data when the receiver starts, like databases or files
+type startableEnricher interface {
+	flowEnricher
+	start() error
+	stop()
+}
+
+type netflowReceiver struct {
+	config    Config
+	logger    *zap.Logger
+	telemetry *receiverTelemetry
+	exporters *exporterTracker
+	sequences *sequenceTracker
+	// applications are the NBAR applications the exporters described in their options data records
+	applications *applicationTable
+	// templates is nil when the templates are not persisted
+	templates *templateStore
+	// debug is nil when the debug endpoint is disabled
+	debug     *debugServer
+	listeners []*listener
+	// enrichers are shared by all the listeners
+	enrichers []flowEnricher
+
+	// The consumers are set by the factory, a nil consumer means the receiver is not part of that pipeline
+	logConsumer     consumer.Logs
+	metricsConsumer consumer.Metrics
+}
+
+func newNetflowReceiver(params receiver.Settings, cfg Config) (*netflowReceiver, error) {
+	if cfg.hasDeprecatedListener() {
+		params.Logger.Warn("the scheme, hostname, port, sockets, workers and queue_size settings are deprecated, move them to an entry of listeners")
 	}
-	udpReceiver, err := utils.NewUDPReceiver(udpCfg)
+	telemetry, err := newReceiverTelemetry(params)
 	if err != nil {
 		return nil, err
 	}
-
+	exporters := newExporterTracker(cfg.Exporters, params.Logger)
+	if err := exporters.registerMetrics(telemetry.meter); err != nil {
+		return nil, err
+	}
+	sequences, err := newSequenceTracker(telemetry.meter)
+	if err != nil {
+		return nil, err
+	}
+	// The gaps of the packets the template buffer holds can still be filled when they are replayed
+	if cfg.Templates.Buffer.enabled() {
+		sequences.bufferMaxAge = cfg.Templates.Buffer.MaxAge
+	}
+	// The dictionary elements are decoded like the mappings, the files are only read once
+	if cfg.Mapping, err = cfg.Mapping.withDictionaries(); err != nil {
+		return nil, err
+	}
 	nr := &netflowReceiver{
-		logger:      params.Logger,
-		config:      cfg,
-		logConsumer: consumer,
-		udpReceiver: udpReceiver,
+		logger:       params.Logger,
+		config:       cfg,
+		telemetry:    telemetry,
+		exporters:    exporters,
+		sequences:    sequences,
+		applications: newApplicationTable(),
+	}
+	if cfg.Templates.enabled() {
+		nr.templates = newTemplateStore(cfg.Templates, params.Logger)
+	}
+	if cfg.Debug.enabled() {
+		nr.debug = newDebugServer(cfg.Debug, params.Logger)
+	}
+
+	for _, listenerCfg := range cfg.Listeners {
+		// Every log line of the listener carries its scheme and address
+		// so that we can tell which listener a message came from
+		logger := params.Logger.With(
+			zap.String("scheme", listenerCfg.Scheme),
+			zap.String("listener", listenerCfg.address()),
+		)
+		lt := telemetry.forListener(listenerCfg)
+
+		// UDP receiver configuration
+		udpCfg := &utils.UDPReceiverConfig{
+			Sockets:   listenerCfg.Sockets,
+			Workers:   listenerCfg.Workers,
+			QueueSize: listenerCfg.QueueSize,
+			Blocking:  false,
+			ReceiverCallback: &dropHandler{
+				logger:    logger,
+				telemetry: lt,
+			},
+		}
+		udpReceiver, err := utils.NewUDPReceiver(udpCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		nr.listeners = append(nr.listeners, &listener{
+			config:      listenerCfg,
+			logger:      logger,
+			telemetry:   lt,
+			udpReceiver: udpReceiver,
+		})
 	}
 
 	return nr, nil
 }
 
 func (nr *netflowReceiver) Start(_ context.Context, _ component.Host) error {
+	nr.enrichers = nr.buildEnrichers()
+	for i, e := range nr.enrichers {
+		s, ok := e.(startableEnricher)
+		if !ok {
+			continue
+		}
+		if err := s.start(); err != nil {
+			nr.stopEnrichers(nr.enrichers[:i])
+			return err
+		}
+	}
+
+	if nr.debug != nil {
+		if err := nr.debug.start(); err != nil {
+			nr.stopEnrichers(nr.enrichers)
+			return fmt.Errorf("failed to start debug endpoint %s: %w", nr.config.Debug.Endpoint, err)
+		}
+	}
+
+	// The templates are loaded before the first packets arrive
+	if nr.templates != nil {
+		nr.templates.start()
+	}
+
+	for i, l := range nr.listeners {
+		if err := nr.startListener(l); err != nil {
+			// Do not leave the listeners that already started running
+			nr.stopListeners(nr.listeners[:i])
+			nr.stopTemplates()
+			nr.stopDebug()
+			nr.stopEnrichers(nr.enrichers)
+			return fmt.Errorf("failed to start listener %s: %w", l.config.address(), err)
+		}
+	}
+	// the silent exporters are sent as log records, once the logs pipeline is known
+	nr.exporters.logConsumer = nr.logConsumer
+	nr.exporters.start()
+
+	return nil
+}
+
+func (nr *netflowReceiver) startListener(l *listener) error {
 	// The function that will decode packets
-	decodeFunc, err := nr.buildDecodeFunc()
+	decodeFunc, err := nr.buildDecodeFunc(l)
 	if err != nil {
 		return err
 	}
 
-	nr.logger.Info("Starting UDP listener", zap.String("scheme", nr.config.Scheme), zap.Int("port", nr.config.Port))
-	if err := nr.udpReceiver.Start(nr.config.Hostname, nr.config.Port, decodeFunc); err != nil {
+	l.logger.Info("Starting UDP listener")
+	if err := l.udpReceiver.Start(l.config.Hostname, l.config.Port, decodeFunc); err != nil {
 		return err
 	}
 
-	// This runs until the receiver is stoppped, consuming from an error channel
-	go nr.handleErrors()
+	if l.aggregator != nil {
+		l.aggregator.start()
+	}
+	if l.stitcher != nil {
+		l.stitcher.start()
+	}
+
+	// This runs until the listener is stoppped, consuming from an error channel
+	l.done = make(chan struct{})
+	l.wg.Add(1)
+	go l.handleErrors()
 
 	return nil
 }
 
 func (nr *netflowReceiver) Shutdown(context.Context) error {
-	if nr.udpReceiver == nil {
-		return nil
+	nr.stopListeners(nr.listeners)
+	nr.exporters.stop()
+	nr.stopTemplates()
+	nr.stopDebug()
+	nr.stopEnrichers(nr.enrichers)
+	return nil
+}
+
+func (nr *netflowReceiver) stopDebug() {
+	if nr.debug != nil {
+		nr.debug.stop()
 	}
-	err := nr.udpReceiver.Stop()
-	if err != nil {
-		nr.logger.Warn("Error stopping UDP receiver", zap.Error(err))
+}
+
+// stopTemplates saves the templates learned from the exporters, once no more packets are received
+func (nr *netflowReceiver) stopTemplates() {
+	if nr.templates != nil {
+		nr.templates.stop()
+	}
+}
+
+func (nr *netflowReceiver) stopEnrichers(enrichers []flowEnricher) {
+	for _, e := range enrichers {
+		if s, ok := e.(startableEnricher); ok {
+			s.stop()
+		}
+	}
+}
+
+func (nr *netflowReceiver) stopListeners(listeners []*listener) {
+	for _, l := range listeners {
+		if l.udpReceiver == nil {
+			continue
+		}
+		err := l.udpReceiver.Stop()
+		if err != nil {
+			l.logger.Warn("Error stopping UDP receiver", zap.Error(err))
+		}
+		// The aggregated flows of the open window are sent once no more packets are received
+		if l.aggregator != nil {
+			l.aggregator.stop()
+		}
+		if l.stitcher != nil {
+			l.stitcher.stop()
+		}
+		if l.done != nil {
+			close(l.done)
+			l.wg.Wait()
+			l.done = nil
+		}
 	}
-	return nil
 }
 
 // buildDecodeFunc creates a decode function based on the scheme
 // This is the fuction that will be invoked for every netflow packet received
 // The function depends on the type of schema (netflow, sflow, flow)
-func (nr *netflowReceiver) buildDecodeFunc() (utils.DecoderFunc, error) {
-	// Eventually this can be used to configure mappings
-	cfgProducer := &protoproducer.ProducerConfig{}
+func (nr *netflowReceiver) buildDecodeFunc(l *listener) (utils.DecoderFunc, error) {
+	// the mappings decode additional fields, they were already compiled when the config was validated
+	cfgProducer := nr.config.Mapping.producerConfig()
 	cfgm, err := cfgProducer.Compile() // converts configuration into a format that can be used by a protobuf producer
 	if err != nil {
 		return nil, err
@@ -106,45 +294,216 @@ func (nr *netflowReceiver) buildDecodeFunc() (utils.DecoderFunc, error) {
 		return nil, err
 	}
 
-	// the otel log producer converts those messages into OpenTelemetry logs
+	// the legacy producer converts the NetFlow v1 and v7 packets the goflow2 producer does not know
+	protoProducer = &legacyProducer{wrapped: protoProducer}
+
+	// the application producer learns the names of the application ids from the options data records
+	protoProducer = &applicationProducer{wrapped: protoProducer, table: nr.applications}
+
+	// the sampled header producer keeps the headers sampled by sFlow for the headers field set
+	if slices.Contains(nr.config.Attributes.FieldSets, fieldSetHeaders) {
+		protoProducer = &sampledHeaderProducer{wrapped: protoProducer}
+	}
+
+	// the debug producer records the sampling rates the exporters sent, before the sampling settings change them
+	if nr.debug != nil {
+		protoProducer = &debugProducer{wrapped: protoProducer, debug: nr.debug}
+	}
+
+	// the sampling producer applies the sampling settings to the messages before anything else uses them,
+	// the sequence producer looks for gaps in the sequence numbers of the packets
+	// and the exporter producer records the packets and flows of every exporter before they are aggregated
+	var flowProducer producer.ProducerInterface = &exporterProducer{
+		wrapped: &sequenceProducer{
+			wrapped:  newSamplingProducer(protoProducer, nr.config.Sampling),
+			tracker:  nr.sequences,
+			annotate: nr.config.Exporters.AnnotateSequenceGaps,
+		},
+		tracker: nr.exporters,
+	}
+
+	// the otel producer converts those messages into OpenTelemetry logs and metrics
 	// it is a wrapper around the protobuf producer
-	otelLogsProducer := newOtelLogsProducer(protoProducer, nr.logConsumer, nr.logger)
+	otelProducer := flowProducer
+	enrichers := nr.enrichers
+
+	// when flows are aggregated, the otel producers only receive the aggregated flows once the window closes
+	if nr.config.Aggregation.enabled() {
+		l.aggregator = newFlowAggregator(flowProducer, nr.config.Aggregation, l.logger)
+		l.aggregator.estimate = nr.config.Sampling.Scaling == samplingScalingAdd
+		otelProducer = aggregationOutput{}
+	}
+	// the same goes for the stitched flows, once both directions arrived or the window expired
+	if nr.config.Stitching.enabled() {
+		l.stitcher = newFlowStitcher(flowProducer, nr.config.Stitching, l.logger)
+		otelProducer = aggregationOutput{}
+	}
+
+	// the otel logs converter turns the messages into log records
+	var converters []flowConverter
+	if nr.logConsumer != nil {
+		logConsumer := &obsLogsConsumer{Logs: nr.logConsumer, telemetry: l.telemetry}
+		converters = append(converters, &OtelLogsProducerWrapper{logConsumer: logConsumer, logger: l.logger, enrichers: enrichers})
+	}
+
+	// the otel metrics converter turns the same messages into sum metrics
+	if nr.metricsConsumer != nil {
+		metricsConsumer := &obsMetricsConsumer{Metrics: nr.metricsConsumer, telemetry: l.telemetry}
+		converters = append(converters, &OtelMetricsProducerWrapper{
+			metricsConsumer: metricsConsumer,
+			dimensions:      nr.config.Metrics.Dimensions,
+			logger:          l.logger,
+			enrichers:       enrichers,
+		})
+	}
+	// both convert the flows the producer returned once, so a consumer that fails does not drop the data of the other
+	if len(converters) > 0 {
+		otelProducer = &otelFanoutProducer{wrapped: otelProducer, converters: converters, logger: l.logger}
+	}
 
+	// the flows are counted once they went through every otel producer
+	otelProducer = &telemetryProducer{wrapped: otelProducer, telemetry: l.telemetry}
+
+	// the buffer learns from the template systems when the template of the packets it holds arrives
+	if nr.config.Templates.Buffer.enabled() {
+		l.buffer = newTemplateBuffer(nr.config.Templates.Buffer, l.logger, l.telemetry, nr.sequences)
+	}
 	cfgPipe := &utils.PipeConfig{
-		Producer: otelLogsProducer,
+		Producer:         otelProducer,
+		NetFlowTemplater: nr.templater(l),
+	}
+	if l.aggregator != nil {
+		l.aggregator.output = otelProducer
+		cfgPipe.Producer = l.aggregator
+	}
+	if l.stitcher != nil {
+		l.stitcher.output = otelProducer
+		cfgPipe.Producer = l.stitcher
+	}
+
+	// the counter samples of sFlow are sent after the flows of the packet, they do not go through the aggregation
+	if nr.metricsConsumer != nil && nr.config.Metrics.SFlowCounters {
+		cfgPipe.Producer = &sflowCountersProducer{
+			wrapped:         cfgPipe.Producer,
+			metricsConsumer: &obsMetricsConsumer{Metrics: nr.metricsConsumer, telemetry: l.telemetry},
+			starts:          newSFlowAgentStarts(),
+		}
 	}
 
 	var p utils.FlowPipe
-	switch nr.config.Scheme {
+	switch l.config.Scheme {
 	case "sflow":
 		p = utils.NewSFlowPipe(cfgPipe)
 	case "netflow":
-		p = utils.NewNetFlowPipe(cfgPipe)
+		p = newNetFlowPipe(cfgPipe)
+	case "flow":
+		p = newAutoFlowPipe(cfgPipe)
 	default:
-		return nil, fmt.Errorf("scheme does not exist: %s", nr.config.Scheme)
+		return nil, fmt.Errorf("scheme does not exist: %s", l.config.Scheme)
 	}
-	return p.DecodeFlow, nil
+	decode := p.DecodeFlow
+	if l.buffer != nil {
+		decode = l.buffer.decodeFunc(decode)
+	}
+	return l.telemetry.decodeFunc(decode), nil
+}
+
+// templater creates the template systems of the NetFlow v9 and IPFIX exporters of a listener
+// goflow2 creates one per exporter, identified by the address and port the packets come from
+func (nr *netflowReceiver) templater(l *listener) func(key string) netflow.NetFlowTemplateSystem {
+	return func(key string) netflow.NetFlowTemplateSystem {
+		src, _ := netip.ParseAddrPort(key)
+		exporter := src.Addr().Unmap()
+		system := newBasicTemplateSystem()
+		if nr.debug != nil {
+			nr.debug.register(l.config.address(), src, system)
+		}
+
+		templates := nr.exporters.templateSystem(exporter, system)
+		// The stored templates go through the exporter tracker, so they are counted like the ones the exporter sends
+		if nr.templates != nil {
+			templates = nr.templates.templateSystem(exporter, templates)
+		}
+		if l.buffer != nil {
+			templates = l.buffer.templateSystem(src, templates)
+		}
+		return templates
+	}
+}
+
+// buildEnrichers creates the enrichers that add attributes to every flow, based on the configuration
+func (nr *netflowReceiver) buildEnrichers() []flowEnricher {
+	var enrichers []flowEnricher
+	for _, name := range nr.config.Attributes.FieldSets {
+		enrichers = append(enrichers, fieldSets[name])
+	}
+	if nr.config.Sampling.Scaling == samplingScalingAdd {
+		enrichers = append(enrichers, samplingEnricher{})
+	}
+	// The reverse direction of IPFIX biflows is always decoded, see reverseFields
+	enrichers = append(enrichers, biflowEnricher{})
+	// The NSEL and NEL fields of firewalls and NAT devices are always decoded too, see firewallFields
+	enrichers = append(enrichers, firewallEnricher{})
+	// The application ids are always decoded, their names are learned from the exporters
+	enrichers = append(enrichers, applicationEnricher{table: nr.applications})
+	if fields := nr.config.Mapping.customFields(); len(fields) > 0 {
+		enrichers = append(enrichers, newCustomFieldsEnricher(fields))
+	}
+	if nr.config.GeoIP.enabled() {
+		enrichers = append(enrichers, newGeoIPEnricher(nr.config.GeoIP, nr.logger))
+	}
+	if nr.config.Networks.enabled() {
+		enrichers = append(enrichers, newNetworksEnricher(nr.config.Networks, nr.logger))
+	}
+	if nr.config.SNMP.enabled() {
+		enrichers = append(enrichers, newSNMPEnricher(nr.config.SNMP, nr.logger))
+	}
+	if nr.config.Interfaces.enabled() {
+		enrichers = append(enrichers, newInterfacesEnricher(nr.config.Interfaces, nr.logger))
+	}
+	if nr.config.Exporters.AnnotateSequenceGaps {
+		enrichers = append(enrichers, sequenceEnricher{})
+	}
+	return enrichers
 }
 
 // handleErrors handles errors from the listener
 // We don't want the receiver to stop if there is an error processing a packet
-func (nr *netflowReceiver) handleErrors() {
-	for err := range nr.udpReceiver.Errors() {
+func (l *listener) handleErrors() {
+	defer l.wg.Done()
+	var detectionErr *FlowDetectionError
+	var cErr *consumerError
+	for {
+		var err error
+		select {
+		case <-l.done:
+			return
+		case err = <-l.udpReceiver.Errors():
+		}
+
 		switch {
 		case errors.Is(err, net.ErrClosed):
-			nr.logger.Info("UDP receiver closed, exiting error handler")
+			l.logger.Info("UDP receiver closed, exiting error handler")
 			return
 
+		case errors.As(err, &cErr):
+			l.logger.Warn("the next consumer refused the flows of a message", zap.Error(err))
+			continue
+
+		case errors.As(err, &detectionErr):
+			l.logger.Warn("could not detect the protocol of a flow message, make sure the device sends netflow, ipfix or sflow", zap.Error(err))
+			continue
+
 		case !errors.Is(err, netflow.ErrorTemplateNotFound):
-			nr.logger.Error("received a generic error while processing a flow message via GoFlow2 for the netflow receiver", zap.Error(err))
+			l.logger.Error("received a generic error while processing a flow message via GoFlow2 for the netflow receiver", zap.Error(err))
 			continue
 
 		case errors.Is(err, netflow.ErrorTemplateNotFound):
-			nr.logger.Warn("we could not find a template for a flow message, this error is expected from time to time until the device sends a template", zap.Error(err))
+			l.logger.Warn("we could not find a template for a flow message, this error is expected from time to time until the device sends a template", zap.Error(err))
 			continue
 
 		default:
-			nr.logger.Error("unexpected error processing the message", zap.Error(err))
+			l.logger.Error("unexpected error processing the message", zap.Error(err))
 			continue
 		}
 	}
@@ -5,11 +5,19 @@ package netflowreceiver
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
 	"go.opentelemetry.io/collector/consumer/consumertest"
 	"go.opentelemetry.io/collector/receiver/receivertest"
+
+	"github.com/dynatrace-extensions/netflowreceiver/internal/sharedcomponent"
 )
 
 func TestCreateValidDefaultReceiver(t *testing.T) {
@@ -19,5 +27,110 @@ func TestCreateValidDefaultReceiver(t *testing.T) {
 	receiver, err := factory.CreateLogs(context.Background(), set, cfg, consumertest.NewNop())
 	assert.NoError(t, err, "receiver creation failed")
 	assert.NotNil(t, receiver, "receiver creation failed")
-	assert.NotNil(t, receiver.(*netflowReceiver).udpReceiver)
+	require.Len(t, receiver.(*sharedcomponent.Component[*netflowReceiver]).Unwrap().listeners, 1)
+	assert.NotNil(t, receiver.(*sharedcomponent.Component[*netflowReceiver]).Unwrap().listeners[0].udpReceiver)
+}
+
+func TestLogsAndMetricsShareListeners(t *testing.T) {
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.Listeners[0].Hostname = "127.0.0.1"
+	cfg.Listeners[0].Port = freeUDPPort(t)
+	set := receivertest.NewNopSettings()
+
+	logsReceiver, err := factory.CreateLogs(context.Background(), set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	metricsReceiver, err := factory.CreateMetrics(context.Background(), set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	assert.Same(t, logsReceiver, metricsReceiver)
+
+	nr := logsReceiver.(*sharedcomponent.Component[*netflowReceiver]).Unwrap()
+	assert.NotNil(t, nr.logConsumer)
+	assert.NotNil(t, nr.metricsConsumer)
+
+	host := componenttest.NewNopHost()
+	require.NoError(t, logsReceiver.Start(context.Background(), host))
+	require.NoError(t, metricsReceiver.Start(context.Background(), host))
+	require.NoError(t, logsReceiver.Shutdown(context.Background()))
+	require.NoError(t, metricsReceiver.Shutdown(context.Background()))
+}
+
+func TestMetricsWhenLogsFail(t *testing.T) {
+	nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *createDefaultConfig().(*Config))
+	require.NoError(t, err)
+	nr.logConsumer = consumertest.NewErr(errors.New("logs refused"))
+	sink := &consumertest.MetricsSink{}
+	nr.metricsConsumer = sink
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+
+	// The error of the logs is returned, the metrics of the flows are still sent
+	assert.ErrorContains(t, decode(testMessage(ipfixPacket())), "logs refused")
+	require.Len(t, sink.AllMetrics(), 1)
+	assert.Equal(t, 3, sink.AllMetrics()[0].DataPointCount())
+}
+
+func TestMultipleListeners(t *testing.T) {
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.Listeners = []ListenerConfig{
+		{Scheme: "netflow", Hostname: "127.0.0.1", Port: freeUDPPort(t), Sockets: 1, Workers: 1, QueueSize: 10},
+		{Scheme: "sflow", Hostname: "127.0.0.1", Port: freeUDPPort(t), Sockets: 1, Workers: 1, QueueSize: 10},
+	}
+	require.NoError(t, cfg.Validate())
+
+	set := receivertest.NewNopSettings()
+	receiver, err := factory.CreateLogs(context.Background(), set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+	require.Len(t, receiver.(*sharedcomponent.Component[*netflowReceiver]).Unwrap().listeners, 2)
+
+	require.NoError(t, receiver.Start(context.Background(), componenttest.NewNopHost()))
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+func TestInvalidListenerStopsStartedListeners(t *testing.T) {
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	port, invalidPort := freeUDPPort(t), freeUDPPort(t)
+	cfg.Listeners = []ListenerConfig{
+		{Scheme: "netflow", Hostname: "127.0.0.1", Port: port, Sockets: 1, Workers: 1, QueueSize: 10},
+		{Scheme: "invalid", Hostname: "127.0.0.1", Port: invalidPort, Sockets: 1, Workers: 1, QueueSize: 10},
+	}
+
+	set := receivertest.NewNopSettings()
+	receiver, err := factory.CreateLogs(context.Background(), set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+
+	err = receiver.Start(context.Background(), componenttest.NewNopHost())
+	assert.ErrorContains(t, err, fmt.Sprintf("failed to start listener 127.0.0.1:%d", invalidPort))
+
+	// The first listener was stopped, so its port can be bound again
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port})
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+	require.NoError(t, receiver.Shutdown(context.Background()))
+}
+
+// freeUDPPort returns a UDP port of the loopback address that the system chose as free
+func freeUDPPort(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestMissingGeoIPDatabaseFailsStart(t *testing.T) {
+	factory := NewFactory()
+	cfg := factory.CreateDefaultConfig().(*Config)
+	cfg.Listeners[0].Port = freeUDPPort(t)
+	cfg.GeoIP.CityDatabase = filepath.Join(t.TempDir(), "missing.mmdb")
+
+	set := receivertest.NewNopSettings()
+	receiver, err := factory.CreateLogs(context.Background(), set, cfg, consumertest.NewNop())
+	require.NoError(t, err)
+
+	err = receiver.Start(context.Background(), componenttest.NewNopHost())
+	assert.ErrorContains(t, err, "failed to load the geoip city database")
+	require.NoError(t, receiver.Shutdown(context.Background()))
 }
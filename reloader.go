@@ -0,0 +1,113 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// fileReloader loads a file when it starts and loads it again every time the file changes
+// The loaded value can be read concurrently while it is being replaced
+type fileReloader[T any] struct {
+	path     string
+	interval time.Duration
+	load     func(path string) (T, error)
+	logger   *zap.Logger
+
+	current atomic.Pointer[T]
+	// modTime and size identify the version of the file that was loaded
+	modTime time.Time
+	size    int64
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newFileReloader creates a reloader that checks the file for changes every interval
+// The file is never reloaded when the interval is zero
+func newFileReloader[T any](path string, interval time.Duration, logger *zap.Logger, load func(path string) (T, error)) *fileReloader[T] {
+	return &fileReloader[T]{
+		path:     path,
+		interval: interval,
+		load:     load,
+		logger:   logger.With(zap.String("path", path)),
+	}
+}
+
+// start loads the file, it fails if the file cannot be loaded
+func (r *fileReloader[T]) start() error {
+	if _, err := r.reload(); err != nil {
+		return err
+	}
+	if r.interval <= 0 {
+		return nil
+	}
+
+	r.done = make(chan struct{})
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.done:
+				return
+			case <-ticker.C:
+				// A file that cannot be loaded is usually being written, the last version is kept until it is valid again
+				reloaded, err := r.reload()
+				if err != nil {
+					r.logger.Warn("Failed to reload file, the previous version is still used", zap.Error(err))
+				} else if reloaded {
+					r.logger.Info("Reloaded file")
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// stop stops checking the file for changes
+func (r *fileReloader[T]) stop() {
+	if r.done == nil {
+		return
+	}
+	close(r.done)
+	r.wg.Wait()
+	r.done = nil
+}
+
+// get returns the value of the last version of the file that was loaded
+func (r *fileReloader[T]) get() T {
+	if v := r.current.Load(); v != nil {
+		return *v
+	}
+	var zero T
+	return zero
+}
+
+// reload loads the file if it changed since the last time it was loaded
+func (r *fileReloader[T]) reload() (bool, error) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false, err
+	}
+	if r.current.Load() != nil && info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return false, nil
+	}
+
+	v, err := r.load(r.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to load %s: %w", r.path, err)
+	}
+	r.current.Store(&v)
+	r.modTime, r.size = info.ModTime(), info.Size()
+	return true, nil
+}
@@ -0,0 +1,65 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// loadTestFile loads the content of a file, empty files are invalid
+func loadTestFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if len(b) == 0 {
+		return "", errors.New("empty file")
+	}
+	return string(b), nil
+}
+
+func TestFileReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("first"), 0o600))
+
+	r := newFileReloader(path, 10*time.Millisecond, zap.NewNop(), loadTestFile)
+	require.NoError(t, r.start())
+	defer r.stop()
+	assert.Equal(t, "first", r.get())
+
+	require.NoError(t, os.WriteFile(path, []byte("second"), 0o600))
+	assert.Eventually(t, func() bool { return r.get() == "second" }, time.Second, 10*time.Millisecond)
+
+	// An invalid version of the file does not replace the last valid one
+	require.NoError(t, os.WriteFile(path, nil, 0o600))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "second", r.get())
+}
+
+func TestFileReloaderUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data")
+	require.NoError(t, os.WriteFile(path, []byte("first"), 0o600))
+
+	r := newFileReloader(path, 0, zap.NewNop(), loadTestFile)
+	require.NoError(t, r.start())
+	defer r.stop()
+
+	reloaded, err := r.reload()
+	require.NoError(t, err)
+	assert.False(t, reloaded)
+}
+
+func TestFileReloaderMissingFile(t *testing.T) {
+	r := newFileReloader(filepath.Join(t.TempDir(), "missing"), time.Minute, zap.NewNop(), loadTestFile)
+	require.Error(t, r.start())
+	r.stop()
+}
@@ -0,0 +1,83 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+)
+
+const (
+	// samplingScalingNone leaves bytes and packets as they were sampled
+	samplingScalingNone = "none"
+	// samplingScalingAdd adds the estimated totals as separate attributes
+	samplingScalingAdd = "add"
+	// samplingScalingReplace replaces bytes and packets with the estimated totals
+	samplingScalingReplace = "replace"
+)
+
+// samplingProducer is a wrapper around a producer.ProducerInterface that applies the sampling settings to the flows
+// It runs before the flows are aggregated so the aggregated totals are also scaled
+type samplingProducer struct {
+	wrapped producer.ProducerInterface
+	cfg     SamplingConfig
+}
+
+func (s *samplingProducer) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	flowMessageSet, err := s.wrapped.Produce(msg, args)
+	if err != nil {
+		return flowMessageSet, err
+	}
+
+	for _, m := range flowMessageSet {
+		pm, ok := m.(*protoproducer.ProtoProducerMessage)
+		if !ok {
+			continue
+		}
+
+		// NetFlow v9 and IPFIX devices send the rate in options templates, goflow2 keeps the last one
+		// seen per device and observation domain, so it is zero until the first options data arrives
+		// Devices that never send the sampling rate can be configured with a fixed one
+		if pm.SamplingRate == 0 {
+			pm.SamplingRate = s.cfg.DefaultRate
+		}
+
+		if s.cfg.Scaling == samplingScalingReplace {
+			if pm.SamplingRate > 0 {
+				pm.Bytes *= pm.SamplingRate
+				pm.Packets *= pm.SamplingRate
+			}
+		}
+	}
+
+	return flowMessageSet, nil
+}
+
+func (s *samplingProducer) Close() {
+	s.wrapped.Close()
+}
+
+func (s *samplingProducer) Commit(flowMessageSet []producer.ProducerMessage) {
+	s.wrapped.Commit(flowMessageSet)
+}
+
+func newSamplingProducer(wrapped producer.ProducerInterface, cfg SamplingConfig) producer.ProducerInterface {
+	return &samplingProducer{
+		wrapped: wrapped,
+		cfg:     cfg,
+	}
+}
+
+// samplingEnricher adds the estimated bytes and packets to the flow attributes
+// Flows without a known sampling rate do not get the estimated attributes
+type samplingEnricher struct{}
+
+func (samplingEnricher) enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	if pm.SamplingRate == 0 {
+		return
+	}
+	attrs.PutInt("flow.io.estimated_bytes", int64(pm.Bytes*pm.SamplingRate))
+	attrs.PutInt("flow.io.estimated_packets", int64(pm.Packets*pm.SamplingRate))
+}
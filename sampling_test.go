@@ -0,0 +1,170 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	semconv "go.opentelemetry.io/collector/semconv/v1.27.0"
+)
+
+func TestSamplingProducerReplace(t *testing.T) {
+	sampled := newTestFlow("192.168.1.1", 6, 100, 2, 1000, 5000)
+	sampled.SamplingRate = 10
+	unknown := newTestFlow("192.168.1.2", 6, 100, 2, 1000, 5000)
+
+	wrapper := newSamplingProducer(&staticProducer{
+		messages: []producer.ProducerMessage{sampled, unknown},
+	}, SamplingConfig{Scaling: samplingScalingReplace})
+
+	_, err := wrapper.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1000), sampled.Bytes)
+	assert.Equal(t, uint64(20), sampled.Packets)
+	// Flows without a sampling rate are left as they are
+	assert.Equal(t, uint64(100), unknown.Bytes)
+	assert.Equal(t, uint64(2), unknown.Packets)
+}
+
+func TestSamplingProducerDefaultRate(t *testing.T) {
+	sampled := newTestFlow("192.168.1.1", 6, 100, 2, 1000, 5000)
+	sampled.SamplingRate = 10
+	unknown := newTestFlow("192.168.1.2", 6, 100, 2, 1000, 5000)
+
+	wrapper := newSamplingProducer(&staticProducer{
+		messages: []producer.ProducerMessage{sampled, unknown},
+	}, SamplingConfig{Scaling: samplingScalingNone, DefaultRate: 100})
+
+	_, err := wrapper.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(10), sampled.SamplingRate)
+	assert.Equal(t, uint64(100), unknown.SamplingRate)
+	assert.Equal(t, uint64(100), unknown.Bytes)
+}
+
+func TestSamplingEnricher(t *testing.T) {
+	pm := newTestFlow("192.168.1.1", 6, 100, 2, 1000, 5000)
+
+	attrs := pcommon.NewMap()
+	samplingEnricher{}.enrich(pm, attrs)
+	assert.Equal(t, 0, attrs.Len(), "flows without a sampling rate have no estimates")
+
+	pm.SamplingRate = 512
+	samplingEnricher{}.enrich(pm, attrs)
+	assertIntAttribute(t, attrs, "flow.io.estimated_bytes", 51200)
+	assertIntAttribute(t, attrs, "flow.io.estimated_packets", 1024)
+}
+
+func TestAggregationEstimates(t *testing.T) {
+	first := newTestFlow("192.168.1.1", 6, 100, 1, 1000, 5000)
+	first.SamplingRate = 10
+	second := newTestFlow("192.168.1.1", 6, 50, 1, 1000, 5000)
+	second.SamplingRate = 100
+	unknown := newTestFlow("192.168.1.1", 6, 10, 1, 1000, 5000)
+
+	aggregator, sink := newTestAggregator(t, AggregationConfig{
+		Key:     []string{semconv.AttributeSourceAddress},
+		MaxKeys: 10,
+	}, []producer.ProducerMessage{first, second, unknown})
+	aggregator.estimate = true
+
+	_, err := aggregator.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+	aggregator.flush()
+
+	require.Len(t, sink.AllLogs(), 1)
+	attrs := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes()
+	assertIntAttribute(t, attrs, "flow.io.bytes", 160)
+	assertIntAttribute(t, attrs, "flow.io.estimated_bytes", 6010)
+	assertIntAttribute(t, attrs, "flow.io.estimated_packets", 111)
+}
+
+func TestSamplingRateFromOptionsData(t *testing.T) {
+	cfgm, err := (&protoproducer.ProducerConfig{}).Compile()
+	require.NoError(t, err)
+	protoProducer, err := protoproducer.CreateProtoProducer(cfgm, protoproducer.CreateSamplingSystem)
+	require.NoError(t, err)
+	wrapper := newSamplingProducer(protoProducer, SamplingConfig{Scaling: samplingScalingReplace})
+
+	args := &producer.ProduceArgs{
+		Src:            netip.MustParseAddrPort("192.168.1.100:2055"),
+		SamplerAddress: netip.MustParseAddr("192.168.1.100"),
+	}
+	dataFlowSet := netflow.DataFlowSet{
+		FlowSetHeader: netflow.FlowSetHeader{Id: 260},
+		Records: []netflow.DataRecord{
+			{
+				Values: []netflow.DataField{
+					{Type: netflow.NFV9_FIELD_IN_BYTES, Value: []byte{0, 0, 0, 100}},
+					{Type: netflow.NFV9_FIELD_IN_PKTS, Value: []byte{0, 0, 0, 2}},
+				},
+			},
+		},
+	}
+
+	// The device sends the sampling interval in an options data record first
+	_, err = wrapper.Produce(&netflow.NFv9Packet{
+		Version:  9,
+		SourceId: 1,
+		FlowSets: []any{
+			netflow.OptionsDataFlowSet{
+				FlowSetHeader: netflow.FlowSetHeader{Id: 261},
+				Records: []netflow.OptionsDataRecord{
+					{
+						OptionsValues: []netflow.DataField{
+							{Type: netflow.NFV9_FIELD_SAMPLING_INTERVAL, Value: []byte{0, 0, 0, 64}},
+						},
+					},
+				},
+			},
+		},
+	}, args)
+	require.NoError(t, err)
+
+	// Later data records from the same device and source id use that rate
+	messages, err := wrapper.Produce(&netflow.NFv9Packet{
+		Version:  9,
+		SourceId: 1,
+		FlowSets: []any{dataFlowSet},
+	}, args)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	pm := messages[0].(*protoproducer.ProtoProducerMessage)
+	assert.Equal(t, uint64(64), pm.SamplingRate)
+	assert.Equal(t, uint64(6400), pm.Bytes)
+	assert.Equal(t, uint64(128), pm.Packets)
+
+	// A different source id of the same device has no rate yet
+	messages, err = wrapper.Produce(&netflow.NFv9Packet{
+		Version:  9,
+		SourceId: 2,
+		FlowSets: []any{dataFlowSet},
+	}, args)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	assert.Equal(t, uint64(0), messages[0].(*protoproducer.ProtoProducerMessage).SamplingRate)
+	assert.Equal(t, uint64(100), messages[0].(*protoproducer.ProtoProducerMessage).Bytes)
+}
+
+func TestAddMessageAttributesWithEstimates(t *testing.T) {
+	pm := newTestFlow("192.168.1.1", 6, 100, 2, 1000, 5000)
+	pm.SamplingRate = 4
+
+	record := plog.NewLogRecord()
+	require.NoError(t, addMessageAttributes(pm, &record, samplingEnricher{}))
+	assertIntAttribute(t, record.Attributes(), "flow.io.bytes", 100)
+	assertIntAttribute(t, record.Attributes(), "flow.io.estimated_bytes", 400)
+	assertIntAttribute(t, record.Attributes(), "flow.io.estimated_packets", 8)
+}
@@ -0,0 +1,345 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/decoders/netflowlegacy"
+	"github.com/netsampler/goflow2/v2/decoders/sflow"
+	flowpb "github.com/netsampler/goflow2/v2/pb"
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// sequenceLostIndex is the protobuf index where the number of flows or packets lost before a flow is stored
+	// It is set by the sequence producer, not by goflow2, and is below the indexes of the custom fields
+	sequenceLostIndex = 998
+
+	// sequenceResetThreshold is the largest jump of a sequence number that is counted as lost
+	// Larger jumps, forwards or backwards, mean the exporter restarted and the sequence starts again
+	sequenceResetThreshold = 1 << 20
+
+	// sequenceReorderWindow is the number of packets of a sequence that can arrive before a late packet
+	// A gap that is not filled once that many packets arrived after it is counted as lost
+	sequenceReorderWindow = 8
+
+	// sequenceExpiry is how long a sequence is kept after its last packet, the exporter starts a new one when it comes back
+	sequenceExpiry = 10 * time.Minute
+)
+
+// sequenceKey identifies a sequence of an exporter
+// NetFlow v9 has a sequence per source id, IPFIX per observation domain and sFlow per sub agent
+type sequenceKey struct {
+	exporter netip.Addr
+	flowType flowpb.FlowMessage_FlowType
+	domain   uint32
+}
+
+// sequenceHeader is the sequence number of a packet and how much the next packet's sequence number should be ahead
+// NetFlow v5, v7 and IPFIX count flows, NetFlow v9 and sFlow count packets
+type sequenceHeader struct {
+	key       sequenceKey
+	number    uint32
+	increment uint32
+}
+
+// readSequenceHeader reads the sequence of a packet decoded by goflow2
+func readSequenceHeader(msg any, exporter netip.Addr) (sequenceHeader, bool) {
+	exporter = exporter.Unmap()
+	switch packet := msg.(type) {
+	case *netflowlegacy.PacketNetFlowV5:
+		return sequenceHeader{
+			key:       sequenceKey{exporter: exporter, flowType: flowpb.FlowMessage_NETFLOW_V5, domain: uint32(packet.EngineType)<<8 | uint32(packet.EngineId)},
+			number:    packet.FlowSequence,
+			increment: uint32(packet.Count),
+		}, true
+	case *legacyNetFlowPacket:
+		// NetFlow v1 has no sequence number, v7 counts flows like v5
+		if packet.Version != 7 {
+			return sequenceHeader{}, false
+		}
+		return sequenceHeader{
+			key:       sequenceKey{exporter: exporter, flowType: flowTypeNetFlowV7},
+			number:    packet.FlowSequence,
+			increment: uint32(packet.Count),
+		}, true
+	case *netflow.NFv9Packet:
+		return sequenceHeader{
+			key:       sequenceKey{exporter: exporter, flowType: flowpb.FlowMessage_NETFLOW_V9, domain: packet.SourceId},
+			number:    packet.SequenceNumber,
+			increment: 1,
+		}, true
+	case *netflow.IPFIXPacket:
+		// The sequence counts every data record, including the options data records
+		var records uint32
+		for _, flowSet := range packet.FlowSets {
+			switch fs := flowSet.(type) {
+			case netflow.DataFlowSet:
+				records += uint32(len(fs.Records))
+			case netflow.OptionsDataFlowSet:
+				records += uint32(len(fs.Records))
+			}
+		}
+		return sequenceHeader{
+			key:       sequenceKey{exporter: exporter, flowType: flowpb.FlowMessage_IPFIX, domain: packet.ObservationDomainId},
+			number:    packet.SequenceNumber,
+			increment: records,
+		}, true
+	case *sflow.Packet:
+		return sequenceHeader{
+			key:       sequenceKey{exporter: exporter, flowType: flowpb.FlowMessage_SFLOW_5, domain: packet.SubAgentId},
+			number:    packet.SequenceNumber,
+			increment: 1,
+		}, true
+	default:
+		return sequenceHeader{}, false
+	}
+}
+
+// sequenceState is the state of a sequence of an exporter
+type sequenceState struct {
+	// next is the sequence number expected in the next packet
+	next uint32
+	// gaps are the gaps that are not counted yet, a packet that arrives late can still fill them
+	gaps []sequenceGap
+	// seen is when the last packet of the sequence arrived
+	seen time.Time
+	// buffered is when the template buffer last held a packet of the sequence, it can replay it until the buffer max age
+	buffered time.Time
+}
+
+// sequenceGap is a range of missing sequence numbers, from start to end excluded
+type sequenceGap struct {
+	start, end uint32
+	// missing is the number of packets or flows of the range that did not arrive yet
+	missing uint32
+	// age is the number of packets of the sequence that arrived after the gap
+	age int
+	// opened is when the packet after the gap arrived
+	opened time.Time
+}
+
+// sequenceTracker finds the gaps in the sequence numbers of the exporters to estimate how many packets or flows were lost
+// The workers of a listener and the template buffer deliver the packets of an exporter slightly out of order,
+// so a gap is only counted once sequenceReorderWindow more packets arrived without filling it
+// The template buffer replays its packets much later, so while it holds packets of a sequence its gaps stay open for the buffer max age
+type sequenceTracker struct {
+	lostPackets metric.Int64Counter
+	lostFlows   metric.Int64Counter
+	// bufferMaxAge is the max age of the template buffer, zero when the packets are not buffered
+	bufferMaxAge time.Duration
+	// now is replaced in the tests
+	now func() time.Time
+
+	mu        sync.Mutex
+	sequences map[sequenceKey]*sequenceState
+	// expired is when the sequences of the silent exporters were last removed
+	expired time.Time
+}
+
+func newSequenceTracker(meter metric.Meter) (*sequenceTracker, error) {
+	lostPackets, err := meter.Int64Counter("otelcol_netflow_receiver_sequence_lost_packets", metric.WithUnit("{packet}"),
+		metric.WithDescription("Number of NetFlow v9 and sFlow packets lost, estimated from the gaps in their sequence numbers"))
+	if err != nil {
+		return nil, err
+	}
+	lostFlows, err := meter.Int64Counter("otelcol_netflow_receiver_sequence_lost_flows", metric.WithUnit("{flow}"),
+		metric.WithDescription("Number of NetFlow v5, v7 and IPFIX flows lost, estimated from the gaps in their sequence numbers"))
+	if err != nil {
+		return nil, err
+	}
+	return &sequenceTracker{
+		lostPackets: lostPackets,
+		lostFlows:   lostFlows,
+		now:         time.Now,
+		sequences:   make(map[sequenceKey]*sequenceState),
+		expired:     time.Now(),
+	}, nil
+}
+
+// observe checks the sequence number of a packet and returns how many packets or flows were lost in the gaps
+// that are counted with this packet, because they were not filled within the reorder window
+func (t *sequenceTracker) observe(header sequenceHeader) uint32 {
+	now := t.now()
+	t.mu.Lock()
+	expiredLost := t.expire(now)
+	lost := uint32(0)
+	state, ok := t.sequences[header.key]
+	// The difference wraps around with the sequence numbers, so it is the distance even when they overflow
+	var diff int32
+	if ok {
+		diff = int32(header.number - state.next)
+	}
+	switch {
+	case !ok:
+		// The first packet of a sequence
+		state = &sequenceState{next: header.number + header.increment}
+		t.sequences[header.key] = state
+	case diff >= sequenceResetThreshold || diff <= -sequenceResetThreshold:
+		// The exporter restarted, the gaps before the restart can no longer be filled
+		for _, gap := range state.gaps {
+			lost += gap.missing
+		}
+		state.gaps = nil
+		state.next = header.number + header.increment
+	case diff < 0:
+		// A packet that arrived late fills a part of its gap, the sequence does not go back
+		state.fill(header.number, header.increment)
+		lost = state.age(now, t.bufferMaxAge)
+	default:
+		lost = state.age(now, t.bufferMaxAge)
+		if diff > 0 {
+			state.gaps = append(state.gaps, sequenceGap{start: state.next, end: header.number, missing: uint32(diff), opened: now})
+		}
+		state.next = header.number + header.increment
+	}
+	state.seen = now
+	t.mu.Unlock()
+
+	for key, expired := range expiredLost {
+		t.count(key, expired)
+	}
+	t.count(header.key, lost)
+	return lost
+}
+
+// count adds the packets or flows lost in a sequence to the counters
+func (t *sequenceTracker) count(key sequenceKey, lost uint32) {
+	if lost == 0 {
+		return
+	}
+	counter := t.lostFlows
+	if key.flowType == flowpb.FlowMessage_NETFLOW_V9 || key.flowType == flowpb.FlowMessage_SFLOW_5 {
+		counter = t.lostPackets
+	}
+	counter.Add(context.Background(), int64(lost), metric.WithAttributes(
+		attribute.String("sampler.address", key.exporter.String()),
+		attribute.String("flow.type", getFlowTypeName(int32(key.flowType))),
+	))
+}
+
+// expire removes the sequences of the exporters that went silent, at most once per sequenceExpiry
+// It returns the packets or flows lost in their open gaps, it must be called with the lock held
+func (t *sequenceTracker) expire(now time.Time) map[sequenceKey]uint32 {
+	if now.Sub(t.expired) < sequenceExpiry {
+		return nil
+	}
+	t.expired = now
+	var lost map[sequenceKey]uint32
+	for key, state := range t.sequences {
+		if now.Sub(state.seen) < sequenceExpiry {
+			continue
+		}
+		delete(t.sequences, key)
+		for _, gap := range state.gaps {
+			if lost == nil {
+				lost = make(map[sequenceKey]uint32)
+			}
+			lost[key] += gap.missing
+		}
+	}
+	return lost
+}
+
+// hold keeps the gaps of a sequence open while the template buffer holds one of its packets
+func (t *sequenceTracker) hold(key sequenceKey) {
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state, ok := t.sequences[key]; ok {
+		state.buffered = now
+	}
+}
+
+// fill removes the packets or flows of a late packet from the gap they belong to
+func (s *sequenceState) fill(number, increment uint32) {
+	for i := range s.gaps {
+		gap := &s.gaps[i]
+		if number-gap.start < gap.end-gap.start {
+			gap.missing -= min(increment, gap.missing)
+			if gap.missing == 0 {
+				s.gaps = append(s.gaps[:i], s.gaps[i+1:]...)
+			}
+			return
+		}
+	}
+}
+
+// age ages the gaps by one packet and returns the packets or flows lost in the gaps that left the reorder window
+// While the template buffer holds packets of the sequence, the gaps younger than its max age can still be filled by a replay
+func (s *sequenceState) age(now time.Time, bufferMaxAge time.Duration) uint32 {
+	lost := uint32(0)
+	gaps := s.gaps[:0]
+	held := bufferMaxAge > 0 && now.Sub(s.buffered) <= bufferMaxAge
+	for _, gap := range s.gaps {
+		gap.age++
+		if gap.age >= sequenceReorderWindow && (!held || now.Sub(gap.opened) > bufferMaxAge) {
+			lost += gap.missing
+			continue
+		}
+		gaps = append(gaps, gap)
+	}
+	s.gaps = gaps
+	return lost
+}
+
+// sequenceProducer checks the sequence numbers of the packets before their flows are produced
+type sequenceProducer struct {
+	wrapped producer.ProducerInterface
+	tracker *sequenceTracker
+	// annotate stores the number of packets or flows lost in the first flow of the packet that counted a gap
+	annotate bool
+}
+
+func (p *sequenceProducer) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	flowMessageSet, err := p.wrapped.Produce(msg, args)
+	if err != nil {
+		return flowMessageSet, err
+	}
+
+	header, ok := readSequenceHeader(msg, args.SamplerAddress)
+	if !ok {
+		return flowMessageSet, nil
+	}
+	lost := p.tracker.observe(header)
+	if lost == 0 || !p.annotate || len(flowMessageSet) == 0 {
+		return flowMessageSet, nil
+	}
+	if pm, ok := flowMessageSet[0].(*protoproducer.ProtoProducerMessage); ok {
+		unknown := pm.ProtoReflect().GetUnknown()
+		unknown = protowire.AppendTag(unknown, sequenceLostIndex, protowire.VarintType)
+		unknown = protowire.AppendVarint(unknown, uint64(lost))
+		pm.ProtoReflect().SetUnknown(unknown)
+	}
+	return flowMessageSet, nil
+}
+
+func (p *sequenceProducer) Close() {
+	p.wrapped.Close()
+}
+
+func (p *sequenceProducer) Commit(flowMessageSet []producer.ProducerMessage) {
+	p.wrapped.Commit(flowMessageSet)
+}
+
+// sequenceEnricher adds the number of packets or flows lost to the first flow of the packet that counted a gap
+type sequenceEnricher struct{}
+
+func (sequenceEnricher) enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	rangeUnknownFields(pm, func(num protowire.Number, value uint64, _ []byte) {
+		if num == sequenceLostIndex {
+			attrs.PutInt("flow.sequence.lost", int64(value))
+		}
+	})
+}
@@ -0,0 +1,249 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/decoders/netflowlegacy"
+	"github.com/netsampler/goflow2/v2/decoders/sflow"
+	flowpb "github.com/netsampler/goflow2/v2/pb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// netflowV5PacketWithSequence builds the NetFlow v5 packet of netflowV5Packet with another flow sequence
+func netflowV5PacketWithSequence(sequence uint32) []byte {
+	packet := netflowV5Packet()
+	binary.BigEndian.PutUint32(packet[16:], sequence)
+	return packet
+}
+
+func TestReadSequenceHeader(t *testing.T) {
+	exporter := netip.MustParseAddr("::ffff:192.0.2.1")
+	ipfix := &netflow.IPFIXPacket{
+		SequenceNumber:      7,
+		ObservationDomainId: 3,
+		FlowSets: []any{
+			netflow.TemplateFlowSet{},
+			netflow.DataFlowSet{Records: make([]netflow.DataRecord, 2)},
+			netflow.OptionsDataFlowSet{Records: make([]netflow.OptionsDataRecord, 1)},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		msg       any
+		domain    uint32
+		number    uint32
+		increment uint32
+	}{
+		{"netflow_v5", &netflowlegacy.PacketNetFlowV5{FlowSequence: 10, EngineType: 1, EngineId: 2, Count: 30}, 1<<8 | 2, 10, 30},
+		{"netflow_v9", &netflow.NFv9Packet{SequenceNumber: 5, SourceId: 4}, 4, 5, 1},
+		{"ipfix", ipfix, 3, 7, 3},
+		{"sflow_5", &sflow.Packet{SequenceNumber: 9, SubAgentId: 6}, 6, 9, 1},
+		{"netflow_v7", &legacyNetFlowPacket{netflowlegacy.PacketNetFlowV5{Version: 7, FlowSequence: 12, Count: 20}}, 0, 12, 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header, ok := readSequenceHeader(tt.msg, exporter)
+			require.True(t, ok)
+			assert.Equal(t, netip.MustParseAddr("192.0.2.1"), header.key.exporter)
+			assert.Equal(t, tt.name, getFlowTypeName(int32(header.key.flowType)))
+			assert.Equal(t, tt.domain, header.key.domain)
+			assert.Equal(t, tt.number, header.number)
+			assert.Equal(t, tt.increment, header.increment)
+		})
+	}
+
+	_, ok := readSequenceHeader("not a packet", exporter)
+	assert.False(t, ok)
+	_, ok = readSequenceHeader(&legacyNetFlowPacket{netflowlegacy.PacketNetFlowV5{Version: 1}}, exporter)
+	assert.False(t, ok, "netflow v1 has no sequence")
+}
+
+func TestSequenceTracker(t *testing.T) {
+	tracker, err := newSequenceTracker(noop.Meter{})
+	require.NoError(t, err)
+	key := sequenceKey{exporter: netip.MustParseAddr("192.0.2.1")}
+	var next uint32
+	observe := func(number, increment uint32) uint32 {
+		if int32(number+increment-next) > 0 {
+			next = number + increment
+		}
+		return tracker.observe(sequenceHeader{key: key, number: number, increment: increment})
+	}
+	// advance observes packets of one flow in order and returns the flows counted as lost
+	advance := func(packets int) uint32 {
+		var lost uint32
+		for i := 0; i < packets; i++ {
+			lost += observe(next, 1)
+		}
+		return lost
+	}
+
+	// The first packet starts the sequence
+	assert.Equal(t, uint32(0), observe(100, 10))
+	assert.Equal(t, uint32(0), observe(110, 10))
+	// The flows 120 to 124 never arrived, they are counted once the reorder window passed
+	assert.Equal(t, uint32(0), observe(125, 10))
+	assert.Equal(t, uint32(0), advance(sequenceReorderWindow-1))
+	assert.Equal(t, uint32(5), advance(1))
+	// A packet that arrives after the window is not counted twice and does not move the sequence back
+	assert.Equal(t, uint32(0), observe(120, 5))
+	assert.Equal(t, uint32(0), advance(2*sequenceReorderWindow))
+
+	// A late packet fills a part of its gap
+	start := next
+	assert.Equal(t, uint32(0), observe(start+10, 1))
+	assert.Equal(t, uint32(0), observe(start+2, 3))
+	assert.Equal(t, uint32(7), advance(sequenceReorderWindow))
+
+	// The sequence wraps around
+	key.domain, next = 2, 1<<32-2
+	assert.Equal(t, uint32(0), observe(1<<32-2, 1))
+	assert.Equal(t, uint32(0), observe(2, 1))
+	assert.Equal(t, uint32(0), observe(0, 1))
+	assert.Equal(t, uint32(2), advance(sequenceReorderWindow))
+
+	// The exporter restarted, the gaps before the restart are counted right away
+	assert.Equal(t, uint32(0), observe(next+2, 1))
+	next = 1 << 30
+	assert.Equal(t, uint32(2), observe(1<<30, 1))
+	assert.Equal(t, uint32(0), advance(2*sequenceReorderWindow))
+
+	// Every domain has its own sequence
+	other := key
+	other.domain = 3
+	assert.Equal(t, uint32(0), tracker.observe(sequenceHeader{key: other, number: 1000, increment: 1}))
+}
+
+func TestSequenceTrackerReordered(t *testing.T) {
+	tracker, err := newSequenceTracker(noop.Meter{})
+	require.NoError(t, err)
+	key := sequenceKey{exporter: netip.MustParseAddr("192.0.2.1"), flowType: flowpb.FlowMessage_NETFLOW_V9}
+
+	// The workers swap every other pair of packets, N+1 arrives before N
+	var lost uint32
+	lost += tracker.observe(sequenceHeader{key: key, number: 0, increment: 1})
+	for n := uint32(1); n < 100; n += 2 {
+		lost += tracker.observe(sequenceHeader{key: key, number: n + 1, increment: 1})
+		lost += tracker.observe(sequenceHeader{key: key, number: n, increment: 1})
+	}
+	assert.Equal(t, uint32(0), lost)
+}
+
+func TestSequenceGaps(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	params := receivertest.NewNopSettings()
+	params.TelemetrySettings.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	params.TelemetrySettings.MetricsLevel = configtelemetry.LevelDetailed
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Exporters.AnnotateSequenceGaps = true
+	nr, err := newNetflowReceiver(params, *cfg)
+	require.NoError(t, err)
+	sink := &consumertest.LogsSink{}
+	nr.logConsumer = sink
+	nr.enrichers = nr.buildEnrichers()
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+
+	// Every packet has a flow, the flows 44 to 49 are missing
+	require.NoError(t, decode(testMessage(netflowV5PacketWithSequence(42))))
+	require.NoError(t, decode(testMessage(netflowV5PacketWithSequence(43))))
+	require.NoError(t, decode(testMessage(netflowV5PacketWithSequence(50))))
+	// The gap is counted once the reorder window passed
+	for i := 0; i < sequenceReorderWindow; i++ {
+		require.NoError(t, decode(testMessage(netflowV5PacketWithSequence(uint32(51+i)))))
+	}
+
+	assert.Equal(t, int64(6), counterValue(t, reader, "otelcol_netflow_receiver_sequence_lost_flows",
+		attribute.String("sampler.address", "127.0.0.1"), attribute.String("flow.type", "netflow_v5")))
+	assert.Equal(t, int64(0), counterValue(t, reader, "otelcol_netflow_receiver_sequence_lost_packets"))
+
+	// The flow of the packet that counted the gap has the lost flows
+	logs := sink.AllLogs()
+	require.Len(t, logs, 3+sequenceReorderWindow)
+	for i := range logs {
+		attrs := logs[i].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes()
+		v, ok := attrs.Get("flow.sequence.lost")
+		assert.Equal(t, i == len(logs)-1, ok)
+		if ok {
+			assert.Equal(t, int64(6), v.Int())
+		}
+	}
+}
+
+func TestSequenceTrackerBuffered(t *testing.T) {
+	tracker, err := newSequenceTracker(noop.Meter{})
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Now()}
+	tracker.now = clock.Now
+	tracker.bufferMaxAge = time.Minute
+	key := sequenceKey{exporter: netip.MustParseAddr("192.0.2.1"), flowType: flowpb.FlowMessage_NETFLOW_V9}
+	observe := func(number uint32) uint32 {
+		return tracker.observe(sequenceHeader{key: key, number: number, increment: 1})
+	}
+
+	// The packet 1 waits for its template in the buffer, its gap stays open past the reorder window
+	require.Equal(t, uint32(0), observe(0))
+	tracker.hold(key)
+	var lost uint32
+	for n := uint32(2); n < 2+2*sequenceReorderWindow; n++ {
+		lost += observe(n)
+	}
+	assert.Equal(t, uint32(0), lost)
+	clock.advance(30 * time.Second)
+	assert.Equal(t, uint32(0), observe(1))
+	assert.Equal(t, uint32(0), observe(2+2*sequenceReorderWindow))
+
+	// The gap is counted once the buffer max age passed
+	next := uint32(3 + 2*sequenceReorderWindow)
+	tracker.hold(key)
+	for i := 0; i < sequenceReorderWindow; i++ {
+		lost += observe(next + 1 + uint32(i))
+	}
+	assert.Equal(t, uint32(0), lost)
+	clock.advance(2 * time.Minute)
+	assert.Equal(t, uint32(1), observe(next+1+sequenceReorderWindow))
+}
+
+func TestSequenceTrackerExpired(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	tracker, err := newSequenceTracker(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test"))
+	require.NoError(t, err)
+	clock := &fakeClock{now: time.Now()}
+	tracker.now = clock.Now
+	key := sequenceKey{exporter: netip.MustParseAddr("192.0.2.1"), flowType: flowpb.FlowMessage_NETFLOW_V9}
+	other := key
+	other.domain = 2
+
+	require.Equal(t, uint32(0), tracker.observe(sequenceHeader{key: key, number: 0, increment: 1}))
+	require.Equal(t, uint32(0), tracker.observe(sequenceHeader{key: key, number: 5, increment: 1}))
+	clock.advance(sequenceExpiry / 2)
+	require.Equal(t, uint32(0), tracker.observe(sequenceHeader{key: other, number: 0, increment: 1}))
+
+	// The exporter went silent, its sequence is removed and its gap counted
+	clock.advance(sequenceExpiry / 2)
+	require.Equal(t, uint32(0), tracker.observe(sequenceHeader{key: other, number: 1, increment: 1}))
+	tracker.mu.Lock()
+	assert.NotContains(t, tracker.sequences, key)
+	assert.Contains(t, tracker.sequences, other)
+	tracker.mu.Unlock()
+	assert.Equal(t, int64(4), counterValue(t, reader, "otelcol_netflow_receiver_sequence_lost_packets"))
+
+	// A packet of the silent exporter starts a new sequence
+	assert.Equal(t, uint32(0), tracker.observe(sequenceHeader{key: key, number: 1000, increment: 1}))
+}
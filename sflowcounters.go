@@ -0,0 +1,350 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/sflow"
+	"github.com/netsampler/goflow2/v2/producer"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/dynatrace-extensions/netflowreceiver/internal/metadata"
+)
+
+const (
+	// The formats of the counter records goflow2 does not decode, the interface and ethernet counters are decoded by goflow2
+	// The other records are ignored
+	sflowCounterProcessor  = 1001
+	sflowCounterHostCPU    = 2003
+	sflowCounterHostMemory = 2004
+
+	// sflowUnknownPercentage is the value of a processor percentage the agent does not know
+	sflowUnknownPercentage = -1
+
+	attrSamplerAddress  = "flow.sampler_address"
+	attrInterfaceIndex  = "flow.interface.index"
+	attrIODirection     = "network.io.direction"
+	attrErrorType       = "error.type"
+	attrCPUInterval     = "sflow.cpu.interval"
+	attrCPUMode         = "cpu.mode"
+	attrMemoryState     = "system.memory.state"
+	ioDirectionReceive  = "receive"
+	ioDirectionTransmit = "transmit"
+
+	// sflowAgentExpiry is how long the start time of an agent is kept after its last counters
+	sflowAgentExpiry = 10 * time.Minute
+)
+
+// sflowAgentKey identifies an sFlow agent, the sub agents of a device have their own uptime
+type sflowAgentKey struct {
+	agent    netip.Addr
+	subAgent uint32
+}
+
+// sflowAgentStart is when an agent started, computed from the uptime of its first packet
+type sflowAgentStart struct {
+	start  time.Time
+	uptime uint32
+	seen   time.Time
+}
+
+// sflowAgentStarts keeps the start time of every agent, so the cumulative sums of its counters keep the same start time
+// The uptime is in milliseconds and the packets are delayed by the network, computing it again for every packet would move it around
+type sflowAgentStarts struct {
+	mu     sync.Mutex
+	agents map[sflowAgentKey]*sflowAgentStart
+	// expired is when the agents that stopped sending counters were last removed
+	expired time.Time
+}
+
+func newSFlowAgentStarts() *sflowAgentStarts {
+	return &sflowAgentStarts{agents: make(map[sflowAgentKey]*sflowAgentStart)}
+}
+
+// start returns when the agent of the packet started, it is computed again when the uptime goes backwards because the agent restarted
+func (s *sflowAgentStarts) start(pkt *sflow.Packet, received time.Time) time.Time {
+	agent, _ := netip.AddrFromSlice(pkt.AgentIP)
+	key := sflowAgentKey{agent: agent.Unmap(), subAgent: pkt.SubAgentId}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if received.Sub(s.expired) >= sflowAgentExpiry {
+		s.expired = received
+		for k, a := range s.agents {
+			if received.Sub(a.seen) >= sflowAgentExpiry {
+				delete(s.agents, k)
+			}
+		}
+	}
+
+	a, ok := s.agents[key]
+	if !ok || pkt.Uptime < a.uptime {
+		a = &sflowAgentStart{start: received.Add(-time.Duration(pkt.Uptime) * time.Millisecond)}
+		s.agents[key] = a
+	}
+	a.uptime, a.seen = pkt.Uptime, received
+	return a.start
+}
+
+// sflowMetrics builds the metrics of the counter records of an sFlow packet
+// Each metric is created the first time a record has a value for it
+type sflowMetrics struct {
+	metrics pmetric.MetricSlice
+	byName  map[string]pmetric.Metric
+	// start is when the agent started, the counters are cumulative since then
+	start pcommon.Timestamp
+	now   pcommon.Timestamp
+	attrs pcommon.Map
+}
+
+func newSFlowMetrics(md pmetric.Metrics, pkt *sflow.Packet, start, received time.Time) *sflowMetrics {
+	scopeMetrics := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty()
+	scopeMetrics.Scope().SetName(metadata.ScopeName)
+	scopeMetrics.Scope().Attributes().PutStr("receiver", metadata.Type.String())
+
+	m := &sflowMetrics{
+		metrics: scopeMetrics.Metrics(),
+		byName:  make(map[string]pmetric.Metric),
+		start:   pcommon.NewTimestampFromTime(start),
+		now:     pcommon.NewTimestampFromTime(received),
+		attrs:   pcommon.NewMap(),
+	}
+	agent, _ := netip.AddrFromSlice(pkt.AgentIP)
+	m.attrs.PutStr(attrSamplerAddress, agent.String())
+	return m
+}
+
+// sum adds a data point to a cumulative monotonic sum, the attributes of the record and the extra ones are added to it
+func (m *sflowMetrics) sum(name, unit, description string, value int64, extra ...string) {
+	metric, ok := m.byName[name]
+	if !ok {
+		metric = m.newMetric(name, unit, description)
+		sum := metric.SetEmptySum()
+		sum.SetIsMonotonic(true)
+		sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+	}
+	dp := metric.Sum().DataPoints().AppendEmpty()
+	dp.SetStartTimestamp(m.start)
+	m.setDataPoint(dp, extra)
+	dp.SetIntValue(value)
+}
+
+// gauge adds an integer data point to a gauge
+func (m *sflowMetrics) gauge(name, unit, description string, value int64, extra ...string) {
+	m.gaugeDataPoint(name, unit, description, extra).SetIntValue(value)
+}
+
+// gaugeDouble adds a double data point to a gauge
+func (m *sflowMetrics) gaugeDouble(name, unit, description string, value float64, extra ...string) {
+	m.gaugeDataPoint(name, unit, description, extra).SetDoubleValue(value)
+}
+
+func (m *sflowMetrics) gaugeDataPoint(name, unit, description string, extra []string) pmetric.NumberDataPoint {
+	metric, ok := m.byName[name]
+	if !ok {
+		metric = m.newMetric(name, unit, description)
+		metric.SetEmptyGauge()
+	}
+	dp := metric.Gauge().DataPoints().AppendEmpty()
+	m.setDataPoint(dp, extra)
+	return dp
+}
+
+func (m *sflowMetrics) newMetric(name, unit, description string) pmetric.Metric {
+	metric := m.metrics.AppendEmpty()
+	metric.SetName(name)
+	metric.SetUnit(unit)
+	metric.SetDescription(description)
+	m.byName[name] = metric
+	return metric
+}
+
+// setDataPoint sets the time and the attributes of a data point, extra are pairs of attribute names and values
+func (m *sflowMetrics) setDataPoint(dp pmetric.NumberDataPoint, extra []string) {
+	dp.SetTimestamp(m.now)
+	m.attrs.CopyTo(dp.Attributes())
+	for i := 0; i+1 < len(extra); i += 2 {
+		dp.Attributes().PutStr(extra[i], extra[i+1])
+	}
+}
+
+// buildSFlowCounterMetrics converts the counter samples of an sFlow packet into metrics, start is when the agent started
+func buildSFlowCounterMetrics(pkt *sflow.Packet, start, received time.Time) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	m := newSFlowMetrics(md, pkt, start, received)
+	for _, sample := range pkt.Samples {
+		counterSample, ok := sample.(sflow.CounterSample)
+		if !ok {
+			continue
+		}
+		for _, record := range counterSample.Records {
+			m.attrs.Remove(attrInterfaceIndex)
+			switch data := record.Data.(type) {
+			case sflow.IfCounters:
+				m.attrs.PutInt(attrInterfaceIndex, int64(data.IfIndex))
+				addInterfaceCounters(m, data)
+			case sflow.EthernetCounters:
+				// The ethernet counters are in the same sample as the interface counters, so they have the same source
+				m.attrs.PutInt(attrInterfaceIndex, int64(counterSample.Header.SourceIdValue))
+				addEthernetCounters(m, data)
+			case sflow.RawRecord:
+				switch record.Header.DataFormat {
+				case sflowCounterProcessor:
+					addProcessorCounters(m, data.Data)
+				case sflowCounterHostCPU:
+					addHostCPUCounters(m, data.Data)
+				case sflowCounterHostMemory:
+					addHostMemoryCounters(m, data.Data)
+				}
+			}
+		}
+	}
+	return md
+}
+
+func addInterfaceCounters(m *sflowMetrics, c sflow.IfCounters) {
+	for _, counter := range []struct {
+		name, unit, description string
+		in, out                 int64
+	}{
+		{"sflow.interface.io", "By", "The bytes received and transmitted by the interface", int64(c.IfInOctets), int64(c.IfOutOctets)},
+		{
+			"sflow.interface.packets", "{packet}", "The packets received and transmitted by the interface",
+			int64(c.IfInUcastPkts) + int64(c.IfInMulticastPkts) + int64(c.IfInBroadcastPkts),
+			int64(c.IfOutUcastPkts) + int64(c.IfOutMulticastPkts) + int64(c.IfOutBroadcastPkts),
+		},
+		{"sflow.interface.errors", "{packet}", "The packets with errors received and transmitted by the interface", int64(c.IfInErrors), int64(c.IfOutErrors)},
+		{"sflow.interface.discards", "{packet}", "The packets discarded by the interface", int64(c.IfInDiscards), int64(c.IfOutDiscards)},
+	} {
+		m.sum(counter.name, counter.unit, counter.description, counter.in, attrIODirection, ioDirectionReceive)
+		m.sum(counter.name, counter.unit, counter.description, counter.out, attrIODirection, ioDirectionTransmit)
+	}
+	m.gauge("sflow.interface.speed", "bit/s", "The speed of the interface", int64(c.IfSpeed))
+	// The first bit of the status is the admin status and the second the operational status, they are set when the interface is up
+	m.gauge("sflow.interface.admin_status", "1", "Whether the interface is administratively up", int64(c.IfStatus&1))
+	m.gauge("sflow.interface.oper_status", "1", "Whether the interface is operationally up", int64(c.IfStatus>>1&1))
+}
+
+func addEthernetCounters(m *sflowMetrics, c sflow.EthernetCounters) {
+	const name, unit, description = "sflow.interface.ethernet.errors", "{frame}", "The ethernet frames with errors by type"
+	for _, e := range []struct {
+		errorType string
+		value     uint32
+	}{
+		{"alignment", c.Dot3StatsAlignmentErrors},
+		{"fcs", c.Dot3StatsFCSErrors},
+		{"single_collision", c.Dot3StatsSingleCollisionFrames},
+		{"multiple_collision", c.Dot3StatsMultipleCollisionFrames},
+		{"sqe_test", c.Dot3StatsSQETestErrors},
+		{"deferred_transmission", c.Dot3StatsDeferredTransmissions},
+		{"late_collision", c.Dot3StatsLateCollisions},
+		{"excessive_collision", c.Dot3StatsExcessiveCollisions},
+		{"internal_mac_transmit", c.Dot3StatsInternalMacTransmitErrors},
+		{"carrier_sense", c.Dot3StatsCarrierSenseErrors},
+		{"frame_too_long", c.Dot3StatsFrameTooLongs},
+		{"internal_mac_receive", c.Dot3StatsInternalMacReceiveErrors},
+		{"symbol", c.Dot3StatsSymbolErrors},
+	} {
+		m.sum(name, unit, description, int64(e.value), attrErrorType, e.errorType)
+	}
+}
+
+// addProcessorCounters adds the processor record of network devices
+// It has the CPU utilization over 5 seconds, 1 minute and 5 minutes in hundredths of a percent, and the total and free memory
+func addProcessorCounters(m *sflowMetrics, data []byte) {
+	if len(data) < 28 {
+		return
+	}
+	for i, interval := range []string{"5s", "1m", "5m"} {
+		percentage := int32(binary.BigEndian.Uint32(data[i*4:]))
+		if percentage == sflowUnknownPercentage {
+			continue
+		}
+		m.gaugeDouble("sflow.cpu.utilization", "1", "The CPU utilization of the device", float64(percentage)/10000, attrCPUInterval, interval)
+	}
+	total := binary.BigEndian.Uint64(data[12:])
+	free := binary.BigEndian.Uint64(data[20:])
+	m.gauge("sflow.memory.limit", "By", "The total memory of the device", int64(total))
+	const usage, usageDescription = "sflow.memory.usage", "The memory of the device by state"
+	m.gauge(usage, "By", usageDescription, int64(total-free), attrMemoryState, "used")
+	m.gauge(usage, "By", usageDescription, int64(free), attrMemoryState, "free")
+}
+
+// addHostCPUCounters adds the CPU record of hosts
+// It has the load averages, then the time spent in each mode in milliseconds after the number of processes, CPUs, their speed and the uptime
+func addHostCPUCounters(m *sflowMetrics, data []byte) {
+	if len(data) < 60 {
+		return
+	}
+	for i, interval := range []string{"1m", "5m", "15m"} {
+		load := math.Float32frombits(binary.BigEndian.Uint32(data[i*4:]))
+		m.gaugeDouble("sflow.host.cpu.load_average", "{thread}", "The load average of the host", float64(load), attrCPUInterval, interval)
+	}
+	m.gauge("sflow.host.cpu.count", "{cpu}", "The number of CPUs of the host", int64(binary.BigEndian.Uint32(data[20:])))
+	for i, mode := range []string{"user", "nice", "system", "idle", "iowait", "interrupt", "softirq"} {
+		ms := binary.BigEndian.Uint32(data[32+i*4:])
+		m.sum("sflow.host.cpu.time", "ms", "The time the CPUs of the host spent in each mode", int64(ms), attrCPUMode, mode)
+	}
+}
+
+// addHostMemoryCounters adds the memory record of hosts, the sizes are in bytes
+func addHostMemoryCounters(m *sflowMetrics, data []byte) {
+	if len(data) < 56 {
+		return
+	}
+	total := binary.BigEndian.Uint64(data[0:])
+	m.gauge("sflow.host.memory.limit", "By", "The total memory of the host", int64(total))
+	for i, state := range []string{"free", "shared", "buffered", "cached"} {
+		m.gauge("sflow.host.memory.usage", "By", "The memory of the host by state", int64(binary.BigEndian.Uint64(data[8+i*8:])), attrMemoryState, state)
+	}
+	swapTotal := binary.BigEndian.Uint64(data[40:])
+	swapFree := binary.BigEndian.Uint64(data[48:])
+	m.gauge("sflow.host.swap.limit", "By", "The total swap space of the host", int64(swapTotal))
+	const swapUsage, swapUsageDescription = "sflow.host.swap.usage", "The swap space of the host by state"
+	m.gauge(swapUsage, "By", swapUsageDescription, int64(swapTotal-swapFree), attrMemoryState, "used")
+	m.gauge(swapUsage, "By", swapUsageDescription, int64(swapFree), attrMemoryState, "free")
+}
+
+// sflowCountersProducer sends the counter samples of sFlow packets to the metrics consumer
+// goflow2 only produces messages for the flow samples, so the counters are read from the decoded packet
+type sflowCountersProducer struct {
+	wrapped         producer.ProducerInterface
+	metricsConsumer consumer.Metrics
+	starts          *sflowAgentStarts
+}
+
+// Produce lets the wrapped producer send the flows of the packet, then sends its counters
+func (p *sflowCountersProducer) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	flowMessageSet, err := p.wrapped.Produce(msg, args)
+	pkt, ok := msg.(*sflow.Packet)
+	if !ok {
+		return flowMessageSet, err
+	}
+
+	md := buildSFlowCounterMetrics(pkt, p.starts.start(pkt, args.TimeReceived), args.TimeReceived)
+	if md.DataPointCount() == 0 {
+		return flowMessageSet, err
+	}
+	if cErr := p.metricsConsumer.ConsumeMetrics(context.Background(), md); cErr != nil {
+		err = errors.Join(err, cErr)
+	}
+	return flowMessageSet, err
+}
+
+func (p *sflowCountersProducer) Close() {
+	p.wrapped.Close()
+}
+
+func (p *sflowCountersProducer) Commit(flowMessageSet []producer.ProducerMessage) {
+	p.wrapped.Commit(flowMessageSet)
+}
@@ -0,0 +1,200 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+)
+
+// sflowCountersPacket builds a sFlow v5 datagram with a counter sample of interface 3
+// The sample has the generic interface, ethernet, processor, host CPU and host memory records
+func sflowCountersPacket() []byte {
+	ifCounters := binary.BigEndian.AppendUint32(nil, 3)       // ifIndex
+	ifCounters = binary.BigEndian.AppendUint32(ifCounters, 6) // ifType
+	ifCounters = binary.BigEndian.AppendUint64(ifCounters, 10_000_000_000)
+	ifCounters = binary.BigEndian.AppendUint32(ifCounters, 1) // ifDirection
+	ifCounters = binary.BigEndian.AppendUint32(ifCounters, 3) // ifStatus, admin and oper up
+	ifCounters = binary.BigEndian.AppendUint64(ifCounters, 5000)
+	for _, v := range []uint32{40, 5, 5, 1, 2, 0} { // in ucast, multicast, broadcast, discards, errors, unknown protos
+		ifCounters = binary.BigEndian.AppendUint32(ifCounters, v)
+	}
+	ifCounters = binary.BigEndian.AppendUint64(ifCounters, 9000)
+	for _, v := range []uint32{80, 0, 0, 3, 4, 0} { // out ucast, multicast, broadcast, discards, errors, promiscuous mode
+		ifCounters = binary.BigEndian.AppendUint32(ifCounters, v)
+	}
+
+	ethernet := make([]byte, 52)
+	binary.BigEndian.PutUint32(ethernet[4:], 7) // FCS errors
+
+	processor := binary.BigEndian.AppendUint32(nil, 2550)                // 5s, 25.5%
+	processor = binary.BigEndian.AppendUint32(processor, 1000)           // 1m, 10%
+	processor = binary.BigEndian.AppendUint32(processor, math.MaxUint32) // 5m unknown
+	processor = binary.BigEndian.AppendUint64(processor, 4096)
+	processor = binary.BigEndian.AppendUint64(processor, 1024)
+
+	hostCPU := make([]byte, 68)
+	binary.BigEndian.PutUint32(hostCPU[0:], math.Float32bits(1.5))
+	binary.BigEndian.PutUint32(hostCPU[20:], 4)     // cpu_num
+	binary.BigEndian.PutUint32(hostCPU[32:], 12000) // cpu_user
+
+	hostMemory := make([]byte, 72)
+	binary.BigEndian.PutUint64(hostMemory[0:], 8192)  // mem_total
+	binary.BigEndian.PutUint64(hostMemory[8:], 2048)  // mem_free
+	binary.BigEndian.PutUint64(hostMemory[40:], 1000) // swap_total
+	binary.BigEndian.PutUint64(hostMemory[48:], 600)  // swap_free
+
+	sample := binary.BigEndian.AppendUint32(nil, 1)   // sequence number
+	sample = binary.BigEndian.AppendUint32(sample, 3) // source id, interface 3
+	sample = binary.BigEndian.AppendUint32(sample, 5) // records
+	for _, record := range []struct {
+		format uint32
+		data   []byte
+	}{{1, ifCounters}, {2, ethernet}, {1001, processor}, {2003, hostCPU}, {2004, hostMemory}} {
+		sample = binary.BigEndian.AppendUint32(sample, record.format)
+		sample = binary.BigEndian.AppendUint32(sample, uint32(len(record.data)))
+		sample = append(sample, record.data...)
+	}
+
+	packet := sflowV5Packet()
+	binary.BigEndian.PutUint32(packet[20:], 60_000)   // uptime
+	binary.BigEndian.PutUint32(packet[24:], 1)        // samples
+	packet = binary.BigEndian.AppendUint32(packet, 2) // counter sample
+	packet = binary.BigEndian.AppendUint32(packet, uint32(len(sample)))
+	return append(packet, sample...)
+}
+
+// findDataPoint returns the data point of a metric with the attribute, or the first one when attr is empty
+func findDataPoint(t *testing.T, md pmetric.Metrics, name, attr, value string) pmetric.NumberDataPoint {
+	t.Helper()
+	metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		m := metrics.At(i)
+		if m.Name() != name {
+			continue
+		}
+		var dps pmetric.NumberDataPointSlice
+		if m.Type() == pmetric.MetricTypeSum {
+			dps = m.Sum().DataPoints()
+		} else {
+			dps = m.Gauge().DataPoints()
+		}
+		for j := 0; j < dps.Len(); j++ {
+			if v, ok := dps.At(j).Attributes().Get(attr); attr == "" || ok && v.AsString() == value {
+				return dps.At(j)
+			}
+		}
+	}
+	require.Failf(t, "missing data point", "%s %s=%s", name, attr, value)
+	return pmetric.NumberDataPoint{}
+}
+
+func TestSFlowCounterMetrics(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Listeners[0].Scheme = "sflow"
+	cfg.Metrics.SFlowCounters = true
+	nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *cfg)
+	require.NoError(t, err)
+	sink := &consumertest.MetricsSink{}
+	nr.metricsConsumer = sink
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+
+	msg := testMessage(sflowCountersPacket())
+	require.NoError(t, decode(msg))
+	// The sample has no flows, so the only metrics are the counters
+	require.Len(t, sink.AllMetrics(), 1)
+	md := sink.AllMetrics()[0]
+
+	io := findDataPoint(t, md, "sflow.interface.io", attrIODirection, ioDirectionTransmit)
+	assert.Equal(t, int64(9000), io.IntValue())
+	assertStrAttribute(t, io.Attributes(), attrSamplerAddress, "10.0.0.1")
+	assertIntAttribute(t, io.Attributes(), attrInterfaceIndex, 3)
+	assert.Equal(t, pcommon.NewTimestampFromTime(msg.Received.Add(-time.Minute)), io.StartTimestamp())
+	assert.Equal(t, int64(50), findDataPoint(t, md, "sflow.interface.packets", attrIODirection, ioDirectionReceive).IntValue())
+	assert.Equal(t, int64(4), findDataPoint(t, md, "sflow.interface.errors", attrIODirection, ioDirectionTransmit).IntValue())
+	assert.Equal(t, int64(1), findDataPoint(t, md, "sflow.interface.discards", attrIODirection, ioDirectionReceive).IntValue())
+	assert.Equal(t, int64(10_000_000_000), findDataPoint(t, md, "sflow.interface.speed", "", "").IntValue())
+	assert.Equal(t, int64(1), findDataPoint(t, md, "sflow.interface.oper_status", "", "").IntValue())
+
+	fcs := findDataPoint(t, md, "sflow.interface.ethernet.errors", attrErrorType, "fcs")
+	assert.Equal(t, int64(7), fcs.IntValue())
+	assertIntAttribute(t, fcs.Attributes(), attrInterfaceIndex, 3)
+
+	cpu := findDataPoint(t, md, "sflow.cpu.utilization", attrCPUInterval, "5s")
+	assert.InDelta(t, 0.255, cpu.DoubleValue(), 1e-9)
+	_, ok := cpu.Attributes().Get(attrInterfaceIndex)
+	assert.False(t, ok, "the processor is not an interface")
+	assert.Equal(t, int64(3072), findDataPoint(t, md, "sflow.memory.usage", attrMemoryState, "used").IntValue())
+
+	assert.InDelta(t, 1.5, findDataPoint(t, md, "sflow.host.cpu.load_average", attrCPUInterval, "1m").DoubleValue(), 1e-9)
+	assert.Equal(t, int64(4), findDataPoint(t, md, "sflow.host.cpu.count", "", "").IntValue())
+	assert.Equal(t, int64(12000), findDataPoint(t, md, "sflow.host.cpu.time", attrCPUMode, "user").IntValue())
+	assert.Equal(t, int64(2048), findDataPoint(t, md, "sflow.host.memory.usage", attrMemoryState, "free").IntValue())
+	assert.Equal(t, int64(400), findDataPoint(t, md, "sflow.host.swap.usage", attrMemoryState, "used").IntValue())
+
+	metrics := md.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics()
+	for i := 0; i < metrics.Len(); i++ {
+		if metrics.At(i).Name() == "sflow.cpu.utilization" {
+			assert.Equal(t, 2, metrics.At(i).Gauge().DataPoints().Len(), "the unknown utilization is skipped")
+		}
+	}
+}
+
+func TestSFlowCounterMetricsDisabled(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Listeners[0].Scheme = "sflow"
+	nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *cfg)
+	require.NoError(t, err)
+	sink := &consumertest.MetricsSink{}
+	nr.metricsConsumer = sink
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+
+	require.NoError(t, decode(testMessage(sflowCountersPacket())))
+	assert.Empty(t, sink.AllMetrics())
+}
+
+func TestSFlowCounterStartTime(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Listeners[0].Scheme = "sflow"
+	cfg.Metrics.SFlowCounters = true
+	nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *cfg)
+	require.NoError(t, err)
+	sink := &consumertest.MetricsSink{}
+	nr.metricsConsumer = sink
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+
+	// decodeCounters decodes the counters with the uptime of the agent and returns the start time of the interface bytes
+	received := time.Now()
+	decodeCounters := func(uptime uint32, delay time.Duration) pcommon.Timestamp {
+		packet := sflowCountersPacket()
+		binary.BigEndian.PutUint32(packet[20:], uptime)
+		msg := testMessage(packet)
+		msg.Received = received.Add(time.Duration(uptime)*time.Millisecond + delay)
+		require.NoError(t, decode(msg))
+		md := sink.AllMetrics()[len(sink.AllMetrics())-1]
+		return findDataPoint(t, md, "sflow.interface.io", attrIODirection, ioDirectionTransmit).StartTimestamp()
+	}
+
+	start := decodeCounters(60_000, 0)
+	assert.Equal(t, pcommon.NewTimestampFromTime(received), start)
+	// The next packets are delayed by the network, the start time stays the same
+	assert.Equal(t, start, decodeCounters(90_000, 250*time.Millisecond))
+	assert.Equal(t, start, decodeCounters(120_000, -100*time.Millisecond))
+
+	// The uptime went backwards, the agent restarted
+	received = received.Add(time.Hour)
+	assert.Equal(t, pcommon.NewTimestampFromTime(received), decodeCounters(5_000, 0))
+}
@@ -0,0 +1,285 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+const (
+	snmpVersion2c = "v2c"
+	snmpVersion3  = "v3"
+
+	// The columns of the IF-MIB ifXTable that are polled, indexed by ifIndex
+	oidIfName      = ".1.3.6.1.2.1.31.1.1.1.1"
+	oidIfHighSpeed = ".1.3.6.1.2.1.31.1.1.1.15"
+	oidIfAlias     = ".1.3.6.1.2.1.31.1.1.1.18"
+
+	// snmpRetryInterval is how long a device that could not be polled waits before it is polled again
+	snmpRetryInterval = time.Minute
+	// snmpQueueSize is the number of devices that can wait to be polled, the rest are polled on a later flow
+	snmpQueueSize = 100
+	// snmpWorkers is the number of devices that are polled at the same time
+	snmpWorkers = 2
+)
+
+var (
+	snmpAuthProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+		"":       gosnmp.NoAuth,
+		"md5":    gosnmp.MD5,
+		"sha":    gosnmp.SHA,
+		"sha224": gosnmp.SHA224,
+		"sha256": gosnmp.SHA256,
+		"sha384": gosnmp.SHA384,
+		"sha512": gosnmp.SHA512,
+	}
+	snmpPrivProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+		"":       gosnmp.NoPriv,
+		"des":    gosnmp.DES,
+		"aes":    gosnmp.AES,
+		"aes192": gosnmp.AES192,
+		"aes256": gosnmp.AES256,
+	}
+)
+
+// interfaceInfo describes an interface of a device
+type interfaceInfo struct {
+	name  string
+	alias string
+	// speed is in bits per second, zero when it is unknown
+	speed uint64
+}
+
+// putInterfaceAttributes adds the attributes of the input or output interface
+func putInterfaceAttributes(attrs pcommon.Map, direction string, info interfaceInfo) {
+	putNonEmptyStr(attrs, "flow.interface."+direction+".name", info.name)
+	putNonEmptyStr(attrs, "flow.interface."+direction+".alias", info.alias)
+	if info.speed > 0 {
+		attrs.PutInt("flow.interface."+direction+".speed", int64(info.speed))
+	}
+}
+
+// snmpClient reads the interfaces of a device
+type snmpClient interface {
+	interfaces(target netip.Addr) (map[uint32]interfaceInfo, error)
+}
+
+// goSNMPClient polls the IF-MIB of the devices with gosnmp
+type goSNMPClient struct {
+	cfg SNMPConfig
+}
+
+// connection returns the gosnmp settings to poll a device with the configured version and credentials
+func (c *goSNMPClient) connection(target netip.Addr) *gosnmp.GoSNMP {
+	g := &gosnmp.GoSNMP{
+		Target:         target.String(),
+		Port:           c.cfg.Port,
+		Transport:      "udp",
+		Timeout:        c.cfg.Timeout,
+		Retries:        1,
+		MaxRepetitions: 25,
+	}
+	switch c.cfg.Version {
+	case snmpVersion2c:
+		g.Version = gosnmp.Version2c
+		g.Community = string(c.cfg.Community)
+	case snmpVersion3:
+		g.Version = gosnmp.Version3
+		g.SecurityModel = gosnmp.UserSecurityModel
+		g.MsgFlags = gosnmp.NoAuthNoPriv
+		if c.cfg.AuthProtocol != "" {
+			g.MsgFlags = gosnmp.AuthNoPriv
+			if c.cfg.PrivProtocol != "" {
+				g.MsgFlags = gosnmp.AuthPriv
+			}
+		}
+		g.SecurityParameters = &gosnmp.UsmSecurityParameters{
+			UserName:                 c.cfg.User,
+			AuthenticationProtocol:   snmpAuthProtocols[c.cfg.AuthProtocol],
+			AuthenticationPassphrase: string(c.cfg.AuthPassword),
+			PrivacyProtocol:          snmpPrivProtocols[c.cfg.PrivProtocol],
+			PrivacyPassphrase:        string(c.cfg.PrivPassword),
+		}
+	}
+	return g
+}
+
+func (c *goSNMPClient) interfaces(target netip.Addr) (map[uint32]interfaceInfo, error) {
+	g := c.connection(target)
+	if err := g.Connect(); err != nil {
+		return nil, err
+	}
+	defer g.Conn.Close()
+
+	interfaces := make(map[uint32]interfaceInfo)
+	walk := func(oid string, set func(info *interfaceInfo, pdu gosnmp.SnmpPDU)) error {
+		return g.BulkWalk(oid, func(pdu gosnmp.SnmpPDU) error {
+			ifIndex, err := strconv.ParseUint(strings.TrimPrefix(pdu.Name, oid+"."), 10, 32)
+			if err != nil {
+				return nil
+			}
+			info := interfaces[uint32(ifIndex)]
+			set(&info, pdu)
+			interfaces[uint32(ifIndex)] = info
+			return nil
+		})
+	}
+
+	if err := walk(oidIfName, func(info *interfaceInfo, pdu gosnmp.SnmpPDU) {
+		if b, ok := pdu.Value.([]byte); ok {
+			info.name = string(b)
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("failed to walk ifName: %w", err)
+	}
+	if err := walk(oidIfAlias, func(info *interfaceInfo, pdu gosnmp.SnmpPDU) {
+		if b, ok := pdu.Value.([]byte); ok {
+			info.alias = string(b)
+		}
+	}); err != nil {
+		return nil, fmt.Errorf("failed to walk ifAlias: %w", err)
+	}
+	if err := walk(oidIfHighSpeed, func(info *interfaceInfo, pdu gosnmp.SnmpPDU) {
+		// ifHighSpeed is in megabits per second
+		info.speed = gosnmp.ToBigInt(pdu.Value).Uint64() * 1_000_000
+	}); err != nil {
+		return nil, fmt.Errorf("failed to walk ifHighSpeed: %w", err)
+	}
+
+	return interfaces, nil
+}
+
+// snmpDevice is the cached state of a device
+type snmpDevice struct {
+	interfaces map[uint32]interfaceInfo
+	// expires is when the device is polled again
+	expires time.Time
+	// polling is true while the device is waiting to be polled or being polled
+	polling bool
+}
+
+// snmpEnricher adds the names of the interfaces, read from the devices with SNMP
+// Flows never wait for a device to be polled: the first flows of a device do not have the names
+// until the device answers, and the names are refreshed in the background when they expire
+type snmpEnricher struct {
+	cfg    SNMPConfig
+	client snmpClient
+	logger *zap.Logger
+	// now is replaced in the tests
+	now func() time.Time
+
+	mu      sync.Mutex
+	devices map[netip.Addr]*snmpDevice
+
+	queue chan netip.Addr
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func newSNMPEnricher(cfg SNMPConfig, logger *zap.Logger) *snmpEnricher {
+	return &snmpEnricher{
+		cfg:     cfg,
+		client:  &goSNMPClient{cfg: cfg},
+		logger:  logger,
+		now:     time.Now,
+		devices: make(map[netip.Addr]*snmpDevice),
+	}
+}
+
+func (e *snmpEnricher) start() error {
+	e.queue = make(chan netip.Addr, snmpQueueSize)
+	e.done = make(chan struct{})
+	for i := 0; i < snmpWorkers; i++ {
+		e.wg.Add(1)
+		go e.poll()
+	}
+	return nil
+}
+
+func (e *snmpEnricher) stop() {
+	if e.done == nil {
+		return
+	}
+	close(e.done)
+	e.wg.Wait()
+	e.done = nil
+}
+
+// poll polls the devices from the queue until the enricher is stopped
+func (e *snmpEnricher) poll() {
+	defer e.wg.Done()
+	for {
+		select {
+		case <-e.done:
+			return
+		case target := <-e.queue:
+			interfaces, err := e.client.interfaces(target)
+			if err != nil {
+				e.logger.Warn("Failed to read the interfaces of the device with SNMP", zap.Stringer("device", target), zap.Error(err))
+			}
+			e.update(target, interfaces, err)
+		}
+	}
+}
+
+// update stores the result of polling a device
+// When the device could not be polled the previous interfaces are kept and it is polled again sooner
+func (e *snmpEnricher) update(target netip.Addr, interfaces map[uint32]interfaceInfo, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	device := e.devices[target]
+	device.polling = false
+	if err != nil {
+		device.expires = e.now().Add(min(e.cfg.CacheTTL, snmpRetryInterval))
+		return
+	}
+	device.interfaces = interfaces
+	device.expires = e.now().Add(e.cfg.CacheTTL)
+}
+
+// device returns the interfaces of a device and schedules a poll if they are missing or expired
+func (e *snmpEnricher) device(target netip.Addr) map[uint32]interfaceInfo {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	device, ok := e.devices[target]
+	if !ok {
+		device = &snmpDevice{}
+		e.devices[target] = device
+	}
+	if !device.polling && !e.now().Before(device.expires) {
+		select {
+		case e.queue <- target:
+			device.polling = true
+		default:
+			// The queue is full, the device is polled on a later flow
+		}
+	}
+	return device.interfaces
+}
+
+func (e *snmpEnricher) enrich(pm *protoproducer.ProtoProducerMessage, attrs pcommon.Map) {
+	target, ok := netip.AddrFromSlice(pm.SamplerAddress)
+	if !ok || (pm.InIf == 0 && pm.OutIf == 0) {
+		return
+	}
+
+	interfaces := e.device(target.Unmap())
+	if info, ok := interfaces[pm.InIf]; ok && pm.InIf != 0 {
+		putInterfaceAttributes(attrs, "in", info)
+	}
+	if info, ok := interfaces[pm.OutIf]; ok && pm.OutIf != 0 {
+		putInterfaceAttributes(attrs, "out", info)
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.uber.org/zap"
+)
+
+// fakeSNMPClient returns the interfaces of a device and counts how often it was polled
+type fakeSNMPClient struct {
+	mu      sync.Mutex
+	devices map[uint32]interfaceInfo
+	err     error
+	polls   int
+	// block, when set, holds every poll until it is closed
+	block chan struct{}
+}
+
+func (c *fakeSNMPClient) set(interfaces map[uint32]interfaceInfo, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.devices, c.err = interfaces, err
+}
+
+func (c *fakeSNMPClient) pollCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.polls
+}
+
+func (c *fakeSNMPClient) interfaces(netip.Addr) (map[uint32]interfaceInfo, error) {
+	if c.block != nil {
+		<-c.block
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.polls++
+	return c.devices, c.err
+}
+
+// fakeClock is a clock that only moves when it is advanced
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestSNMPEnricher(t *testing.T, client snmpClient, clock *fakeClock) *snmpEnricher {
+	t.Helper()
+	enricher := newSNMPEnricher(SNMPConfig{Version: snmpVersion2c, CacheTTL: time.Hour}, zap.NewNop())
+	enricher.client = client
+	enricher.now = clock.Now
+	require.NoError(t, enricher.start())
+	t.Cleanup(enricher.stop)
+	return enricher
+}
+
+func testSNMPMessage() *protoproducer.ProtoProducerMessage {
+	pm := &protoproducer.ProtoProducerMessage{}
+	pm.SamplerAddress = netip.MustParseAddr("192.0.2.1").AsSlice()
+	pm.InIf = 1
+	pm.OutIf = 2
+	return pm
+}
+
+// enrichUntil enriches the message until the attribute has the expected value
+func enrichUntil(t *testing.T, enricher *snmpEnricher, pm *protoproducer.ProtoProducerMessage, key, expected string) pcommon.Map {
+	t.Helper()
+	attrs := pcommon.NewMap()
+	assert.Eventually(t, func() bool {
+		attrs.Clear()
+		enricher.enrich(pm, attrs)
+		v, ok := attrs.Get(key)
+		return ok && v.Str() == expected
+	}, time.Second, time.Millisecond)
+	return attrs
+}
+
+func TestSNMPEnricher(t *testing.T) {
+	client := &fakeSNMPClient{devices: map[uint32]interfaceInfo{
+		1: {name: "Gi0/0/1", alias: "uplink", speed: 10_000_000_000},
+		2: {name: "Gi0/0/2"},
+	}}
+	clock := &fakeClock{now: time.Now()}
+	enricher := newTestSNMPEnricher(t, client, clock)
+	pm := testSNMPMessage()
+
+	attrs := enrichUntil(t, enricher, pm, "flow.interface.in.name", "Gi0/0/1")
+	assertStrAttribute(t, attrs, "flow.interface.in.alias", "uplink")
+	assertIntAttribute(t, attrs, "flow.interface.in.speed", 10_000_000_000)
+	assertStrAttribute(t, attrs, "flow.interface.out.name", "Gi0/0/2")
+	_, ok := attrs.Get("flow.interface.out.alias")
+	assert.False(t, ok)
+	_, ok = attrs.Get("flow.interface.out.speed")
+	assert.False(t, ok)
+
+	// The cached interfaces are used until they expire
+	for i := 0; i < 10; i++ {
+		enricher.enrich(pm, pcommon.NewMap())
+	}
+	assert.Equal(t, 1, client.pollCount())
+
+	client.set(map[uint32]interfaceInfo{1: {name: "Te0/0/1"}}, nil)
+	clock.advance(time.Hour)
+	enrichUntil(t, enricher, pm, "flow.interface.in.name", "Te0/0/1")
+	assert.Equal(t, 2, client.pollCount())
+}
+
+func TestSNMPEnricherFailure(t *testing.T) {
+	client := &fakeSNMPClient{devices: map[uint32]interfaceInfo{1: {name: "Gi0/0/1"}}}
+	clock := &fakeClock{now: time.Now()}
+	enricher := newTestSNMPEnricher(t, client, clock)
+	pm := testSNMPMessage()
+
+	enrichUntil(t, enricher, pm, "flow.interface.in.name", "Gi0/0/1")
+
+	// The previous interfaces are kept while the device does not answer
+	client.set(nil, errors.New("request timeout"))
+	clock.advance(time.Hour)
+	enricher.enrich(pm, pcommon.NewMap())
+	assert.Eventually(t, func() bool { return client.pollCount() == 2 }, time.Second, time.Millisecond)
+	enrichUntil(t, enricher, pm, "flow.interface.in.name", "Gi0/0/1")
+
+	// A device that failed is polled again sooner than the cache ttl
+	client.set(map[uint32]interfaceInfo{1: {name: "Te0/0/1"}}, nil)
+	clock.advance(snmpRetryInterval)
+	enrichUntil(t, enricher, pm, "flow.interface.in.name", "Te0/0/1")
+}
+
+func TestSNMPEnricherDoesNotBlock(t *testing.T) {
+	client := &fakeSNMPClient{block: make(chan struct{})}
+	clock := &fakeClock{now: time.Now()}
+	enricher := newTestSNMPEnricher(t, client, clock)
+	// The cleanup of the enricher runs after this one, so the workers are released before they are stopped
+	t.Cleanup(func() { close(client.block) })
+
+	// More devices than the workers and the queue can hold are enriched while the client never answers
+	pm := testSNMPMessage()
+	for i := 0; i < snmpQueueSize*2; i++ {
+		pm.SamplerAddress = netip.AddrFrom4([4]byte{192, 0, byte(i >> 8), byte(i)}).AsSlice()
+		attrs := pcommon.NewMap()
+		enricher.enrich(pm, attrs)
+		assert.Equal(t, 0, attrs.Len())
+	}
+
+	// The devices that did not fit in the queue are polled on a later flow
+	enricher.mu.Lock()
+	defer enricher.mu.Unlock()
+	polling := 0
+	for _, device := range enricher.devices {
+		if device.polling {
+			polling++
+		}
+	}
+	assert.Len(t, enricher.devices, snmpQueueSize*2)
+	assert.LessOrEqual(t, polling, snmpQueueSize+snmpWorkers)
+}
+
+func TestSNMPEnricherSkipsUnknownInterfaces(t *testing.T) {
+	client := &fakeSNMPClient{devices: map[uint32]interfaceInfo{1: {name: "Gi0/0/1"}}}
+	enricher := newTestSNMPEnricher(t, client, &fakeClock{now: time.Now()})
+
+	pm := testSNMPMessage()
+	enrichUntil(t, enricher, pm, "flow.interface.in.name", "Gi0/0/1")
+
+	// Interface 0 is used when the interface is not known, and is never looked up
+	pm.InIf = 0
+	attrs := pcommon.NewMap()
+	enricher.enrich(pm, attrs)
+	assert.Equal(t, 0, attrs.Len())
+}
+
+// snmpAgent is a local SNMP v2c agent that answers the GETBULK requests of a walk with a fixed table
+type snmpAgent struct {
+	conn *net.UDPConn
+	// pdus are sorted by OID
+	pdus []gosnmp.SnmpPDU
+}
+
+func newSNMPAgent(t *testing.T, pdus []gosnmp.SnmpPDU) *snmpAgent {
+	t.Helper()
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	slices.SortFunc(pdus, func(a, b gosnmp.SnmpPDU) int { return slices.Compare(parseOID(a.Name), parseOID(b.Name)) })
+	agent := &snmpAgent{conn: conn, pdus: pdus}
+	go agent.serve()
+	t.Cleanup(func() { conn.Close() })
+	return agent
+}
+
+func (a *snmpAgent) port() uint16 {
+	return uint16(a.conn.LocalAddr().(*net.UDPAddr).Port)
+}
+
+func (a *snmpAgent) serve() {
+	buf := make([]byte, 65535)
+	decoder := &gosnmp.GoSNMP{Version: gosnmp.Version2c}
+	for {
+		n, addr, err := a.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		request, err := decoder.SnmpDecodePacket(buf[:n])
+		if err != nil || request.PDUType != gosnmp.GetBulkRequest || len(request.Variables) == 0 {
+			continue
+		}
+		response := &gosnmp.SnmpPacket{
+			Version:   gosnmp.Version2c,
+			Community: request.Community,
+			PDUType:   gosnmp.GetResponse,
+			RequestID: request.RequestID,
+			Variables: a.next(request.Variables[0].Name, int(request.MaxRepetitions)),
+		}
+		if out, err := response.MarshalMsg(); err == nil {
+			_, _ = a.conn.WriteToUDP(out, addr)
+		}
+	}
+}
+
+// next returns the PDUs that follow the OID, the walk ends at the end of the table
+func (a *snmpAgent) next(oid string, count int) []gosnmp.SnmpPDU {
+	after := parseOID(oid)
+	var pdus []gosnmp.SnmpPDU
+	for _, pdu := range a.pdus {
+		if len(pdus) < count && slices.Compare(parseOID(pdu.Name), after) > 0 {
+			pdus = append(pdus, pdu)
+		}
+	}
+	if len(pdus) < count {
+		pdus = append(pdus, gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView})
+	}
+	return pdus
+}
+
+func parseOID(oid string) []int {
+	var parts []int
+	for _, part := range strings.Split(strings.TrimPrefix(oid, "."), ".") {
+		n, _ := strconv.Atoi(part)
+		parts = append(parts, n)
+	}
+	return parts
+}
+
+func TestGoSNMPClient(t *testing.T) {
+	agent := newSNMPAgent(t, []gosnmp.SnmpPDU{
+		{Name: oidIfName + ".1", Type: gosnmp.OctetString, Value: []byte("Gi0/0/1")},
+		{Name: oidIfName + ".2", Type: gosnmp.OctetString, Value: []byte("Gi0/0/2")},
+		{Name: oidIfName + ".10", Type: gosnmp.OctetString, Value: []byte("Te0/1/0")},
+		{Name: oidIfAlias + ".1", Type: gosnmp.OctetString, Value: []byte("uplink")},
+		{Name: oidIfHighSpeed + ".1", Type: gosnmp.Gauge32, Value: uint(10_000)},
+		{Name: oidIfHighSpeed + ".10", Type: gosnmp.Gauge32, Value: uint(0)},
+		// The next column of the ifXTable ends the ifAlias walk
+		{Name: ".1.3.6.1.2.1.31.1.1.1.19.1", Type: gosnmp.TimeTicks, Value: uint32(0)},
+	})
+	client := &goSNMPClient{cfg: SNMPConfig{
+		Version:   snmpVersion2c,
+		Community: "public",
+		Port:      agent.port(),
+		Timeout:   time.Second,
+	}}
+
+	interfaces, err := client.interfaces(netip.MustParseAddr("127.0.0.1"))
+	require.NoError(t, err)
+	assert.Equal(t, map[uint32]interfaceInfo{
+		1:  {name: "Gi0/0/1", alias: "uplink", speed: 10_000_000_000},
+		2:  {name: "Gi0/0/2"},
+		10: {name: "Te0/1/0"},
+	}, interfaces)
+}
+
+func TestGoSNMPClientV3Flags(t *testing.T) {
+	tests := []struct {
+		auth, priv string
+		flags      gosnmp.SnmpV3MsgFlags
+	}{
+		{flags: gosnmp.NoAuthNoPriv},
+		{auth: "sha", flags: gosnmp.AuthNoPriv},
+		{auth: "sha256", priv: "aes", flags: gosnmp.AuthPriv},
+	}
+	for _, tt := range tests {
+		client := &goSNMPClient{cfg: SNMPConfig{Version: snmpVersion3, User: "netflow", AuthProtocol: tt.auth, PrivProtocol: tt.priv}}
+		g := client.connection(netip.MustParseAddr("192.0.2.1"))
+		assert.Equal(t, gosnmp.Version3, g.Version)
+		assert.Equal(t, tt.flags, g.MsgFlags, tt.auth+"/"+tt.priv)
+		params := g.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+		assert.Equal(t, "netflow", params.UserName)
+		assert.Equal(t, snmpAuthProtocols[tt.auth], params.AuthenticationProtocol)
+		assert.Equal(t, snmpPrivProtocols[tt.priv], params.PrivacyProtocol)
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	semconv "go.opentelemetry.io/collector/semconv/v1.27.0"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// stitchCheckInterval is the longest time between two checks for flows that waited for the whole window
+	stitchCheckInterval = time.Second
+
+	tcpProtocol = 6
+	tcpFlagSYN  = 0x02
+
+	// How the client of a stitched flow was chosen
+	stitchClientBySYN       = "syn"
+	stitchClientByPort      = "port"
+	stitchClientByFirstSeen = "first_seen"
+)
+
+// stitchKey identifies the flows of one direction of a conversation seen by a sampler
+type stitchKey struct {
+	samplerAddr, srcAddr, dstAddr string
+	srcPort, dstPort, proto       uint32
+}
+
+func newStitchKey(pm *protoproducer.ProtoProducerMessage) stitchKey {
+	return stitchKey{
+		samplerAddr: string(pm.SamplerAddress),
+		srcAddr:     string(pm.SrcAddr),
+		dstAddr:     string(pm.DstAddr),
+		srcPort:     pm.SrcPort,
+		dstPort:     pm.DstPort,
+		proto:       pm.Proto,
+	}
+}
+
+// reverse returns the key of the flows of the other direction
+func (k stitchKey) reverse() stitchKey {
+	k.srcAddr, k.dstAddr = k.dstAddr, k.srcAddr
+	k.srcPort, k.dstPort = k.dstPort, k.srcPort
+	return k
+}
+
+// stitchedFlowMessage is a conversation made of the flows of both directions
+type stitchedFlowMessage struct {
+	// flow is the flow from the client to the server, it has the start and end of both flows
+	flow *protoproducer.ProtoProducerMessage
+	// reverse is the flow from the server to the client
+	reverse *protoproducer.ProtoProducerMessage
+	// clientBy tells how the client was chosen
+	clientBy string
+}
+
+// newStitchedFlow pairs two flows of opposite directions, first is the one that was received first
+// The client is the first to send a SYN, or else the side with the higher port, as servers usually listen on the lower one
+func newStitchedFlow(first, second *protoproducer.ProtoProducerMessage) *stitchedFlowMessage {
+	s := &stitchedFlowMessage{flow: first, reverse: second, clientBy: stitchClientByFirstSeen}
+	firstSYN := first.Proto == tcpProtocol && first.TcpFlags&tcpFlagSYN != 0
+	secondSYN := second.Proto == tcpProtocol && second.TcpFlags&tcpFlagSYN != 0
+	switch {
+	case firstSYN && (!secondSYN || first.TimeFlowStartNs <= second.TimeFlowStartNs):
+		s.clientBy = stitchClientBySYN
+	case secondSYN:
+		s.flow, s.reverse, s.clientBy = second, first, stitchClientBySYN
+	case first.DstPort < first.SrcPort:
+		s.clientBy = stitchClientByPort
+	case first.DstPort > first.SrcPort:
+		s.flow, s.reverse, s.clientBy = second, first, stitchClientByPort
+	}
+
+	s.flow.TimeFlowStartNs = min(first.TimeFlowStartNs, second.TimeFlowStartNs)
+	s.flow.TimeFlowEndNs = max(first.TimeFlowEndNs, second.TimeFlowEndNs)
+	s.flow.TimeReceivedNs = max(first.TimeReceivedNs, second.TimeReceivedNs)
+	return s
+}
+
+// addAttributes adds the attributes of the client to server flow, the totals of the reverse flow and the roles
+func (s *stitchedFlowMessage) addAttributes(attrs pcommon.Map, enrichers ...flowEnricher) {
+	addFlowAttributes(s.flow, attrs)
+	for _, enricher := range enrichers {
+		enricher.enrich(s.flow, attrs)
+	}
+
+	attrs.PutInt("flow.reverse.io.bytes", int64(s.reverse.Bytes))
+	attrs.PutInt("flow.reverse.io.packets", int64(s.reverse.Packets))
+
+	clientAddr, _ := netip.AddrFromSlice(s.flow.SrcAddr)
+	serverAddr, _ := netip.AddrFromSlice(s.flow.DstAddr)
+	attrs.PutStr(semconv.AttributeClientAddress, clientAddr.String())
+	attrs.PutInt(semconv.AttributeClientPort, int64(s.flow.SrcPort))
+	attrs.PutStr(semconv.AttributeServerAddress, serverAddr.String())
+	attrs.PutInt(semconv.AttributeServerPort, int64(s.flow.DstPort))
+	attrs.PutStr("flow.stitching.client_by", s.clientBy)
+}
+
+// total returns a flow with the bytes and packets of both directions, it is what the metrics count
+func (s *stitchedFlowMessage) total() *protoproducer.ProtoProducerMessage {
+	total := &protoproducer.ProtoProducerMessage{}
+	total.Bytes = s.flow.Bytes + s.reverse.Bytes
+	total.Packets = s.flow.Packets + s.reverse.Packets
+	total.TimeFlowStartNs = s.flow.TimeFlowStartNs
+	total.TimeFlowEndNs = s.flow.TimeFlowEndNs
+	total.TimeReceivedNs = s.flow.TimeReceivedNs
+	return total
+}
+
+// pendingFlow is a flow that waits for the flow of the other direction
+type pendingFlow struct {
+	flow *protoproducer.ProtoProducerMessage
+	seen time.Time
+}
+
+// flowStitcher is a producer that pairs the flows of both directions of a conversation into a single flow
+// Like the aggregator, it sends the flows to the output from its own goroutine, the flows without a pair once the window expires
+type flowStitcher struct {
+	wrapped producer.ProducerInterface
+	output  producer.ProducerInterface
+	cfg     StitchingConfig
+	logger  *zap.Logger
+	// now is replaced in the tests
+	now func() time.Time
+
+	mu      sync.Mutex
+	pending map[stitchKey]*pendingFlow
+	// ready are the flows sent to the output with the next flush
+	ready []producer.ProducerMessage
+	// overflow counts the flows that were sent without waiting because max_flows were already waiting
+	overflow int
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newFlowStitcher(wrapped producer.ProducerInterface, cfg StitchingConfig, logger *zap.Logger) *flowStitcher {
+	return &flowStitcher{
+		wrapped: wrapped,
+		cfg:     cfg,
+		logger:  logger,
+		now:     time.Now,
+		pending: make(map[stitchKey]*pendingFlow),
+	}
+}
+
+// Produce pairs the flows of the message with the waiting flows of the other direction, or makes them wait
+// The flows are still returned so the pipe commits them back to the wrapped producer, the stitcher keeps copies
+func (s *flowStitcher) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	flowMessageSet, err := s.wrapped.Produce(msg, args)
+	if err != nil {
+		return flowMessageSet, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, m := range flowMessageSet {
+		pm, ok := m.(*protoproducer.ProtoProducerMessage)
+		if !ok {
+			continue
+		}
+		flow := &protoproducer.ProtoProducerMessage{}
+		proto.Merge(&flow.FlowMessage, &pm.FlowMessage)
+
+		// The firewall and NAT events have no other direction, they are sent as they are
+		if _, ok := getSecurityEvent(flow); ok {
+			s.ready = append(s.ready, flow)
+			continue
+		}
+
+		key := newStitchKey(flow)
+		reverseKey := key.reverse()
+		// A flow from an address and port to the same address and port has no other direction
+		if other, ok := s.pending[reverseKey]; ok && reverseKey != key {
+			delete(s.pending, reverseKey)
+			s.ready = append(s.ready, newStitchedFlow(other.flow, flow))
+			continue
+		}
+
+		// An older flow of the same direction is sent as it is
+		if old, ok := s.pending[key]; ok {
+			s.ready = append(s.ready, old.flow)
+		} else if len(s.pending) >= s.cfg.MaxFlows {
+			s.ready = append(s.ready, flow)
+			s.overflow++
+			continue
+		}
+		s.pending[key] = &pendingFlow{flow: flow, seen: now}
+	}
+	return flowMessageSet, nil
+}
+
+// start flushes the stitched flows and the flows that waited for the whole window until stop is called
+func (s *flowStitcher) start() {
+	s.done = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(min(s.cfg.Window, stitchCheckInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.flush(false)
+			}
+		}
+	}()
+}
+
+// stop stops flushing and sends every flow, including the ones that are still waiting
+func (s *flowStitcher) stop() {
+	if s.done == nil {
+		return
+	}
+	close(s.done)
+	s.wg.Wait()
+	s.done = nil
+	s.flush(true)
+}
+
+// flush sends the stitched flows and the flows that waited for the whole window, or all of them
+func (s *flowStitcher) flush(all bool) {
+	s.mu.Lock()
+	flowMessageSet, overflow := s.ready, s.overflow
+	s.ready, s.overflow = nil, 0
+	now := s.now()
+	for key, p := range s.pending {
+		if all || now.Sub(p.seen) >= s.cfg.Window {
+			flowMessageSet = append(flowMessageSet, p.flow)
+			delete(s.pending, key)
+		}
+	}
+	s.mu.Unlock()
+
+	if overflow > 0 {
+		s.logger.Warn("the number of flows waiting to be stitched reached the limit, the remaining flows were sent without their pair",
+			zap.Int("max_flows", s.cfg.MaxFlows), zap.Int("flows", overflow))
+	}
+	if len(flowMessageSet) == 0 || s.output == nil {
+		return
+	}
+
+	produced, err := s.output.Produce(&aggregationFlush{flows: flowMessageSet}, &producer.ProduceArgs{TimeReceived: now})
+	if err != nil {
+		s.logger.Error("failed to send the stitched flows", zap.Error(err))
+	}
+	s.output.Commit(produced)
+}
+
+func (s *flowStitcher) Close() {
+	s.wrapped.Close()
+}
+
+func (s *flowStitcher) Commit(flowMessageSet []producer.ProducerMessage) {
+	s.wrapped.Commit(flowMessageSet)
+}
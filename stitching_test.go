@@ -0,0 +1,198 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	flowpb "github.com/netsampler/goflow2/v2/pb"
+	"github.com/netsampler/goflow2/v2/producer"
+	protoproducer "github.com/netsampler/goflow2/v2/producer/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	semconv "go.opentelemetry.io/collector/semconv/v1.27.0"
+	"go.uber.org/zap"
+)
+
+// newTestDirectionFlow builds a TCP flow of one direction of a conversation
+func newTestDirectionFlow(src string, srcPort uint32, dst string, dstPort uint32, tcpFlags uint32, bytes uint64, start uint64) *protoproducer.ProtoProducerMessage {
+	return &protoproducer.ProtoProducerMessage{
+		FlowMessage: flowpb.FlowMessage{
+			SrcAddr:         netip.MustParseAddr(src).AsSlice(),
+			DstAddr:         netip.MustParseAddr(dst).AsSlice(),
+			SrcPort:         srcPort,
+			DstPort:         dstPort,
+			SamplerAddress:  netip.MustParseAddr("192.168.1.100").AsSlice(),
+			Type:            flowpb.FlowMessage_IPFIX,
+			Proto:           tcpProtocol,
+			TcpFlags:        tcpFlags,
+			Bytes:           bytes,
+			Packets:         1,
+			TimeFlowStartNs: start,
+			TimeFlowEndNs:   start + 1000,
+			TimeReceivedNs:  start + 2000,
+		},
+	}
+}
+
+func newTestStitcher(cfg StitchingConfig) (*flowStitcher, *staticProducer, *consumertest.LogsSink, *fakeClock) {
+	sink := &consumertest.LogsSink{}
+	source := &staticProducer{}
+	clock := &fakeClock{now: time.Now()}
+	stitcher := newFlowStitcher(source, cfg, zap.NewNop())
+	stitcher.now = clock.Now
+	stitcher.output = newOtelLogsProducer(aggregationOutput{}, sink, zap.NewNop())
+	return stitcher, source, sink, clock
+}
+
+func flushedRecords(t *testing.T, sink *consumertest.LogsSink) plog.LogRecordSlice {
+	t.Helper()
+	require.Len(t, sink.AllLogs(), 1)
+	return sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+}
+
+func TestStitching(t *testing.T) {
+	tests := []struct {
+		name     string
+		first    *protoproducer.ProtoProducerMessage
+		second   *protoproducer.ProtoProducerMessage
+		clientBy string
+	}{
+		{
+			name:     "syn",
+			first:    newTestDirectionFlow("10.0.0.1", 443, "10.0.0.2", 50000, 0x12, 900, 2000),
+			second:   newTestDirectionFlow("10.0.0.2", 50000, "10.0.0.1", 443, 0x02, 100, 1000),
+			clientBy: stitchClientBySYN,
+		},
+		{
+			name:     "port",
+			first:    newTestDirectionFlow("10.0.0.1", 443, "10.0.0.2", 50000, 0x10, 900, 1000),
+			second:   newTestDirectionFlow("10.0.0.2", 50000, "10.0.0.1", 443, 0x10, 100, 2000),
+			clientBy: stitchClientByPort,
+		},
+		{
+			name:     "first_seen",
+			first:    newTestDirectionFlow("10.0.0.2", 50000, "10.0.0.1", 50000, 0x10, 100, 1000),
+			second:   newTestDirectionFlow("10.0.0.1", 50000, "10.0.0.2", 50000, 0x10, 900, 2000),
+			clientBy: stitchClientByFirstSeen,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stitcher, source, sink, _ := newTestStitcher(StitchingConfig{Window: time.Minute, MaxFlows: 10})
+
+			source.messages = []producer.ProducerMessage{tt.first}
+			produced, err := stitcher.Produce(nil, &producer.ProduceArgs{})
+			require.NoError(t, err)
+			assert.Len(t, produced, 1, "the flows are returned so they are committed back to the proto producer")
+			source.messages = []producer.ProducerMessage{tt.second}
+			_, err = stitcher.Produce(nil, &producer.ProduceArgs{})
+			require.NoError(t, err)
+
+			// The proto producer reuses its messages once they are committed
+			tt.first.Bytes, tt.second.Bytes = 0, 0
+
+			stitcher.flush(false)
+			records := flushedRecords(t, sink)
+			require.Equal(t, 1, records.Len())
+			attrs := records.At(0).Attributes()
+			assertStrAttribute(t, attrs, semconv.AttributeSourceAddress, "10.0.0.2")
+			assertStrAttribute(t, attrs, semconv.AttributeClientAddress, "10.0.0.2")
+			assertIntAttribute(t, attrs, semconv.AttributeClientPort, 50000)
+			assertStrAttribute(t, attrs, semconv.AttributeServerAddress, "10.0.0.1")
+			assertIntAttribute(t, attrs, "flow.io.bytes", 100)
+			assertIntAttribute(t, attrs, "flow.reverse.io.bytes", 900)
+			assertIntAttribute(t, attrs, "flow.reverse.io.packets", 1)
+			assertIntAttribute(t, attrs, "flow.start", 1000)
+			assertIntAttribute(t, attrs, "flow.end", 3000)
+			assertStrAttribute(t, attrs, "flow.stitching.client_by", tt.clientBy)
+		})
+	}
+}
+
+func TestStitchingUnmatched(t *testing.T) {
+	stitcher, source, sink, clock := newTestStitcher(StitchingConfig{Window: time.Minute, MaxFlows: 10})
+
+	source.messages = []producer.ProducerMessage{newTestDirectionFlow("10.0.0.2", 50000, "10.0.0.1", 443, 0x02, 100, 1000)}
+	_, err := stitcher.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+
+	clock.advance(59 * time.Second)
+	stitcher.flush(false)
+	assert.Empty(t, sink.AllLogs())
+
+	// The flow is sent as it is once the window expires
+	clock.advance(time.Second)
+	stitcher.flush(false)
+	records := flushedRecords(t, sink)
+	require.Equal(t, 1, records.Len())
+	_, stitched := records.At(0).Attributes().Get("flow.reverse.io.bytes")
+	assert.False(t, stitched)
+	assert.Empty(t, stitcher.pending)
+}
+
+func TestStitchingEvents(t *testing.T) {
+	stitcher, source, sink, _ := newTestStitcher(StitchingConfig{Window: time.Minute, MaxFlows: 10})
+
+	// The event does not wait for a flow of the other direction
+	source.messages = []producer.ProducerMessage{newTestNATEvent("10.0.0.1", 1)}
+	_, err := stitcher.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+	assert.Empty(t, stitcher.pending)
+
+	stitcher.flush(false)
+	records := flushedRecords(t, sink)
+	require.Equal(t, 1, records.Len())
+	assert.Equal(t, "nat.nat44_session_create", records.At(0).EventName())
+}
+
+func TestStitchingMaxFlows(t *testing.T) {
+	stitcher, source, sink, _ := newTestStitcher(StitchingConfig{Window: time.Minute, MaxFlows: 1})
+
+	source.messages = []producer.ProducerMessage{
+		newTestDirectionFlow("10.0.0.2", 50000, "10.0.0.1", 443, 0x02, 100, 1000),
+		newTestDirectionFlow("10.0.0.3", 50000, "10.0.0.1", 443, 0x02, 100, 1000),
+		// The reverse of the first flow is still stitched
+		newTestDirectionFlow("10.0.0.1", 443, "10.0.0.2", 50000, 0x12, 900, 2000),
+	}
+	_, err := stitcher.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+
+	stitcher.flush(false)
+	records := flushedRecords(t, sink)
+	require.Equal(t, 2, records.Len())
+	assertStrAttribute(t, records.At(0).Attributes(), semconv.AttributeSourceAddress, "10.0.0.3")
+	assertIntAttribute(t, records.At(1).Attributes(), "flow.reverse.io.bytes", 900)
+}
+
+func TestStitchingStop(t *testing.T) {
+	stitcher, source, sink, _ := newTestStitcher(StitchingConfig{Window: time.Hour, MaxFlows: 10})
+	stitcher.start()
+
+	source.messages = []producer.ProducerMessage{newTestDirectionFlow("10.0.0.2", 50000, "10.0.0.1", 443, 0x02, 100, 1000)}
+	_, err := stitcher.Produce(nil, &producer.ProduceArgs{})
+	require.NoError(t, err)
+
+	// The waiting flows are sent when the listener stops
+	stitcher.stop()
+	assert.Equal(t, 1, flushedRecords(t, sink).Len())
+}
+
+func TestStitchedFlowMetrics(t *testing.T) {
+	stitched := newStitchedFlow(
+		newTestDirectionFlow("10.0.0.2", 50000, "10.0.0.1", 443, 0x02, 100, 1000),
+		newTestDirectionFlow("10.0.0.1", 443, "10.0.0.2", 50000, 0x12, 900, 2000),
+	)
+	metrics := buildMetrics([]producer.ProducerMessage{stitched}, []string{"flow.type"})
+	values := map[string]int64{}
+	for i := 0; i < metrics.MetricCount(); i++ {
+		m := metrics.ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(i)
+		values[m.Name()] = m.Sum().DataPoints().At(0).IntValue()
+	}
+	assert.Equal(t, map[string]int64{"flow.io.bytes": 1000, "flow.io.packets": 2, "flow.count": 2}, values)
+}
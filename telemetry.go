@@ -0,0 +1,219 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"context"
+	"errors"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/netsampler/goflow2/v2/producer"
+	"github.com/netsampler/goflow2/v2/utils"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/receiver"
+	"go.opentelemetry.io/collector/receiver/receiverhelper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/dynatrace-extensions/netflowreceiver/internal/metadata"
+)
+
+const (
+	// The types of the errors counted by the decode errors metric
+	errorTypeTemplateNotFound = "template_not_found"
+	errorTypeUnknownProtocol  = "unknown_protocol"
+	errorTypeDecode           = "decode"
+)
+
+// receiverTelemetry records the internal metrics of the receiver
+// The accepted and refused flows of each pipeline are recorded by the receiverhelper, the rest are specific to this receiver
+type receiverTelemetry struct {
+	obsrecv *receiverhelper.ObsReport
+	// meter creates the metrics of other parts of the receiver, it is a noop meter below the basic level
+	meter metric.Meter
+
+	packetsReceived metric.Int64Counter
+	bytesReceived   metric.Int64Counter
+	packetsDropped  metric.Int64Counter
+	decodeErrors    metric.Int64Counter
+	flowsProduced   metric.Int64Counter
+	flowsRefused    metric.Int64Counter
+	bufferedPackets metric.Int64Counter
+	replayedPackets metric.Int64Counter
+	expiredPackets  metric.Int64Counter
+}
+
+func newReceiverTelemetry(params receiver.Settings) (*receiverTelemetry, error) {
+	obsrecv, err := receiverhelper.NewObsReport(receiverhelper.ObsReportSettings{
+		ReceiverID:             params.ID,
+		Transport:              "udp",
+		LongLivedCtx:           true,
+		ReceiverCreateSettings: params,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Like the metrics of the receiverhelper, they are only recorded from the basic level
+	meter := params.TelemetrySettings.MeterProvider.Meter(metadata.ScopeName)
+	if params.TelemetrySettings.MetricsLevel < configtelemetry.LevelBasic {
+		meter = noop.Meter{}
+	}
+	t := &receiverTelemetry{obsrecv: obsrecv, meter: meter}
+	counters := []struct {
+		counter     *metric.Int64Counter
+		name        string
+		unit        string
+		description string
+	}{
+		{&t.packetsReceived, "otelcol_netflow_receiver_packets_received", "{packet}", "Number of packets received by the listener"},
+		{&t.bytesReceived, "otelcol_netflow_receiver_bytes_received", "By", "Number of bytes received by the listener"},
+		{&t.packetsDropped, "otelcol_netflow_receiver_packets_dropped", "{packet}", "Number of packets dropped because the queue of the listener was full"},
+		{&t.decodeErrors, "otelcol_netflow_receiver_decode_errors", "{packet}", "Number of packets that could not be decoded, by error type"},
+		{&t.flowsProduced, "otelcol_netflow_receiver_flows_produced", "{flow}", "Number of flows sent to the pipelines, after the aggregation when it is enabled"},
+		{&t.flowsRefused, "otelcol_netflow_receiver_flows_refused", "{flow}", "Number of flows refused by the next consumer"},
+		{&t.bufferedPackets, "otelcol_netflow_receiver_template_buffer_buffered", "{packet}", "Number of packets buffered until the exporter sends their template"},
+		{&t.replayedPackets, "otelcol_netflow_receiver_template_buffer_replayed", "{packet}", "Number of buffered packets decoded again after their template arrived"},
+		{&t.expiredPackets, "otelcol_netflow_receiver_template_buffer_expired", "{packet}", "Number of buffered packets dropped because their template did not arrive in time"},
+	}
+	for _, c := range counters {
+		if *c.counter, err = meter.Int64Counter(c.name, metric.WithUnit(c.unit), metric.WithDescription(c.description)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// forListener returns the telemetry of a listener, every measurement has its scheme and address
+func (t *receiverTelemetry) forListener(cfg ListenerConfig) *listenerTelemetry {
+	return &listenerTelemetry{
+		receiverTelemetry: t,
+		scheme:            cfg.Scheme,
+		attrs: metric.WithAttributeSet(attribute.NewSet(
+			attribute.String("scheme", cfg.Scheme),
+			attribute.String("listener", cfg.address()),
+		)),
+	}
+}
+
+// listenerTelemetry records the internal metrics of a single listener
+type listenerTelemetry struct {
+	*receiverTelemetry
+	scheme string
+	attrs  metric.MeasurementOption
+}
+
+func (t *listenerTelemetry) packetDropped() {
+	t.packetsDropped.Add(context.Background(), 1, t.attrs)
+}
+
+// decodeFunc counts the packets received by the listener and the ones that could not be decoded
+// The errors are counted here because goflow2 drops them when the error handler is not fast enough
+func (t *listenerTelemetry) decodeFunc(decode utils.DecoderFunc) utils.DecoderFunc {
+	return func(msg any) error {
+		ctx := context.Background()
+		t.packetsReceived.Add(ctx, 1, t.attrs)
+		if m, ok := msg.(*utils.Message); ok {
+			t.bytesReceived.Add(ctx, int64(len(m.Payload)), t.attrs)
+		}
+
+		err := decode(msg)
+		var cErr *consumerError
+		if err != nil && !errors.As(err, &cErr) {
+			t.decodeErrors.Add(ctx, 1, t.attrs, metric.WithAttributes(attribute.String("error.type", decodeErrorType(err))))
+		}
+		return err
+	}
+}
+
+// decodeErrorType returns the type of a decoding error for the decode errors metric
+func decodeErrorType(err error) string {
+	var detectionErr *FlowDetectionError
+	switch {
+	case errors.Is(err, netflow.ErrorTemplateNotFound):
+		return errorTypeTemplateNotFound
+	case errors.As(err, &detectionErr):
+		return errorTypeUnknownProtocol
+	default:
+		return errorTypeDecode
+	}
+}
+
+// consumerError is returned when the next consumer refuses the flows, so it is not counted as a decoding error
+type consumerError struct {
+	err error
+}
+
+func (e *consumerError) Error() string {
+	return e.err.Error()
+}
+
+func (e *consumerError) Unwrap() error {
+	return e.err
+}
+
+// telemetryProducer counts the flows that the producers it wraps send to the pipelines
+type telemetryProducer struct {
+	wrapped   producer.ProducerInterface
+	telemetry *listenerTelemetry
+}
+
+func (p *telemetryProducer) Produce(msg any, args *producer.ProduceArgs) ([]producer.ProducerMessage, error) {
+	flowMessageSet, err := p.wrapped.Produce(msg, args)
+	var cErr *consumerError
+	switch {
+	case errors.As(err, &cErr):
+		p.telemetry.flowsRefused.Add(context.Background(), int64(len(flowMessageSet)), p.telemetry.attrs)
+	case err == nil:
+		p.telemetry.flowsProduced.Add(context.Background(), int64(len(flowMessageSet)), p.telemetry.attrs)
+	}
+	return flowMessageSet, err
+}
+
+func (p *telemetryProducer) Close() {
+	p.wrapped.Close()
+}
+
+func (p *telemetryProducer) Commit(flowMessageSet []producer.ProducerMessage) {
+	p.wrapped.Commit(flowMessageSet)
+}
+
+// obsLogsConsumer records the log records accepted and refused by the next consumer
+type obsLogsConsumer struct {
+	consumer.Logs
+	telemetry *listenerTelemetry
+}
+
+func (c *obsLogsConsumer) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	ctx = c.telemetry.obsrecv.StartLogsOp(ctx)
+	// The count is taken first, the next consumer is allowed to change the logs
+	count := ld.LogRecordCount()
+	err := c.Logs.ConsumeLogs(ctx, ld)
+	c.telemetry.obsrecv.EndLogsOp(ctx, c.telemetry.scheme, count, err)
+	if err != nil {
+		return &consumerError{err: err}
+	}
+	return nil
+}
+
+// obsMetricsConsumer records the data points accepted and refused by the next consumer
+type obsMetricsConsumer struct {
+	consumer.Metrics
+	telemetry *listenerTelemetry
+}
+
+func (c *obsMetricsConsumer) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	ctx = c.telemetry.obsrecv.StartMetricsOp(ctx)
+	count := md.DataPointCount()
+	err := c.Metrics.ConsumeMetrics(ctx, md)
+	c.telemetry.obsrecv.EndMetricsOp(ctx, c.telemetry.scheme, count, err)
+	if err != nil {
+		return &consumerError{err: err}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// newTestTelemetryDecoder creates the decode function of a listener whose internal metrics are read from the returned reader
+func newTestTelemetryDecoder(t *testing.T, scheme string, logConsumer consumer.Logs) (utils.DecoderFunc, *listener, *sdkmetric.ManualReader) {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	params := receivertest.NewNopSettings()
+	params.TelemetrySettings.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	params.TelemetrySettings.MetricsLevel = configtelemetry.LevelDetailed
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Listeners[0].Scheme = scheme
+	nr, err := newNetflowReceiver(params, *cfg)
+	require.NoError(t, err)
+	nr.logConsumer = logConsumer
+
+	l := nr.listeners[0]
+	decode, err := nr.buildDecodeFunc(l)
+	require.NoError(t, err)
+	return decode, l, reader
+}
+
+// counterValue returns the sum of a counter for the data points that have all the attributes
+func counterValue(t *testing.T, reader *sdkmetric.ManualReader, name string, attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+
+	var value int64
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			require.True(t, ok, name)
+			for _, dp := range sum.DataPoints {
+				matches := true
+				for _, attr := range attrs {
+					if v, ok := dp.Attributes.Value(attr.Key); !ok || v != attr.Value {
+						matches = false
+					}
+				}
+				if matches {
+					value += dp.Value
+				}
+			}
+		}
+	}
+	return value
+}
+
+func testMessage(payload []byte) *utils.Message {
+	return &utils.Message{
+		Src:      netip.MustParseAddrPort("127.0.0.1:5000"),
+		Dst:      netip.MustParseAddrPort("127.0.0.1:2055"),
+		Payload:  payload,
+		Received: time.Now(),
+	}
+}
+
+// ipfixDataWithoutTemplate builds an IPFIX packet with a data set of a template that was never sent
+func ipfixDataWithoutTemplate() []byte {
+	packet := make([]byte, 24)
+	binary.BigEndian.PutUint16(packet[0:], 10) // version
+	binary.BigEndian.PutUint16(packet[2:], 24) // length
+	binary.BigEndian.PutUint32(packet[12:], 1) // observation domain
+	binary.BigEndian.PutUint16(packet[16:], 300)
+	binary.BigEndian.PutUint16(packet[18:], 8)
+	return packet
+}
+
+func TestTelemetry(t *testing.T) {
+	decode, _, reader := newTestTelemetryDecoder(t, "netflow", &consumertest.LogsSink{})
+	listenerAttrs := []attribute.KeyValue{attribute.String("scheme", "netflow"), attribute.String("listener", ":2055")}
+
+	require.NoError(t, decode(testMessage(netflowV5Packet())))
+	require.Error(t, decode(testMessage(ipfixDataWithoutTemplate())))
+	require.Error(t, decode(testMessage([]byte{0, 42})))
+
+	assert.Equal(t, int64(3), counterValue(t, reader, "otelcol_netflow_receiver_packets_received", listenerAttrs...))
+	assert.Equal(t, int64(len(netflowV5Packet())+len(ipfixDataWithoutTemplate())+2), counterValue(t, reader, "otelcol_netflow_receiver_bytes_received", listenerAttrs...))
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_netflow_receiver_flows_produced", listenerAttrs...))
+	assert.Equal(t, int64(0), counterValue(t, reader, "otelcol_netflow_receiver_flows_refused", listenerAttrs...))
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_netflow_receiver_decode_errors", append(listenerAttrs, attribute.String("error.type", errorTypeTemplateNotFound))...))
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_netflow_receiver_decode_errors", append(listenerAttrs, attribute.String("error.type", errorTypeDecode))...))
+
+	// The flows accepted by the pipeline are also recorded with the standard receiver metrics
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_receiver_accepted_log_records", attribute.String("transport", "udp")))
+}
+
+func TestTelemetryUnknownProtocol(t *testing.T) {
+	decode, _, reader := newTestTelemetryDecoder(t, "flow", &consumertest.LogsSink{})
+
+	require.Error(t, decode(testMessage([]byte{0, 42, 0, 0})))
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_netflow_receiver_decode_errors",
+		attribute.String("scheme", "flow"), attribute.String("error.type", errorTypeUnknownProtocol)))
+}
+
+func TestTelemetryRefusedFlows(t *testing.T) {
+	decode, _, reader := newTestTelemetryDecoder(t, "netflow", consumertest.NewErr(errors.New("pipeline is full")))
+
+	err := decode(testMessage(netflowV5Packet()))
+	var cErr *consumerError
+	require.ErrorAs(t, err, &cErr)
+
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_netflow_receiver_flows_refused"))
+	assert.Equal(t, int64(0), counterValue(t, reader, "otelcol_netflow_receiver_flows_produced"))
+	// A refused flow is not a decoding error
+	assert.Equal(t, int64(0), counterValue(t, reader, "otelcol_netflow_receiver_decode_errors"))
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_receiver_refused_log_records"))
+}
+
+func TestTelemetryDisabled(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	params := receivertest.NewNopSettings()
+	params.TelemetrySettings.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	params.TelemetrySettings.MetricsLevel = configtelemetry.LevelNone
+
+	telemetry, err := newReceiverTelemetry(params)
+	require.NoError(t, err)
+	telemetry.forListener(defaultListenerConfig()).packetDropped()
+	assert.Equal(t, int64(0), counterValue(t, reader, "otelcol_netflow_receiver_packets_dropped"))
+}
+
+func TestTelemetryDroppedPackets(t *testing.T) {
+	_, l, reader := newTestTelemetryDecoder(t, "netflow", &consumertest.LogsSink{})
+
+	handler := dropHandler{logger: l.logger, telemetry: l.telemetry}
+	handler.Dropped(*testMessage(netflowV5Packet()))
+	handler.Dropped(*testMessage(netflowV5Packet()))
+	assert.Equal(t, int64(2), counterValue(t, reader, "otelcol_netflow_receiver_packets_dropped", attribute.String("scheme", "netflow")))
+}
@@ -0,0 +1,216 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	flowpb "github.com/netsampler/goflow2/v2/pb"
+	"github.com/netsampler/goflow2/v2/utils"
+	"go.uber.org/zap"
+)
+
+// bufferKey identifies the template a buffered packet waits for
+// goflow2 keeps the templates by the address and port the packets come from, so the packets are buffered the same way
+type bufferKey struct {
+	src      netip.AddrPort
+	version  uint16
+	domain   uint32
+	template uint16
+}
+
+type bufferedPacket struct {
+	key bufferKey
+	msg *utils.Message
+	// buffered is when the packet was first buffered, it is kept when the packet waits for another template after a replay
+	buffered time.Time
+}
+
+// templateBuffer holds the NetFlow v9 and IPFIX packets with data sets whose template is not known yet
+// and decodes them again once the exporter sends the template, or drops them after the max age
+type templateBuffer struct {
+	cfg       TemplateBufferConfig
+	logger    *zap.Logger
+	telemetry *listenerTelemetry
+	// sequences keeps the sequence gaps of the buffered packets open until they are replayed
+	sequences *sequenceTracker
+	// now is replaced in the tests
+	now func() time.Time
+
+	mu sync.Mutex
+	// packets are the buffered packets, the oldest first
+	packets *list.List
+	byKey   map[bufferKey][]*list.Element
+	// ready are the packets whose template arrived, they are decoded after the packet with the template
+	ready []*bufferedPacket
+}
+
+func newTemplateBuffer(cfg TemplateBufferConfig, logger *zap.Logger, telemetry *listenerTelemetry, sequences *sequenceTracker) *templateBuffer {
+	return &templateBuffer{
+		cfg:       cfg,
+		logger:    logger,
+		telemetry: telemetry,
+		sequences: sequences,
+		now:       time.Now,
+		packets:   list.New(),
+		byKey:     make(map[bufferKey][]*list.Element),
+	}
+}
+
+// decodeFunc buffers the packets that cannot be decoded because of a missing template
+// and decodes the buffered packets once the packet with their template was decoded
+func (b *templateBuffer) decodeFunc(decode utils.DecoderFunc) utils.DecoderFunc {
+	return func(msg any) error {
+		b.expire()
+		err := b.decode(decode, msg, time.Time{})
+		b.replay(decode)
+		return err
+	}
+}
+
+// decode buffers the packet if its template is missing, buffered is zero unless the packet is replayed
+func (b *templateBuffer) decode(decode utils.DecoderFunc, msg any, buffered time.Time) error {
+	err := decode(msg)
+	pkt, ok := msg.(*utils.Message)
+	var flowErr *netflow.FlowError
+	if !ok || !errors.Is(err, netflow.ErrorTemplateNotFound) || !errors.As(err, &flowErr) {
+		return err
+	}
+
+	key := bufferKey{src: pkt.Src, version: flowErr.Version, domain: flowErr.ObsDomainId, template: flowErr.TemplateId}
+	if buffered.IsZero() {
+		buffered = b.now()
+		// The payload belongs to a pool of the UDP receiver and is reused once the packet is decoded
+		copied := *pkt
+		copied.Payload = append([]byte(nil), pkt.Payload...)
+		pkt = &copied
+	}
+	if !b.add(&bufferedPacket{key: key, msg: pkt, buffered: buffered}) {
+		return err
+	}
+	b.telemetry.bufferedPackets.Add(context.Background(), 1, b.telemetry.attrs)
+	if b.sequences != nil {
+		b.sequences.hold(key.sequenceKey())
+	}
+	return nil
+}
+
+// sequenceKey returns the key of the sequence the buffered packets belong to
+func (k bufferKey) sequenceKey() sequenceKey {
+	flowType := flowpb.FlowMessage_NETFLOW_V9
+	if k.version == 10 {
+		flowType = flowpb.FlowMessage_IPFIX
+	}
+	return sequenceKey{exporter: k.src.Addr().Unmap(), flowType: flowType, domain: k.domain}
+}
+
+// add buffers a packet, it returns false when the buffer is full
+func (b *templateBuffer) add(p *bufferedPacket) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.packets.Len() >= b.cfg.MaxPackets {
+		return false
+	}
+	b.byKey[p.key] = append(b.byKey[p.key], b.packets.PushBack(p))
+	return true
+}
+
+// expire drops the packets that were buffered for longer than the max age
+func (b *templateBuffer) expire() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	for e := b.packets.Front(); e != nil; e = b.packets.Front() {
+		p := e.Value.(*bufferedPacket)
+		if now.Sub(p.buffered) <= b.cfg.MaxAge {
+			// The packets that wait for another template after a replay are at the back, they are dropped once they are replayed
+			return
+		}
+		b.remove(e)
+		b.telemetry.expiredPackets.Add(context.Background(), 1, b.telemetry.attrs)
+	}
+}
+
+// remove removes a packet from the buffer, it must be called with the lock held
+func (b *templateBuffer) remove(e *list.Element) {
+	p := b.packets.Remove(e).(*bufferedPacket)
+	elements := b.byKey[p.key]
+	for i, other := range elements {
+		if other == e {
+			elements = append(elements[:i], elements[i+1:]...)
+			break
+		}
+	}
+	if len(elements) == 0 {
+		delete(b.byKey, p.key)
+	} else {
+		b.byKey[p.key] = elements
+	}
+}
+
+// templateAdded makes the packets that wait for the template ready to be decoded
+func (b *templateBuffer) templateAdded(key bufferKey) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	elements := b.byKey[key]
+	delete(b.byKey, key)
+	for _, e := range elements {
+		b.ready = append(b.ready, b.packets.Remove(e).(*bufferedPacket))
+	}
+}
+
+// replay decodes the packets whose template arrived
+func (b *templateBuffer) replay(decode utils.DecoderFunc) {
+	for {
+		b.mu.Lock()
+		ready := b.ready
+		b.ready = nil
+		b.mu.Unlock()
+		if len(ready) == 0 {
+			return
+		}
+
+		now := b.now()
+		for _, p := range ready {
+			if now.Sub(p.buffered) > b.cfg.MaxAge {
+				b.telemetry.expiredPackets.Add(context.Background(), 1, b.telemetry.attrs)
+				continue
+			}
+			b.telemetry.replayedPackets.Add(context.Background(), 1, b.telemetry.attrs)
+			// A packet can wait for another template, then it is buffered again
+			if err := b.decode(decode, p.msg, p.buffered); err != nil {
+				b.logger.Warn("Failed to decode a buffered packet", zap.Error(err))
+			}
+		}
+	}
+}
+
+// templateSystem returns a template system that replays the packets of the exporter when their template arrives
+func (b *templateBuffer) templateSystem(src netip.AddrPort, templates netflow.NetFlowTemplateSystem) netflow.NetFlowTemplateSystem {
+	return &bufferedTemplateSystem{
+		NetFlowTemplateSystem: templates,
+		src:                   src,
+		buffer:                b,
+	}
+}
+
+type bufferedTemplateSystem struct {
+	netflow.NetFlowTemplateSystem
+	src    netip.AddrPort
+	buffer *templateBuffer
+}
+
+func (s *bufferedTemplateSystem) AddTemplate(version uint16, obsDomainID uint32, templateID uint16, template any) error {
+	if err := s.NetFlowTemplateSystem.AddTemplate(version, obsDomainID, templateID, template); err != nil {
+		return err
+	}
+	s.buffer.templateAdded(bufferKey{src: s.src, version: version, domain: obsDomainID, template: templateID})
+	return nil
+}
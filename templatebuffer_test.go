@@ -0,0 +1,93 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	flowpb "github.com/netsampler/goflow2/v2/pb"
+	"github.com/netsampler/goflow2/v2/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/config/configtelemetry"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// newTestBufferDecoder creates the decode function of a listener that buffers at most maxPackets packets
+func newTestBufferDecoder(t *testing.T, maxPackets int) (utils.DecoderFunc, *listener, *consumertest.LogsSink, *sdkmetric.ManualReader) {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	params := receivertest.NewNopSettings()
+	params.TelemetrySettings.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	params.TelemetrySettings.MetricsLevel = configtelemetry.LevelDetailed
+
+	cfg := createDefaultConfig().(*Config)
+	cfg.Templates.Buffer.MaxPackets = maxPackets
+	nr, err := newNetflowReceiver(params, *cfg)
+	require.NoError(t, err)
+	sink := &consumertest.LogsSink{}
+	nr.logConsumer = sink
+
+	l := nr.listeners[0]
+	decode, err := nr.buildDecodeFunc(l)
+	require.NoError(t, err)
+	return decode, l, sink, reader
+}
+
+func TestTemplateBufferReplay(t *testing.T) {
+	decode, _, sink, reader := newTestBufferDecoder(t, 10)
+
+	msg := testMessage(ipfixDataPacket())
+	require.NoError(t, decode(msg))
+	// The payload is reused by the UDP receiver once the packet is decoded
+	clear(msg.Payload)
+	assert.Equal(t, 0, sink.LogRecordCount())
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_netflow_receiver_template_buffer_buffered"))
+
+	// The packet with the template is decoded first, then the buffered packet
+	require.NoError(t, decode(testMessage(ipfixPacket())))
+	assert.Equal(t, 2, sink.LogRecordCount())
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_netflow_receiver_template_buffer_replayed"))
+	assert.Equal(t, int64(0), counterValue(t, reader, "otelcol_netflow_receiver_template_buffer_expired"))
+	assert.Equal(t, int64(0), counterValue(t, reader, "otelcol_netflow_receiver_decode_errors"))
+}
+
+func TestTemplateBufferExpired(t *testing.T) {
+	decode, l, sink, reader := newTestBufferDecoder(t, 10)
+	clock := &fakeClock{now: time.Now()}
+	l.buffer.now = clock.Now
+
+	require.NoError(t, decode(testMessage(ipfixDataPacket())))
+	clock.advance(2 * time.Minute)
+	require.NoError(t, decode(testMessage(netflowV5Packet())))
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_netflow_receiver_template_buffer_expired"))
+
+	// The template arrives too late for the expired packet
+	require.NoError(t, decode(testMessage(ipfixPacket())))
+	assert.Equal(t, 2, sink.LogRecordCount())
+	assert.Equal(t, int64(0), counterValue(t, reader, "otelcol_netflow_receiver_template_buffer_replayed"))
+}
+
+func TestTemplateBufferFull(t *testing.T) {
+	decode, l, _, reader := newTestBufferDecoder(t, 1)
+
+	require.NoError(t, decode(testMessage(ipfixDataPacket())))
+	err := decode(testMessage(ipfixDataPacket()))
+	require.ErrorIs(t, err, netflow.ErrorTemplateNotFound)
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_netflow_receiver_template_buffer_buffered"))
+	assert.Equal(t, int64(1), counterValue(t, reader, "otelcol_netflow_receiver_decode_errors"))
+	assert.Equal(t, 1, l.buffer.packets.Len())
+}
+
+func TestTemplateBufferSequenceKey(t *testing.T) {
+	key := bufferKey{src: netip.MustParseAddrPort("[::ffff:192.0.2.1]:2055"), version: 10, domain: 7, template: 256}
+	assert.Equal(t, sequenceKey{exporter: netip.MustParseAddr("192.0.2.1"), flowType: flowpb.FlowMessage_IPFIX, domain: 7}, key.sequenceKey())
+	key.version = 9
+	assert.Equal(t, flowpb.FlowMessage_NETFLOW_V9, key.sequenceKey().flowType)
+}
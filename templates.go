@@ -0,0 +1,324 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver // import "github.com/open-telemetry/opentelemetry-collector-contrib/receiver/netflowreceiver"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"go.uber.org/zap"
+)
+
+const (
+	// The kinds of the templates in the templates file, goflow2 stores each of them as a different type
+	templateKindData         = "data"
+	templateKindNFv9Options  = "nfv9_options"
+	templateKindIPFIXOptions = "ipfix_options"
+)
+
+// templateKey identifies a template, the template ids are only unique for an observation domain of an exporter
+type templateKey struct {
+	exporter netip.Addr
+	version  uint16
+	domain   uint32
+	id       uint16
+}
+
+// storedTemplate is a template and when the exporter last sent it
+type storedTemplate struct {
+	template any
+	updated  time.Time
+}
+
+// templateFileEntry is a template in the templates file
+type templateFileEntry struct {
+	Exporter          string          `json:"exporter"`
+	Version           uint16          `json:"version"`
+	ObservationDomain uint32          `json:"observation_domain"`
+	TemplateID        uint16          `json:"template_id"`
+	Kind              string          `json:"kind"`
+	Updated           time.Time       `json:"updated"`
+	Template          json.RawMessage `json:"template"`
+}
+
+// templateStore keeps the NetFlow v9 and IPFIX templates of every exporter in a file
+// The templates are loaded when the receiver starts, so the flows of the exporters can be decoded before they send their templates again
+type templateStore struct {
+	cfg    TemplatesConfig
+	logger *zap.Logger
+	// now is replaced in the tests
+	now func() time.Time
+
+	mu        sync.Mutex
+	templates map[templateKey]storedTemplate
+	// changed is set when templates were added or removed since the file was saved
+	changed bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newTemplateStore(cfg TemplatesConfig, logger *zap.Logger) *templateStore {
+	return &templateStore{
+		cfg:       cfg,
+		logger:    logger.With(zap.String("path", cfg.File)),
+		now:       time.Now,
+		templates: make(map[templateKey]storedTemplate),
+	}
+}
+
+// start loads the templates of the file and saves them every save interval until stop is called
+// A file that cannot be read is not an error, the exporters will send their templates again
+func (s *templateStore) start() {
+	switch err := s.load(); {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		s.logger.Warn("Failed to load the templates, they are learned from the exporters again", zap.Error(err))
+	default:
+		s.logger.Info("Loaded templates", zap.Int("templates", len(s.templates)))
+	}
+	if s.cfg.SaveInterval == 0 {
+		return
+	}
+
+	s.done = make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.cfg.SaveInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				s.saveOrWarn()
+			}
+		}
+	}()
+}
+
+// stop saves the templates one last time
+func (s *templateStore) stop() {
+	if s.done != nil {
+		close(s.done)
+		s.wg.Wait()
+		s.done = nil
+	}
+	s.saveOrWarn()
+}
+
+func (s *templateStore) saveOrWarn() {
+	if err := s.save(); err != nil {
+		s.logger.Warn("Failed to save the templates", zap.Error(err))
+	}
+}
+
+// expired returns whether a template was sent too long ago to be used
+func (s *templateStore) expired(t storedTemplate, now time.Time) bool {
+	return s.cfg.MaxAge > 0 && now.Sub(t.updated) > s.cfg.MaxAge
+}
+
+// load replaces the templates with the ones of the file that did not expire
+func (s *templateStore) load() error {
+	data, err := os.ReadFile(s.cfg.File)
+	if err != nil {
+		return err
+	}
+	var entries []templateFileEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return err
+	}
+
+	templates := make(map[templateKey]storedTemplate, len(entries))
+	now := s.now()
+	for i, entry := range entries {
+		exporter, err := netip.ParseAddr(entry.Exporter)
+		if err != nil {
+			return fmt.Errorf("template %d: exporter address %q is not valid: %w", i, entry.Exporter, err)
+		}
+		template, err := decodeTemplate(entry.Kind, entry.Template)
+		if err != nil {
+			return fmt.Errorf("template %d: %w", i, err)
+		}
+		stored := storedTemplate{template: template, updated: entry.Updated}
+		if s.expired(stored, now) {
+			continue
+		}
+		templates[templateKey{exporter: exporter.Unmap(), version: entry.Version, domain: entry.ObservationDomain, id: entry.TemplateID}] = stored
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates = templates
+	return nil
+}
+
+// save writes the templates that did not expire to the file, if they changed
+// The file is replaced at once, so it is never left half written
+func (s *templateStore) save() error {
+	s.mu.Lock()
+	now := s.now()
+	for key, t := range s.templates {
+		if s.expired(t, now) {
+			delete(s.templates, key)
+			s.changed = true
+		}
+	}
+	if !s.changed {
+		s.mu.Unlock()
+		return nil
+	}
+	entries := make([]templateFileEntry, 0, len(s.templates))
+	for key, t := range s.templates {
+		kind, template, err := encodeTemplate(t.template)
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		entries = append(entries, templateFileEntry{
+			Exporter:          key.exporter.String(),
+			Version:           key.version,
+			ObservationDomain: key.domain,
+			TemplateID:        key.id,
+			Kind:              kind,
+			Updated:           t.updated,
+			Template:          template,
+		})
+	}
+	s.changed = false
+	s.mu.Unlock()
+
+	data, err := json.Marshal(entries)
+	if err == nil {
+		err = writeFileAtomic(s.cfg.File, data)
+	}
+	if err != nil {
+		// The templates are saved again the next time
+		s.mu.Lock()
+		s.changed = true
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// writeFileAtomic writes the data to a temporary file next to the path and renames it
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func encodeTemplate(template any) (string, json.RawMessage, error) {
+	var kind string
+	switch template.(type) {
+	case netflow.TemplateRecord:
+		kind = templateKindData
+	case netflow.NFv9OptionsTemplateRecord:
+		kind = templateKindNFv9Options
+	case netflow.IPFIXOptionsTemplateRecord:
+		kind = templateKindIPFIXOptions
+	default:
+		return "", nil, fmt.Errorf("template of type %T is not supported", template)
+	}
+	data, err := json.Marshal(template)
+	return kind, data, err
+}
+
+func decodeTemplate(kind string, data json.RawMessage) (any, error) {
+	switch kind {
+	case templateKindData:
+		var template netflow.TemplateRecord
+		err := json.Unmarshal(data, &template)
+		return template, err
+	case templateKindNFv9Options:
+		var template netflow.NFv9OptionsTemplateRecord
+		err := json.Unmarshal(data, &template)
+		return template, err
+	case templateKindIPFIXOptions:
+		var template netflow.IPFIXOptionsTemplateRecord
+		err := json.Unmarshal(data, &template)
+		return template, err
+	default:
+		return nil, fmt.Errorf("template kind %q is not supported", kind)
+	}
+}
+
+func (s *templateStore) add(key templateKey, template any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates[key] = storedTemplate{template: template, updated: s.now()}
+	s.changed = true
+}
+
+func (s *templateStore) remove(key templateKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.templates[key]; ok {
+		delete(s.templates, key)
+		s.changed = true
+	}
+}
+
+// templateSystem adds the stored templates of the exporter to its template system
+// and returns a template system that stores the templates the exporter sends
+func (s *templateStore) templateSystem(exporter netip.Addr, templates netflow.NetFlowTemplateSystem) netflow.NetFlowTemplateSystem {
+	s.mu.Lock()
+	now := s.now()
+	for key, t := range s.templates {
+		if key.exporter != exporter || s.expired(t, now) {
+			continue
+		}
+		if err := templates.AddTemplate(key.version, key.domain, key.id, t.template); err != nil {
+			s.logger.Warn("Failed to add a stored template", zap.Stringer("exporter", exporter), zap.Error(err))
+		}
+	}
+	s.mu.Unlock()
+
+	return &storedTemplateSystem{
+		NetFlowTemplateSystem: templates,
+		exporter:              exporter,
+		store:                 s,
+	}
+}
+
+// storedTemplateSystem stores the templates an exporter sends
+type storedTemplateSystem struct {
+	netflow.NetFlowTemplateSystem
+	exporter netip.Addr
+	store    *templateStore
+}
+
+func (s *storedTemplateSystem) AddTemplate(version uint16, obsDomainID uint32, templateID uint16, template any) error {
+	if err := s.NetFlowTemplateSystem.AddTemplate(version, obsDomainID, templateID, template); err != nil {
+		return err
+	}
+	s.store.add(templateKey{exporter: s.exporter, version: version, domain: obsDomainID, id: templateID}, template)
+	return nil
+}
+
+func (s *storedTemplateSystem) RemoveTemplate(version uint16, obsDomainID uint32, templateID uint16) (any, error) {
+	template, err := s.NetFlowTemplateSystem.RemoveTemplate(version, obsDomainID, templateID)
+	if err == nil {
+		s.store.remove(templateKey{exporter: s.exporter, version: version, domain: obsDomainID, id: templateID})
+	}
+	return template, err
+}
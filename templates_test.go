@@ -0,0 +1,132 @@
+// Copyright The OpenTelemetry Authors
+// SPDX-License-Identifier: Apache-2.0
+
+package netflowreceiver
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/netsampler/goflow2/v2/decoders/netflow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+	"go.uber.org/zap"
+)
+
+// ipfixDataPacket builds the IPFIX packet of ipfixPacket without its template set
+func ipfixDataPacket() []byte {
+	packet := ipfixPacket()
+	templateLength := int(binary.BigEndian.Uint16(packet[18:]))
+	data := append(packet[:16:16], packet[16+templateLength:]...)
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)))
+	return data
+}
+
+func TestTemplateStoreRoundTrip(t *testing.T) {
+	cfg := TemplatesConfig{File: filepath.Join(t.TempDir(), "templates.json")}
+	store := newTemplateStore(cfg, zap.NewNop())
+	exporter := netip.MustParseAddr("192.0.2.1")
+	field := netflow.Field{Type: 1, Length: 8}
+	templates := map[templateKey]any{
+		{exporter: exporter, version: 10, domain: 1, id: 256}: netflow.TemplateRecord{TemplateId: 256, FieldCount: 1, Fields: []netflow.Field{field}},
+		{exporter: exporter, version: 9, domain: 2, id: 257}:  netflow.NFv9OptionsTemplateRecord{TemplateId: 257, ScopeLength: 4, OptionLength: 4, Scopes: []netflow.Field{field}, Options: []netflow.Field{field}},
+		{exporter: exporter, version: 10, domain: 1, id: 258}: netflow.IPFIXOptionsTemplateRecord{TemplateId: 258, FieldCount: 2, ScopeFieldCount: 1, Scopes: []netflow.Field{field}, Options: []netflow.Field{{PenProvided: true, Type: 1, Length: 4, Pen: 9}}},
+	}
+	for key, template := range templates {
+		store.add(key, template)
+	}
+	store.remove(templateKey{exporter: exporter, version: 10, domain: 1, id: 999})
+	require.NoError(t, store.save())
+
+	loaded := newTemplateStore(cfg, zap.NewNop())
+	require.NoError(t, loaded.load())
+	require.Len(t, loaded.templates, len(templates))
+	for key, template := range templates {
+		assert.Equal(t, template, loaded.templates[key].template)
+	}
+
+	// The file is not written again when nothing changed
+	require.NoError(t, os.Remove(cfg.File))
+	require.NoError(t, loaded.save())
+	assert.NoFileExists(t, cfg.File)
+}
+
+func TestTemplateStoreMaxAge(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	cfg := TemplatesConfig{File: filepath.Join(t.TempDir(), "templates.json"), MaxAge: time.Hour}
+	store := newTemplateStore(cfg, zap.NewNop())
+	store.now = clock.Now
+
+	exporter := netip.MustParseAddr("192.0.2.1")
+	old := templateKey{exporter: exporter, version: 10, domain: 1, id: 256}
+	recent := templateKey{exporter: exporter, version: 10, domain: 1, id: 257}
+	store.add(old, netflow.TemplateRecord{TemplateId: 256})
+	clock.advance(30 * time.Minute)
+	store.add(recent, netflow.TemplateRecord{TemplateId: 257})
+	require.NoError(t, store.save())
+
+	// The old template expires while the receiver is stopped
+	clock.advance(45 * time.Minute)
+	loaded := newTemplateStore(cfg, zap.NewNop())
+	loaded.now = clock.Now
+	require.NoError(t, loaded.load())
+	assert.NotContains(t, loaded.templates, old)
+	assert.Contains(t, loaded.templates, recent)
+
+	// The expired templates are not given to the exporters
+	clock.advance(time.Hour)
+	templates := loaded.templateSystem(exporter, netflow.CreateTemplateSystem())
+	_, err := templates.GetTemplate(10, 1, 257)
+	assert.ErrorIs(t, err, netflow.ErrorTemplateNotFound)
+}
+
+func TestTemplateStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.json")
+	require.NoError(t, os.WriteFile(path, []byte(`[{"exporter":"192.0.2.1","kind":"unknown","template":{}}]`), 0o600))
+
+	store := newTemplateStore(TemplatesConfig{File: path}, zap.NewNop())
+	assert.EqualError(t, store.load(), `template 0: template kind "unknown" is not supported`)
+	// The receiver starts without the templates
+	store.start()
+	assert.Empty(t, store.templates)
+	store.stop()
+}
+
+func TestTemplatesPersistedAcrossRestarts(t *testing.T) {
+	cfg := createDefaultConfig().(*Config)
+	cfg.Listeners[0].Scheme = "flow"
+	cfg.Templates.File = filepath.Join(t.TempDir(), "templates.json")
+
+	newReceiver := func() (*netflowReceiver, *consumertest.LogsSink) {
+		nr, err := newNetflowReceiver(receivertest.NewNopSettings(), *cfg)
+		require.NoError(t, err)
+		sink := &consumertest.LogsSink{}
+		nr.logConsumer = sink
+		nr.templates.start()
+		return nr, sink
+	}
+
+	nr, _ := newReceiver()
+	decode, err := nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+	require.NoError(t, decode(testMessage(ipfixPacket())))
+	nr.stopTemplates()
+
+	// After the restart, the data of the exporter is decoded before it sends its template again
+	nr, sink := newReceiver()
+	decode, err = nr.buildDecodeFunc(nr.listeners[0])
+	require.NoError(t, err)
+	require.NoError(t, decode(testMessage(ipfixDataPacket())))
+	assert.Equal(t, 1, sink.LogRecordCount())
+	nr.stopTemplates()
+
+	nr.exporters.mu.Lock()
+	defer nr.exporters.mu.Unlock()
+	assert.Equal(t, int64(1), nr.exporters.exporters[netip.MustParseAddr("127.0.0.1")].templates)
+}